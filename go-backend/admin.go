@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// adminToken guards the /api/admin endpoints, appends and imports, set
+// from a flag in main. When it is empty they are disabled.
+var adminToken string
+
+// reloadDataset reads the -data or -csv file again, or is nil when serving
+// synthetic data
+var reloadDataset func() ([]VectorItem, error)
+
+// ResetResponse is the response structure for resets. It counts what was
+// dropped; Total is the size of the dataset after reloading a file.
+type ResetResponse struct {
+	CacheEntries int  `json:"cache_entries"`
+	ANNIndexes   int  `json:"ann_indexes"`
+	NormDatasets int  `json:"norm_datasets"`
+	Jobs         int  `json:"jobs"`
+	Reloaded     bool `json:"reloaded"`
+	Total        *int `json:"total,omitempty"`
+}
+
+// beginAdmin checks that the request carries the admin token as a bearer
+// token, answering it when it doesn't
+func beginAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if adminToken == "" {
+		writeError(w, http.StatusForbidden, "admin endpoints are disabled; start the server with -admin-token")
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeError(w, http.StatusUnauthorized, "a valid admin token is required")
+		return false
+	}
+	return true
+}
+
+// handleAdminReset clears the server's in-memory state without a restart:
+// cached responses, nearest-neighbor indexes, datasets cached with their
+// norms and finished jobs. With reload=true it also reads the -data or -csv
+// file again, dropping any appended items, or goes back to synthetic data
+// when the server started without one. Running jobs carry on.
+func handleAdminReset(w http.ResponseWriter, r *http.Request) {
+	if !beginPOST(w, r) {
+		return
+	}
+	if !beginAdmin(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	reload := false // Default
+	if reloadStr := r.URL.Query().Get("reload"); reloadStr != "" {
+		parsedReload, err := strconv.ParseBool(reloadStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "reload must be true or false")
+			return
+		}
+		reload = parsedReload
+	}
+
+	// Reload before clearing, so nothing cached from the old data outlives
+	// the reset
+	var response ResetResponse
+	switch {
+	case reload && reloadDataset == nil:
+		// Drop imported data and serve synthetic vectors again
+		loadedMu.Lock()
+		loadedDataset = nil
+		loadedMu.Unlock()
+		response.Reloaded = true
+	case reload:
+		items, err := reloadDataset()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "reloading the dataset: "+err.Error())
+			return
+		}
+		loadedMu.Lock()
+		loadedDataset = items
+		loadedMu.Unlock()
+
+		total := len(items)
+		response.Reloaded = true
+		response.Total = &total
+	}
+
+	if vectorCache != nil {
+		response.CacheEntries = vectorCache.Clear()
+	}
+	response.ANNIndexes = annIndexes.Clear()
+	response.NormDatasets = normDatasets.Clear()
+	response.Jobs = jobs.ClearFinished()
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(response)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResetReloadReturnsToSyntheticData(t *testing.T) {
+	defer func(token string, reload func() ([]VectorItem, error)) {
+		adminToken, reloadDataset = token, reload
+	}(adminToken, reloadDataset)
+	adminToken, reloadDataset = "secret", nil
+
+	// As if items were imported into a server started without -data
+	withLoadedDataset(t, constantItems(3, []float64{1, 2}))
+	normDatasets.Set("imported", testNormedDataset(3, 2))
+
+	r := httptest.NewRequest("POST", "/api/admin/reset?reload=true", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handleAdminReset(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var response ResetResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !response.Reloaded || response.Total != nil {
+		t.Errorf("reloaded %v with total %v, want reloaded without a total", response.Reloaded, response.Total)
+	}
+	if loadedItems() != nil {
+		t.Error("the imported dataset is still served")
+	}
+	if _, ok := normDatasets.Get("imported"); ok {
+		t.Error("datasets cached with their norms survived the reset")
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Neighbor search strategies accepted by the index query parameter
+const (
+	indexExact = "exact" // Score every vector
+	indexANN   = "ann"   // Search a cached HNSW graph
+)
+
+// Bounds and defaults for the HNSW parameters. M is the number of links a
+// node keeps per layer (twice that on the bottom layer), efConstruction
+// the breadth of the searches that choose them, and ef the breadth of a
+// query. Larger values trade speed for recall.
+const (
+	defaultANNM              = 16
+	maxANNM                  = 64
+	defaultANNEf             = 64
+	maxANNEf                 = 4096
+	defaultANNEfConstruction = 100
+)
+
+// Salt mixed into the seed for the level draws, so the index doesn't share
+// a sequence with the data it indexes
+const annSeedSalt = 0x5851F42D4C957F2D
+
+// The most built indexes kept at once. Each holds a copy of its vectors.
+const annCacheCapacity = 4
+
+// annOptions holds the neighbor search parameters of a request
+type annOptions struct {
+	Index string
+	M     int
+	Ef    int
+}
+
+// parseANNOptions reads index, m and ef from the query
+func parseANNOptions(query url.Values) (annOptions, error) {
+	options := annOptions{
+		Index: indexExact,   // Default
+		M:     defaultANNM,  // Default
+		Ef:    defaultANNEf, // Default
+	}
+
+	if index := query.Get("index"); index != "" {
+		if index != indexExact && index != indexANN {
+			return options, fmt.Errorf("index must be %q or %q", indexExact, indexANN)
+		}
+		options.Index = index
+	}
+
+	if mStr := query.Get("m"); mStr != "" {
+		m, err := strconv.Atoi(mStr)
+		if err != nil || m < 2 || m > maxANNM {
+			return options, fmt.Errorf("m must be an integer between 2 and %d", maxANNM)
+		}
+		options.M = m
+	}
+
+	if efStr := query.Get("ef"); efStr != "" {
+		ef, err := strconv.Atoi(efStr)
+		if err != nil || ef <= 0 || ef > maxANNEf {
+			return options, fmt.Errorf("ef must be an integer between 1 and %d", maxANNEf)
+		}
+		options.Ef = ef
+	}
+	return options, nil
+}
+
+// annIndex is a hierarchical navigable small world graph over a fixed set
+// of vectors. Each node is linked to nearby nodes on its own layers; the
+// upper layers are sparse and let a search descend quickly to the right
+// region of the dense bottom layer. It is read-only once built, so
+// searches may run concurrently.
+type annIndex struct {
+	vectors  [][]float64
+	distance func(a, b []float64) float64
+	m        int
+	links    [][][]int // links[node][layer]
+	entry    int
+	maxLayer int
+	visited  sync.Pool // *annVisited sets for Search
+}
+
+// annCandidate is a node and its distance from the query
+type annCandidate struct {
+	Node     int
+	Distance float64
+}
+
+// annHeap is a heap of candidates, nearest first unless farthest is set
+type annHeap struct {
+	items    []annCandidate
+	farthest bool
+}
+
+func (h *annHeap) Len() int { return len(h.items) }
+
+func (h *annHeap) Less(i, j int) bool {
+	if h.farthest {
+		return h.items[i].Distance > h.items[j].Distance
+	}
+	return h.items[i].Distance < h.items[j].Distance
+}
+
+func (h *annHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *annHeap) Push(x interface{}) { h.items = append(h.items, x.(annCandidate)) }
+
+func (h *annHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// metricANNDistance returns the vectors to index and the distance to index
+// them by. Cosine vectors are normalized up front so comparing them is a
+// dot product.
+func metricANNDistance(vectors [][]float64, metric similarityMetric) ([][]float64, func(a, b []float64) float64, bool) {
+	switch metric.Name {
+	case metricCosine:
+		normalized := make([][]float64, len(vectors))
+		for i, v := range vectors {
+			normalized[i] = normalizeVector(v)
+		}
+		return normalized, func(a, b []float64) float64 {
+			return 1 - dotProduct(a, b)
+		}, true
+	case metricEuclidean:
+		return vectors, euclideanDistance, true
+	default:
+		return nil, nil, false
+	}
+}
+
+// buildANNIndex inserts every vector into a new graph. Node levels are
+// drawn from rng, so the same source builds the same graph. It gives up
+// with the context's error if ctx ends first.
+func buildANNIndex(ctx context.Context, vectors [][]float64, distance func(a, b []float64) float64, m, efConstruction int, rng *rand.Rand) (*annIndex, error) {
+	index := &annIndex{
+		vectors:  vectors,
+		distance: distance,
+		m:        m,
+		links:    make([][][]int, len(vectors)),
+		entry:    -1,
+	}
+	levelScale := 1 / math.Log(float64(m))
+	visited := newANNVisited(len(vectors))
+	for node := range vectors {
+		if node%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		level := int(-math.Log(1-rng.Float64()) * levelScale)
+		index.insert(node, level, efConstruction, visited)
+	}
+	return index, nil
+}
+
+// maxLinks returns the most links a node keeps on layer
+func (index *annIndex) maxLinks(layer int) int {
+	if layer == 0 {
+		return 2 * index.m
+	}
+	return index.m
+}
+
+// insert links node into layers 0 through level
+func (index *annIndex) insert(node, level, efConstruction int, visited *annVisited) {
+	index.links[node] = make([][]int, level+1)
+	if index.entry < 0 {
+		index.entry = node
+		index.maxLayer = level
+		return
+	}
+
+	query := index.vectors[node]
+	entry := annCandidate{Node: index.entry, Distance: index.distance(query, index.vectors[index.entry])}
+	for layer := index.maxLayer; layer > level; layer-- {
+		entry = index.searchLayer(query, entry, 1, layer, visited)[0]
+	}
+
+	for layer := min(level, index.maxLayer); layer >= 0; layer-- {
+		candidates := index.searchLayer(query, entry, efConstruction, layer, visited)
+		for _, neighbor := range index.selectNeighbors(candidates, index.m) {
+			index.links[node][layer] = append(index.links[node][layer], neighbor.Node)
+			index.link(neighbor.Node, node, layer)
+		}
+		entry = candidates[0]
+	}
+
+	if level > index.maxLayer {
+		index.entry = node
+		index.maxLayer = level
+	}
+}
+
+// selectNeighbors picks up to m of candidates, which are nearest first,
+// skipping any that is closer to an already picked one than to the query.
+// Links then point in different directions instead of all into the
+// nearest cluster, which keeps the graph navigable between clusters.
+func (index *annIndex) selectNeighbors(candidates []annCandidate, m int) []annCandidate {
+	selected := make([]annCandidate, 0, m)
+	for _, candidate := range candidates {
+		if len(selected) == m {
+			break
+		}
+		keep := true
+		for _, s := range selected {
+			if index.distance(index.vectors[candidate.Node], index.vectors[s.Node]) < candidate.Distance {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			selected = append(selected, candidate)
+		}
+	}
+	return selected
+}
+
+// link adds a link from node to other on layer, reselecting node's links
+// when it has too many
+func (index *annIndex) link(node, other, layer int) {
+	links := append(index.links[node][layer], other)
+	if len(links) > index.maxLinks(layer) {
+		v := index.vectors[node]
+		candidates := make([]annCandidate, len(links))
+		for i, n := range links {
+			candidates[i] = annCandidate{Node: n, Distance: index.distance(v, index.vectors[n])}
+		}
+		sort.Slice(candidates, func(a, b int) bool {
+			return candidates[a].Distance < candidates[b].Distance
+		})
+		links = links[:0]
+		for _, candidate := range index.selectNeighbors(candidates, index.maxLinks(layer)) {
+			links = append(links, candidate.Node)
+		}
+	}
+	index.links[node][layer] = links
+}
+
+// annVisited marks the nodes a search has reached. Bumping the stamp
+// clears it, so one set serves many searches.
+type annVisited struct {
+	stamps []uint32
+	stamp  uint32
+}
+
+func newANNVisited(n int) *annVisited {
+	return &annVisited{stamps: make([]uint32, n)}
+}
+
+// reset starts a new search
+func (v *annVisited) reset() {
+	v.stamp++
+	if v.stamp == 0 {
+		clear(v.stamps)
+		v.stamp = 1
+	}
+}
+
+// visit marks node and reports whether it was already marked
+func (v *annVisited) visit(node int) bool {
+	if v.stamps[node] == v.stamp {
+		return true
+	}
+	v.stamps[node] = v.stamp
+	return false
+}
+
+// searchLayer returns up to ef nodes of layer near query, nearest first,
+// found by a best-first walk from entry
+func (index *annIndex) searchLayer(query []float64, entry annCandidate, ef, layer int, visited *annVisited) []annCandidate {
+	visited.reset()
+	visited.visit(entry.Node)
+	candidates := &annHeap{items: []annCandidate{entry}}
+	results := &annHeap{items: []annCandidate{entry}, farthest: true}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(annCandidate)
+		if current.Distance > results.items[0].Distance && results.Len() >= ef {
+			break
+		}
+		for _, n := range index.links[current.Node][layer] {
+			if visited.visit(n) {
+				continue
+			}
+			d := index.distance(query, index.vectors[n])
+			if results.Len() < ef || d < results.items[0].Distance {
+				heap.Push(candidates, annCandidate{Node: n, Distance: d})
+				heap.Push(results, annCandidate{Node: n, Distance: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	nearest := make([]annCandidate, results.Len())
+	for i := len(nearest) - 1; i >= 0; i-- {
+		nearest[i] = heap.Pop(results).(annCandidate)
+	}
+	return nearest
+}
+
+// Search returns up to k nodes near query, nearest first, exploring ef
+// candidates on the bottom layer
+func (index *annIndex) Search(query []float64, k, ef int) []annCandidate {
+	if index.entry < 0 {
+		return nil
+	}
+
+	// Searches run concurrently, so each takes its own visited set
+	visited, ok := index.visited.Get().(*annVisited)
+	if !ok {
+		visited = newANNVisited(len(index.vectors))
+	}
+	defer index.visited.Put(visited)
+
+	entry := annCandidate{Node: index.entry, Distance: index.distance(query, index.vectors[index.entry])}
+	for layer := index.maxLayer; layer > 0; layer-- {
+		entry = index.searchLayer(query, entry, 1, layer, visited)[0]
+	}
+	nearest := index.searchLayer(query, entry, max(ef, k), 0, visited)
+	return nearest[:min(k, len(nearest))]
+}
+
+// annDataset is a built index with the items it was built from
+type annDataset struct {
+	index *annIndex
+	ids   []string
+	keys  []string
+	nodes map[string]int // Node of each ID
+}
+
+// annCache is a small LRU of built indexes, keyed by everything that
+// determines the dataset and the graph. It is safe for concurrent use.
+// Concurrent misses on one key each build the index; the last one stored
+// wins.
+type annCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // Most recently used at the front
+}
+
+type annCacheEntry struct {
+	key     string
+	dataset *annDataset
+}
+
+// annIndexes holds the indexes built for neighbor queries
+var annIndexes = newANNCache(annCacheCapacity)
+
+func newANNCache(capacity int) *annCache {
+	return &annCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the dataset stored under key
+func (c *annCache) Get(key string) (*annDataset, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*annCacheEntry).dataset, true
+}
+
+// Set stores dataset under key, evicting the least recently used entry
+// when the cache is full
+func (c *annCache) Set(key string, dataset *annDataset) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*annCacheEntry).dataset = dataset
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&annCacheEntry{key: key, dataset: dataset})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*annCacheEntry).key)
+	}
+}
+
+// Clear drops every index, for when the data behind them has changed, and
+// returns how many there were
+func (c *annCache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cleared := c.order.Len()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	return cleared
+}
+
+// annCacheKey identifies the index for a request. The seed only matters
+// for generated data; loaded data is the same for every seed, and the
+// cache is cleared when it changes. Vectors don't depend on the base time.
+func annCacheKey(params vectorParams, metric similarityMetric, m int) string {
+	echo := generationParams(params)
+	echo.BaseTime = time.Time{}
+	generation, _ := json.Marshal(echo)
+	seed := params.Seed
+	if loadedItems() != nil {
+		seed = 0
+	}
+	return fmt.Sprintf("%d|%s|%s|%d", seed, generation, metric.Name, m)
+}
+
+// annNeighborDataset returns the index for the request's dataset, building
+// it on first use. Unseeded synthetic data differs on every request, so its
+// index is built each time and not cached.
+func annNeighborDataset(ctx context.Context, params vectorParams, metric similarityMetric, m int) (*annDataset, error) {
+	cacheable := params.Seeded || loadedItems() != nil
+	key := annCacheKey(params, metric, m)
+	if cacheable {
+		if dataset, ok := annIndexes.Get(key); ok {
+			return dataset, nil
+		}
+	}
+
+	data, err := datasetItems(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	dataset := &annDataset{
+		ids:   make([]string, len(data)),
+		keys:  make([]string, len(data)),
+		nodes: make(map[string]int, len(data)),
+	}
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		dataset.ids[i] = item.ID
+		dataset.keys[i] = item.Key
+		if _, ok := dataset.nodes[item.ID]; !ok {
+			dataset.nodes[item.ID] = i
+		}
+		vectors[i] = item.Vector
+	}
+
+	vectors, distance, _ := metricANNDistance(vectors, metric)
+	rng := rand.New(rand.NewSource(params.Seed ^ annSeedSalt))
+	dataset.index, err = buildANNIndex(ctx, vectors, distance, m, defaultANNEfConstruction, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable {
+		annIndexes.Set(key, dataset)
+	}
+	return dataset, nil
+}
+
+// annNeighbors returns about the k items closest to the target node,
+// excluding the target itself, in the same form as findNeighbors
+func annNeighbors(dataset *annDataset, target, k, ef int, metric similarityMetric) []Neighbor {
+	// Ask for one extra result since the target finds itself
+	index := dataset.index
+	found := index.Search(index.vectors[target], k+1, ef)
+
+	neighbors := make([]Neighbor, 0, k)
+	for _, candidate := range found {
+		if candidate.Node == target || len(neighbors) == k {
+			continue
+		}
+		neighbor := Neighbor{ID: dataset.ids[candidate.Node], Key: dataset.keys[candidate.Node]}
+		if metric.IsDistance {
+			distance := candidate.Distance
+			neighbor.Distance = &distance
+		} else {
+			similarity := 1 - candidate.Distance
+			neighbor.Similarity = &similarity
+		}
+		neighbors = append(neighbors, neighbor)
+	}
+	return neighbors
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+// minANNRecall is the recall@10 the default HNSW parameters must reach on
+// overlapping gaussian clusters. They reach about 0.99.
+const minANNRecall = 0.95
+
+func TestANNRecallAgainstBruteForce(t *testing.T) {
+	const k, targets = 10, 200
+	params, err := parsePagedParams(httptest.NewRequest("GET", "/api/vectors?seed=3&size=5000&dimensions=64&clusters=12&spread=1&distribution=gaussian", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := datasetItems(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{metricCosine, metricEuclidean} {
+		t.Run(name, func(t *testing.T) {
+			metric, _ := metricByName(name)
+			dataset, err := annNeighborDataset(context.Background(), params, metric, defaultANNM)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Spread the targets over the dataset
+			found := 0
+			for i := 0; i < targets; i++ {
+				target := i * len(data) / targets
+				exact := make(map[string]bool, k)
+				for _, neighbor := range findNeighbors(data, nil, target, k, metric) {
+					exact[neighbor.ID] = true
+				}
+				for _, neighbor := range annNeighbors(dataset, target, k, defaultANNEf, metric) {
+					if exact[neighbor.ID] {
+						found++
+					}
+				}
+			}
+			recall := float64(found) / float64(k*targets)
+			t.Logf("recall@%d = %.4f", k, recall)
+			if recall < minANNRecall {
+				t.Errorf("recall@%d = %.4f, want at least %.2f", k, recall, minANNRecall)
+			}
+		})
+	}
+}
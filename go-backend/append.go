@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Append bodies carry full vectors, so they may be much larger than batch
+// lookups
+const maxAppendBodyBytes = 64 << 20
+
+// AppendResponse is the response structure for appends. Total is the size
+// of the loaded dataset after the append.
+type AppendResponse struct {
+	Added int `json:"added"`
+	Total int `json:"total"`
+}
+
+// appendLoaded adds items to the end of the loaded dataset after checking
+// that their vectors match the existing ones. It returns the new size.
+func appendLoaded(items []VectorItem) (int, error) {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+
+	// The new items must agree with each other, and then with an existing
+	// item on the dimensions and named vectors
+	if _, err := checkDimensions(items); err != nil {
+		return 0, err
+	}
+	if err := sameLayout(loadedDataset[0], items[0]); err != nil {
+		return 0, err
+	}
+
+	for i := range items {
+		if items[i].Metadata == nil {
+			items[i].Metadata = map[string]interface{}{}
+		}
+	}
+	loadedDataset = append(loadedDataset, items...)
+	return len(loadedDataset), nil
+}
+
+// sameLayout reports an error unless item has the same vector dimensions
+// and named vectors as existing
+func sameLayout(existing, item VectorItem) error {
+	if len(item.Vector) != len(existing.Vector) {
+		return fmt.Errorf("vectors have %d dimensions, expected %d", len(item.Vector), len(existing.Vector))
+	}
+	if len(item.Vectors) != len(existing.Vectors) {
+		return fmt.Errorf("items have %d named vectors, expected %d", len(item.Vectors), len(existing.Vectors))
+	}
+	for name, vector := range existing.Vectors {
+		other, ok := item.Vectors[name]
+		if !ok {
+			return fmt.Errorf("items have no %s vector", name)
+		}
+		if len(other) != len(vector) {
+			return fmt.Errorf("%s vectors have %d dimensions, expected %d", name, len(other), len(vector))
+		}
+	}
+	return nil
+}
+
+// handleAppendVectors adds the posted JSON array of items to the dataset
+// loaded with -data or -csv. It changes what every client is served, so it
+// takes the admin token. Synthetic data has nothing to append to.
+func handleAppendVectors(w http.ResponseWriter, r *http.Request) {
+	if !beginPOST(w, r) {
+		return
+	}
+	if !beginAdmin(w, r) {
+		return
+	}
+
+	if loadedItems() == nil {
+		writeError(w, http.StatusConflict, "appending requires a dataset loaded with -data or -csv")
+		return
+	}
+
+	var items []VectorItem
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAppendBodyBytes))
+	if err := decoder.Decode(&items); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if len(items) == 0 {
+		writeError(w, http.StatusBadRequest, "request body must be a non-empty array of vectors")
+		return
+	}
+	if len(items) > maxLimit {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("an append must not exceed %d vectors", maxLimit))
+		return
+	}
+
+	total, err := appendLoaded(items)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Cached responses and indexes describe the dataset before the append
+	if vectorCache != nil {
+		vectorCache.Clear()
+	}
+	annIndexes.Clear()
+	normDatasets.Clear()
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := AppendResponse{
+		Added: len(items),
+		Total: total,
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAppendRequiresAdminToken(t *testing.T) {
+	defer func(token string) { adminToken = token }(adminToken)
+
+	tests := []struct {
+		token         string
+		authorization string
+		want          int
+	}{
+		{"", "Bearer secret", http.StatusForbidden},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "secret", http.StatusUnauthorized},
+		// With the token the request gets as far as finding no dataset
+		{"secret", "Bearer secret", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		adminToken = tt.token
+		r := httptest.NewRequest("POST", "/api/vectors", strings.NewReader(`[{"id":"x","vector":[1]}]`))
+		if tt.authorization != "" {
+			r.Header.Set("Authorization", tt.authorization)
+		}
+		w := httptest.NewRecorder()
+		handleAppendVectors(w, r)
+		if w.Code != tt.want {
+			t.Errorf("token %q, Authorization %q: status %d, want %d", tt.token, tt.authorization, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+// ParamAdjustment records a query parameter the server didn't use as
+// given: Requested is the raw value and Applied what was used instead
+type ParamAdjustment struct {
+	Param     string      `json:"param"`
+	Requested string      `json:"requested"`
+	Applied   interface{} `json:"applied"`
+	Reason    string      `json:"reason"`
+}
+
+// AppliedParams echoes the effective page and generation parameters of a
+// vector data response. Seed is the seed used even when the request didn't
+// give one and is omitted for loaded datasets; Adjustments lists every
+// parameter that was defaulted or clamped rather than applied as given.
+type AppliedParams struct {
+	Limit       int               `json:"limit"`
+	Offset      int               `json:"offset"`
+	Size        int               `json:"size"`
+	Dimensions  int               `json:"dimensions"`
+	Seed        *int64            `json:"seed,omitempty"`
+	Seeded      bool              `json:"seeded"`
+	Adjustments []ParamAdjustment `json:"adjustments"`
+}
+
+// adjust records that param was requested as requested but applied as
+// applied
+func (p *vectorParams) adjust(param, requested string, applied interface{}, reason string) {
+	p.Adjustments = append(p.Adjustments, ParamAdjustment{
+		Param:     param,
+		Requested: requested,
+		Applied:   applied,
+		Reason:    reason,
+	})
+}
+
+// appliedParams returns the echo of params for a response whose page
+// holds at most limit items
+func appliedParams(params vectorParams, limit int) *AppliedParams {
+	applied := &AppliedParams{
+		Limit:       limit,
+		Offset:      params.Offset,
+		Size:        params.Size,
+		Dimensions:  params.vectorDimensions(),
+		Seeded:      params.Seeded,
+		Adjustments: append([]ParamAdjustment{}, params.Adjustments...),
+	}
+	if loaded := loadedItems(); loaded != nil {
+		applied.Size = len(loaded)
+	} else {
+		seed := params.Seed
+		applied.Seed = &seed
+	}
+	return applied
+}
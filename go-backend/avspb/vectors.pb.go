@@ -0,0 +1,469 @@
+// gRPC interface to the vector generator, served with -grpc-port. The
+// messages in avspb/vectors.pb.go are generated from this file with
+// protoc-gen-go; regenerate them after changing it:
+//
+//   protoc --go_out=. --go_opt=module=github.com/hev/avs-visualizer/go-backend vectors.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: vectors.proto
+
+package avspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// VectorsRequest takes the /api/vectors query parameters. Zero values
+// leave a parameter at its default.
+type VectorsRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Limit      int32                  `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset     int32                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Size       int32                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Dimensions int32                  `protobuf:"varint,4,opt,name=dimensions,proto3" json:"dimensions,omitempty"`
+	Clusters   int32                  `protobuf:"varint,5,opt,name=clusters,proto3" json:"clusters,omitempty"`
+	Seed       *int64                 `protobuf:"varint,6,opt,name=seed,proto3,oneof" json:"seed,omitempty"`
+	// Any other /api/vectors parameter, such as spread, filter or sort.
+	// Repeated parameters like filter are listed once per value.
+	Params        []*Param `protobuf:"bytes,7,rep,name=params,proto3" json:"params,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VectorsRequest) Reset() {
+	*x = VectorsRequest{}
+	mi := &file_vectors_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VectorsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VectorsRequest) ProtoMessage() {}
+
+func (x *VectorsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vectors_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VectorsRequest.ProtoReflect.Descriptor instead.
+func (*VectorsRequest) Descriptor() ([]byte, []int) {
+	return file_vectors_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *VectorsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *VectorsRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *VectorsRequest) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *VectorsRequest) GetDimensions() int32 {
+	if x != nil {
+		return x.Dimensions
+	}
+	return 0
+}
+
+func (x *VectorsRequest) GetClusters() int32 {
+	if x != nil {
+		return x.Clusters
+	}
+	return 0
+}
+
+func (x *VectorsRequest) GetSeed() int64 {
+	if x != nil && x.Seed != nil {
+		return *x.Seed
+	}
+	return 0
+}
+
+func (x *VectorsRequest) GetParams() []*Param {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+type Param struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Param) Reset() {
+	*x = Param{}
+	mi := &file_vectors_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Param) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Param) ProtoMessage() {}
+
+func (x *Param) ProtoReflect() protoreflect.Message {
+	mi := &file_vectors_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Param.ProtoReflect.Descriptor instead.
+func (*Param) Descriptor() ([]byte, []int) {
+	return file_vectors_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Param) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Param) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+type VectorsResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Data       []*VectorItem          `protobuf:"bytes,1,rep,name=data,proto3" json:"data,omitempty"`
+	Total      int32                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Offset     int32                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	Limit      int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	NextOffset *int32                 `protobuf:"varint,5,opt,name=next_offset,json=nextOffset,proto3,oneof" json:"next_offset,omitempty"`
+	// The seed used, even when the request didn't give one; zero for
+	// loaded datasets
+	Seed          int64 `protobuf:"varint,6,opt,name=seed,proto3" json:"seed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VectorsResponse) Reset() {
+	*x = VectorsResponse{}
+	mi := &file_vectors_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VectorsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VectorsResponse) ProtoMessage() {}
+
+func (x *VectorsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vectors_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VectorsResponse.ProtoReflect.Descriptor instead.
+func (*VectorsResponse) Descriptor() ([]byte, []int) {
+	return file_vectors_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *VectorsResponse) GetData() []*VectorItem {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *VectorsResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *VectorsResponse) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *VectorsResponse) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *VectorsResponse) GetNextOffset() int32 {
+	if x != nil && x.NextOffset != nil {
+		return *x.NextOffset
+	}
+	return 0
+}
+
+func (x *VectorsResponse) GetSeed() int64 {
+	if x != nil {
+		return x.Seed
+	}
+	return 0
+}
+
+type VectorItem struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Id                string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Key               string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Vector            []float64              `protobuf:"fixed64,3,rep,packed,name=vector,proto3" json:"vector,omitempty"`
+	Metadata          *structpb.Struct       `protobuf:"bytes,4,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Clusters          []string               `protobuf:"bytes,5,rep,name=clusters,proto3" json:"clusters,omitempty"`
+	NearestCluster    string                 `protobuf:"bytes,6,opt,name=nearest_cluster,json=nearestCluster,proto3" json:"nearest_cluster,omitempty"`
+	QuantizedVector   []int32                `protobuf:"zigzag32,7,rep,packed,name=quantized_vector,json=quantizedVector,proto3" json:"quantized_vector,omitempty"`
+	QuantizationScale *float64               `protobuf:"fixed64,8,opt,name=quantization_scale,json=quantizationScale,proto3,oneof" json:"quantization_scale,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *VectorItem) Reset() {
+	*x = VectorItem{}
+	mi := &file_vectors_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VectorItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VectorItem) ProtoMessage() {}
+
+func (x *VectorItem) ProtoReflect() protoreflect.Message {
+	mi := &file_vectors_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VectorItem.ProtoReflect.Descriptor instead.
+func (*VectorItem) Descriptor() ([]byte, []int) {
+	return file_vectors_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *VectorItem) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *VectorItem) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *VectorItem) GetVector() []float64 {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
+func (x *VectorItem) GetMetadata() *structpb.Struct {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *VectorItem) GetClusters() []string {
+	if x != nil {
+		return x.Clusters
+	}
+	return nil
+}
+
+func (x *VectorItem) GetNearestCluster() string {
+	if x != nil {
+		return x.NearestCluster
+	}
+	return ""
+}
+
+func (x *VectorItem) GetQuantizedVector() []int32 {
+	if x != nil {
+		return x.QuantizedVector
+	}
+	return nil
+}
+
+func (x *VectorItem) GetQuantizationScale() float64 {
+	if x != nil && x.QuantizationScale != nil {
+		return *x.QuantizationScale
+	}
+	return 0
+}
+
+var File_vectors_proto protoreflect.FileDescriptor
+
+const file_vectors_proto_rawDesc = "" +
+	"\n" +
+	"\rvectors.proto\x12\x06avs.v1\x1a\x1cgoogle/protobuf/struct.proto\"\xd7\x01\n" +
+	"\x0eVectorsRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x05R\x06offset\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x05R\x04size\x12\x1e\n" +
+	"\n" +
+	"dimensions\x18\x04 \x01(\x05R\n" +
+	"dimensions\x12\x1a\n" +
+	"\bclusters\x18\x05 \x01(\x05R\bclusters\x12\x17\n" +
+	"\x04seed\x18\x06 \x01(\x03H\x00R\x04seed\x88\x01\x01\x12%\n" +
+	"\x06params\x18\a \x03(\v2\r.avs.v1.ParamR\x06paramsB\a\n" +
+	"\x05_seed\"1\n" +
+	"\x05Param\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"\xc7\x01\n" +
+	"\x0fVectorsResponse\x12&\n" +
+	"\x04data\x18\x01 \x03(\v2\x12.avs.v1.VectorItemR\x04data\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x05R\x06offset\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12$\n" +
+	"\vnext_offset\x18\x05 \x01(\x05H\x00R\n" +
+	"nextOffset\x88\x01\x01\x12\x12\n" +
+	"\x04seed\x18\x06 \x01(\x03R\x04seedB\x0e\n" +
+	"\f_next_offset\"\xb6\x02\n" +
+	"\n" +
+	"VectorItem\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\tR\x03key\x12\x16\n" +
+	"\x06vector\x18\x03 \x03(\x01R\x06vector\x123\n" +
+	"\bmetadata\x18\x04 \x01(\v2\x17.google.protobuf.StructR\bmetadata\x12\x1a\n" +
+	"\bclusters\x18\x05 \x03(\tR\bclusters\x12'\n" +
+	"\x0fnearest_cluster\x18\x06 \x01(\tR\x0enearestCluster\x12)\n" +
+	"\x10quantized_vector\x18\a \x03(\x11R\x0fquantizedVector\x122\n" +
+	"\x12quantization_scale\x18\b \x01(\x01H\x00R\x11quantizationScale\x88\x01\x01B\x15\n" +
+	"\x13_quantization_scale2\x8d\x01\n" +
+	"\rVectorService\x12=\n" +
+	"\n" +
+	"GetVectors\x12\x16.avs.v1.VectorsRequest\x1a\x17.avs.v1.VectorsResponse\x12=\n" +
+	"\rStreamVectors\x12\x16.avs.v1.VectorsRequest\x1a\x12.avs.v1.VectorItem0\x01B0Z.github.com/hev/avs-visualizer/go-backend/avspbb\x06proto3"
+
+var (
+	file_vectors_proto_rawDescOnce sync.Once
+	file_vectors_proto_rawDescData []byte
+)
+
+func file_vectors_proto_rawDescGZIP() []byte {
+	file_vectors_proto_rawDescOnce.Do(func() {
+		file_vectors_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_vectors_proto_rawDesc), len(file_vectors_proto_rawDesc)))
+	})
+	return file_vectors_proto_rawDescData
+}
+
+var file_vectors_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_vectors_proto_goTypes = []any{
+	(*VectorsRequest)(nil),  // 0: avs.v1.VectorsRequest
+	(*Param)(nil),           // 1: avs.v1.Param
+	(*VectorsResponse)(nil), // 2: avs.v1.VectorsResponse
+	(*VectorItem)(nil),      // 3: avs.v1.VectorItem
+	(*structpb.Struct)(nil), // 4: google.protobuf.Struct
+}
+var file_vectors_proto_depIdxs = []int32{
+	1, // 0: avs.v1.VectorsRequest.params:type_name -> avs.v1.Param
+	3, // 1: avs.v1.VectorsResponse.data:type_name -> avs.v1.VectorItem
+	4, // 2: avs.v1.VectorItem.metadata:type_name -> google.protobuf.Struct
+	0, // 3: avs.v1.VectorService.GetVectors:input_type -> avs.v1.VectorsRequest
+	0, // 4: avs.v1.VectorService.StreamVectors:input_type -> avs.v1.VectorsRequest
+	2, // 5: avs.v1.VectorService.GetVectors:output_type -> avs.v1.VectorsResponse
+	3, // 6: avs.v1.VectorService.StreamVectors:output_type -> avs.v1.VectorItem
+	5, // [5:7] is the sub-list for method output_type
+	3, // [3:5] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_vectors_proto_init() }
+func file_vectors_proto_init() {
+	if File_vectors_proto != nil {
+		return
+	}
+	file_vectors_proto_msgTypes[0].OneofWrappers = []any{}
+	file_vectors_proto_msgTypes[2].OneofWrappers = []any{}
+	file_vectors_proto_msgTypes[3].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_vectors_proto_rawDesc), len(file_vectors_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_vectors_proto_goTypes,
+		DependencyIndexes: file_vectors_proto_depIdxs,
+		MessageInfos:      file_vectors_proto_msgTypes,
+	}.Build()
+	File_vectors_proto = out.File
+	file_vectors_proto_goTypes = nil
+	file_vectors_proto_depIdxs = nil
+}
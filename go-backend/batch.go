@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Batch request bodies are small lists of IDs
+const maxBatchBodyBytes = 1 << 20
+
+// BatchRequest is the request body for batch lookups
+type BatchRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// BatchResponse is the response structure for batch lookups. Data holds
+// the found items in request order and Missing the IDs that matched nothing.
+type BatchResponse struct {
+	Data    []VectorItem `json:"data"`
+	Missing []string     `json:"missing"`
+	Total   int          `json:"total"`
+}
+
+func handleBatch(w http.ResponseWriter, r *http.Request) {
+	if !beginPOST(w, r) {
+		return
+	}
+
+	// Generation parameters come from the query string, as for GET requests,
+	// so IDs resolve in the same logical dataset as the item endpoint
+	params, err := parsePagedParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	output, err := parseOutputOptions(r.URL.Query(), params.vectorDimensions())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Unseeded synthetic data changes on every request, so its IDs can't
+	// be looked up again
+	if loadedItems() == nil && !params.Seeded {
+		writeError(w, http.StatusBadRequest, "seed is required to look up generated vectors")
+		return
+	}
+
+	var request BatchRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes))
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if len(request.IDs) > maxLimit {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ids must not exceed %d entries", maxLimit))
+		return
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	index := make(map[string]int, len(data))
+	for i, item := range data {
+		if _, ok := index[item.ID]; !ok {
+			index[item.ID] = i
+		}
+	}
+
+	found := make([]VectorItem, 0, len(request.IDs))
+	missing := []string{}
+	for _, id := range request.IDs {
+		i, ok := index[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		found = append(found, output.Apply(data[i]))
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := BatchResponse{
+		Data:    found,
+		Missing: missing,
+		Total:   len(found),
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatchResolvesIDsLikeItemLookup(t *testing.T) {
+	// 750 is past the first page but inside the default logical dataset
+	r := httptest.NewRequest("POST", "/api/vectors/batch?seed=1&dimensions=3", strings.NewReader(`{"ids":["750","3"]}`))
+	w := httptest.NewRecorder()
+	handleBatch(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var response BatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Missing) != 0 || len(response.Data) != 2 {
+		t.Errorf("found %d items, missing %v; want both", len(response.Data), response.Missing)
+	}
+
+	item := httptest.NewRecorder()
+	get := httptest.NewRequest("GET", "/api/vectors/750?seed=1&dimensions=3", nil)
+	get.SetPathValue("id", "750")
+	handleVectorByID(item, get)
+	var want VectorItem
+	if err := json.Unmarshal(item.Body.Bytes(), &want); err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range response.Data {
+		if got.ID == "750" && got.Key != want.Key {
+			t.Errorf("batch item 750 has key %q, item lookup %q", got.Key, want.Key)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// BoundsResponse is the response structure for the bounding box of a
+// dataset. Min and Max hold the range of each of the first Axes
+// dimensions over the Count items that passed the filters; both are null
+// when none did.
+type BoundsResponse struct {
+	Min   []float64 `json:"min"`
+	Max   []float64 `json:"max"`
+	Axes  int       `json:"axes"`
+	Count int       `json:"count"`
+}
+
+// handleBounds returns the per-dimension range of the dataset, so a plot
+// can size its axes before fetching any points. The dataset is generated
+// in a single pass without being held in memory.
+func handleBounds(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters, err := parseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// dimensions already sets the generated vectors' length, so axes picks
+	// how many of them to bound
+	axes := params.vectorDimensions() // Default
+	if axesStr := r.URL.Query().Get("axes"); axesStr != "" {
+		parsedAxes, err := strconv.Atoi(axesStr)
+		if err != nil || parsedAxes <= 0 {
+			writeError(w, http.StatusBadRequest, "axes must be a positive integer")
+			return
+		}
+		axes = min(parsedAxes, axes)
+	}
+
+	// Generate data
+	response := BoundsResponse{Axes: axes}
+	next := datasetIterator(params)
+	for {
+		if err := r.Context().Err(); err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		item, ok := next()
+		if !ok {
+			break
+		}
+		if !matchesFilters(item, filters) {
+			continue
+		}
+
+		response.Count++
+		if response.Min == nil {
+			response.Min = append([]float64(nil), item.Vector[:axes]...)
+			response.Max = append([]float64(nil), item.Vector[:axes]...)
+			continue
+		}
+		for j, x := range item.Vector[:axes] {
+			response.Min[j] = min(response.Min[j], x)
+			response.Max[j] = max(response.Max[j], x)
+		}
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(response)
+}
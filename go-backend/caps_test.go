@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// oversizedQuery asks for a dataset that takes seconds to generate and is
+// over every analysis endpoint's item cap
+const oversizedQuery = "?limit=100000&dimensions=1000&seed=1"
+
+// cappedEndpoint is an analysis endpoint with an item cap, any query
+// parameters that select the capped work, and a valid body for it if it
+// takes one
+type cappedEndpoint struct {
+	Handler http.HandlerFunc
+	Query   string
+	Body    string
+}
+
+// cappedEndpoints lists the analysis endpoints with an item cap, by test
+// name
+var cappedEndpoints = map[string]cappedEndpoint{
+	"/api/vectors/tsne":              {Handler: handleTSNE},
+	"/api/vectors/umap":              {Handler: handleUMAP},
+	"/api/vectors/dbscan":            {Handler: handleDBSCAN},
+	"/api/vectors/hierarchical":      {Handler: handleHierarchical},
+	"/api/vectors/graph":             {Handler: handleGraph},
+	"/api/vectors/pipeline tsne":     {Handler: handlePipeline, Query: "&reduce=tsne"},
+	"/api/vectors/pipeline dbscan":   {Handler: handlePipeline, Query: "&cluster=dbscan&eps=1"},
+	"/api/vectors/label-propagation": {Handler: handleLabelPropagation, Body: `{"0":"a"}`},
+}
+
+func TestCapsCheckedBeforeGenerating(t *testing.T) {
+	for name, endpoint := range cappedEndpoints {
+		t.Run(name, func(t *testing.T) {
+			target, _, _ := strings.Cut(name, " ")
+			target += oversizedQuery + endpoint.Query
+			r := httptest.NewRequest("GET", target, nil)
+			if endpoint.Body != "" {
+				r = httptest.NewRequest("POST", target, strings.NewReader(endpoint.Body))
+			}
+
+			start := time.Now()
+			w := httptest.NewRecorder()
+			endpoint.Handler(w, r)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "limited to") {
+				t.Errorf("status %d, want 400 for the item cap: %s", w.Code, w.Body)
+			}
+			if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+				t.Errorf("refusing took %v; the dataset was generated first", elapsed)
+			}
+		})
+	}
+}
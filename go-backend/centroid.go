@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Centroid bodies are lists of IDs, like batch lookups
+const maxCentroidBodyBytes = maxBatchBodyBytes
+
+// CentroidRequest is the optional request body for centroids. When IDs is
+// omitted every item matching the query filters contributes.
+type CentroidRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// CentroidResponse is the response structure for centroids. Count is the
+// number of vectors averaged and Missing the requested IDs that matched
+// nothing.
+type CentroidResponse struct {
+	Centroid []float64 `json:"centroid"`
+	Count    int       `json:"count"`
+	Missing  []string  `json:"missing,omitempty"`
+}
+
+// handleCentroid returns the mean vector of a selection: the posted IDs,
+// or the items matching the query filters. Filters also narrow a list of
+// IDs.
+func handleCentroid(w http.ResponseWriter, r *http.Request) {
+	if !beginPOST(w, r) {
+		return
+	}
+
+	// Generation parameters come from the query string, as for GET requests,
+	// so IDs resolve in the same logical dataset as the item endpoint
+	params, err := parsePagedParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters, err := parseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var request CentroidRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCentroidBodyBytes))
+	if err := decoder.Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	// selected tracks which of the requested IDs were found
+	var selected map[string]bool
+	if request.IDs != nil {
+		// Unseeded synthetic data changes on every request, so its IDs
+		// can't be looked up again
+		if loadedItems() == nil && !params.Seeded {
+			writeError(w, http.StatusBadRequest, "seed is required to look up generated vectors")
+			return
+		}
+		selected = make(map[string]bool, len(request.IDs))
+		for _, id := range request.IDs {
+			selected[id] = false
+		}
+	}
+
+	// Generate data, summing the selected vectors as they are produced
+	var sum []float64
+	count := 0
+	next := datasetIterator(params)
+	for {
+		if err := r.Context().Err(); err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		item, ok := next()
+		if !ok {
+			break
+		}
+		if selected != nil {
+			if _, ok := selected[item.ID]; !ok {
+				continue
+			}
+			selected[item.ID] = true
+		}
+		if !matchesFilters(item, filters) {
+			continue
+		}
+
+		if sum == nil {
+			sum = make([]float64, len(item.Vector))
+		}
+		for j, x := range item.Vector {
+			sum[j] += x
+		}
+		count++
+	}
+
+	var missing []string
+	for _, id := range request.IDs {
+		if !selected[id] {
+			missing = append(missing, id)
+			selected[id] = true // Report duplicates once
+		}
+	}
+
+	if count == 0 {
+		writeError(w, http.StatusBadRequest, "the selection contains no vectors")
+		return
+	}
+	for j := range sum {
+		sum[j] /= float64(count)
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := CentroidResponse{
+		Centroid: sum,
+		Count:    count,
+		Missing:  missing,
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCentroidResolvesIDsLikeItemLookup(t *testing.T) {
+	// 750 is past the first page but inside the default logical dataset
+	r := httptest.NewRequest("POST", "/api/vectors/centroid?seed=1&dimensions=3", strings.NewReader(`{"ids":["750"]}`))
+	w := httptest.NewRecorder()
+	handleCentroid(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var response CentroidResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	item := httptest.NewRecorder()
+	get := httptest.NewRequest("GET", "/api/vectors/750?seed=1&dimensions=3", nil)
+	get.SetPathValue("id", "750")
+	handleVectorByID(item, get)
+	var want VectorItem
+	if err := json.Unmarshal(item.Body.Bytes(), &want); err != nil {
+		t.Fatal(err)
+	}
+	if response.Count != 1 || !reflect.DeepEqual(response.Centroid, want.Vector) {
+		t.Errorf("centroid of 750 is %v over %d vectors, want its vector %v", response.Centroid, response.Count, want.Vector)
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+)
+
+// Silhouette compares every pair of points, so larger inputs are scored on
+// a uniform sample of this many points
+const maxSilhouetteItems = 2000
+
+// Sources of the labels being evaluated
+const (
+	labelsKMeans      = "kmeans"      // A k-means run on the data
+	labelsClusters    = "clusters"    // Each item's primary generated cluster
+	labelsAssignments = "assignments" // Labels posted by the client
+)
+
+// Assignment bodies hold one small integer per item
+const maxAssignmentBodyBytes = 16 << 20
+
+// ClusterMetricsRequest is the request body for evaluating a clustering
+// computed by the client. Assignments holds one label per item in dataset
+// order.
+type ClusterMetricsRequest struct {
+	Assignments []int `json:"assignments"`
+}
+
+// ClusterMetricsResponse is the response structure for cluster quality
+// metrics. Silhouette and DaviesBouldin need at least two clusters and are
+// null otherwise. SilhouetteItems is the number of points the silhouette
+// was computed on, at most maxSilhouetteItems.
+type ClusterMetricsResponse struct {
+	Labels          string   `json:"labels"`
+	Metric          string   `json:"metric"`
+	Clusters        int      `json:"clusters"`
+	Items           int      `json:"items"`
+	Inertia         float64  `json:"inertia"`
+	Silhouette      *float64 `json:"silhouette"`
+	SilhouetteItems int      `json:"silhouette_items"`
+	DaviesBouldin   *float64 `json:"davies_bouldin"`
+}
+
+// compactLabels renumbers labels to 0..k-1 in order of first appearance
+// and returns k, so clusters nobody was assigned to don't count
+func compactLabels(labels []int) ([]int, int) {
+	ids := make(map[int]int)
+	compact := make([]int, len(labels))
+	for i, label := range labels {
+		id, ok := ids[label]
+		if !ok {
+			id = len(ids)
+			ids[label] = id
+		}
+		compact[i] = id
+	}
+	return compact, len(ids)
+}
+
+// clusterCentroids returns the mean of each of the k clusters
+func clusterCentroids(vectors [][]float64, labels []int, k int) [][]float64 {
+	centroids := make([][]float64, k)
+	counts := make([]int, k)
+	for i, v := range vectors {
+		c := labels[i]
+		if centroids[c] == nil {
+			centroids[c] = make([]float64, len(v))
+		}
+		for j, x := range v {
+			centroids[c][j] += x
+		}
+		counts[c]++
+	}
+	for c := range centroids {
+		for j := range centroids[c] {
+			centroids[c][j] /= float64(counts[c])
+		}
+	}
+	return centroids
+}
+
+// clusterInertia returns the sum of squared distances from each point to
+// its cluster's centroid
+func clusterInertia(vectors [][]float64, labels []int, centroids [][]float64, distance func(a, b []float64) float64) float64 {
+	inertia := 0.0
+	for i, v := range vectors {
+		d := distance(v, centroids[labels[i]])
+		inertia += d * d
+	}
+	return inertia
+}
+
+// daviesBouldin returns the Davies-Bouldin index: the mean over clusters of
+// the worst ratio of within-cluster scatter to centroid separation. Lower
+// is better.
+func daviesBouldin(vectors [][]float64, labels []int, centroids [][]float64, distance func(a, b []float64) float64) float64 {
+	k := len(centroids)
+	scatter := make([]float64, k)
+	counts := make([]int, k)
+	for i, v := range vectors {
+		scatter[labels[i]] += distance(v, centroids[labels[i]])
+		counts[labels[i]]++
+	}
+	for c := range scatter {
+		scatter[c] /= float64(counts[c])
+	}
+
+	total := 0.0
+	for a := 0; a < k; a++ {
+		worst := 0.0
+		for b := 0; b < k; b++ {
+			if a == b {
+				continue
+			}
+			separation := distance(centroids[a], centroids[b])
+			ratio := math.Inf(1)
+			if separation > 0 {
+				ratio = (scatter[a] + scatter[b]) / separation
+			} else if scatter[a]+scatter[b] == 0 {
+				ratio = 0
+			}
+			worst = math.Max(worst, ratio)
+		}
+		total += worst
+	}
+	return total / float64(k)
+}
+
+// silhouette returns the mean silhouette coefficient of the points, from
+// -1 (misassigned) to 1 (well separated). Points alone in their cluster
+// score 0. It gives up with the context's error if ctx ends first.
+func silhouette(ctx context.Context, vectors [][]float64, labels []int, k int, distance func(a, b []float64) float64) (float64, error) {
+	counts := make([]int, k)
+	for _, label := range labels {
+		counts[label]++
+	}
+
+	total := 0.0
+	sums := make([]float64, k)
+	for i, v := range vectors {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		own := labels[i]
+		if counts[own] == 1 {
+			continue
+		}
+
+		for c := range sums {
+			sums[c] = 0
+		}
+		for j, u := range vectors {
+			if i != j {
+				sums[labels[j]] += distance(v, u)
+			}
+		}
+
+		a := sums[own] / float64(counts[own]-1)
+		b := math.Inf(1)
+		for c, sum := range sums {
+			if c != own && counts[c] > 0 {
+				b = math.Min(b, sum/float64(counts[c]))
+			}
+		}
+		if math.IsInf(b, 1) {
+			// No other cluster made it into the sample
+			continue
+		}
+		if scale := math.Max(a, b); scale > 0 {
+			total += (b - a) / scale
+		}
+	}
+	return total / float64(len(vectors)), nil
+}
+
+// sampleIndices returns n distinct indices below total in increasing
+// order, chosen uniformly with rng
+func sampleIndices(rng *rand.Rand, total, n int) []int {
+	picked := make([]bool, total)
+	for _, i := range rng.Perm(total)[:n] {
+		picked[i] = true
+	}
+	indices := make([]int, 0, n)
+	for i, ok := range picked {
+		if ok {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// handleClusterMetrics scores a clustering of the dataset. GET evaluates a
+// k-means run or, with labels=clusters, the generated primary clusters;
+// POST evaluates the client's own assignments.
+func handleClusterMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	metric, err := parseMetric(r.URL.Query(), metricEuclidean)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	distance, ok := metricDistance(metric)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "cluster metrics need a distance; use metric cosine or euclidean")
+		return
+	}
+
+	source := r.URL.Query().Get("labels")
+	if source == "" {
+		source = labelsKMeans // Default
+	}
+	if r.Method == "POST" {
+		source = labelsAssignments
+	} else if source != labelsKMeans && source != labelsClusters {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("labels must be %q or %q", labelsKMeans, labelsClusters))
+		return
+	}
+
+	k := 5 // Default
+	if kStr := r.URL.Query().Get("k"); kStr != "" {
+		parsedK, err := strconv.Atoi(kStr)
+		if err != nil || parsedK <= 0 || parsedK > maxKMeansK {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("k must be an integer between 1 and %d", maxKMeansK))
+			return
+		}
+		k = parsedK
+	}
+
+	var request ClusterMetricsRequest
+	if source == labelsAssignments {
+		// Unseeded synthetic data changes on every request, so labels
+		// computed from an earlier response wouldn't fit it
+		if loadedItems() == nil && !params.Seeded {
+			writeError(w, http.StatusBadRequest, "seed is required to evaluate assignments of generated vectors")
+			return
+		}
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAssignmentBodyBytes))
+		if err := decoder.Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	var labels []int
+	switch source {
+	case labelsKMeans:
+		if k > len(data) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("k must not exceed the number of vectors (%d)", len(data)))
+			return
+		}
+		// Seeded like /api/vectors/kmeans, so both report the same clustering
+		result, err := kMeans(r.Context(), vectors, k, defaultKMeansMaxIterations, params.Rand())
+		if err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		labels = result.Assignments
+	case labelsClusters:
+		names := make(map[string]int)
+		labels = make([]int, len(data))
+		for i, item := range data {
+			if len(item.Clusters) == 0 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("vector %q has no cluster", item.ID))
+				return
+			}
+			id, ok := names[item.Clusters[0]]
+			if !ok {
+				id = len(names)
+				names[item.Clusters[0]] = id
+			}
+			labels[i] = id
+		}
+	case labelsAssignments:
+		if len(request.Assignments) != len(data) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("assignments must have one label per vector (%d)", len(data)))
+			return
+		}
+		labels = request.Assignments
+	}
+	labels, clusters := compactLabels(labels)
+
+	centroids := clusterCentroids(vectors, labels, clusters)
+	response := ClusterMetricsResponse{
+		Labels:          source,
+		Metric:          metric.Name,
+		Clusters:        clusters,
+		Items:           len(data),
+		Inertia:         clusterInertia(vectors, labels, centroids, distance),
+		SilhouetteItems: len(data),
+	}
+
+	if clusters > 1 {
+		index := daviesBouldin(vectors, labels, centroids, distance)
+		response.DaviesBouldin = &index
+
+		// Score a seeded sample when the pairwise cost is too high
+		sampleVectors, sampleLabels := vectors, labels
+		if len(data) > maxSilhouetteItems {
+			indices := sampleIndices(params.sampleRand(), len(data), maxSilhouetteItems)
+			sampleVectors = make([][]float64, len(indices))
+			sampleLabels = make([]int, len(indices))
+			for n, i := range indices {
+				sampleVectors[n] = vectors[i]
+				sampleLabels[n] = labels[i]
+			}
+			response.SilhouetteItems = len(indices)
+		}
+		score, err := silhouette(r.Context(), sampleVectors, sampleLabels, clusters, distance)
+		if err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		response.Silhouette = &score
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(response)
+}
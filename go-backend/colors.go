@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Saturation and lightness of cluster colors. Only the hue varies, so
+// every cluster is equally readable on the plot background.
+const (
+	clusterSaturation = 0.65
+	clusterLightness  = 0.55
+)
+
+// clusterColor returns a hex color for a cluster, derived only from its
+// name so the same cluster has the same color in every dataset. Names
+// like "Group A" and "Group B" differ in one byte, so the hash must mix
+// well for their hues to land apart.
+func clusterColor(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	hue := float64(binary.BigEndian.Uint32(sum[:4]) % 360)
+	return hslToHex(hue, clusterSaturation, clusterLightness)
+}
+
+// hslToHex converts a hue in degrees and saturation and lightness in
+// [0, 1] to a #rrggbb color
+func hslToHex(hue, saturation, lightness float64) string {
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	m := lightness - chroma/2
+
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = chroma, x, 0
+	case hue < 120:
+		r, g, b = x, chroma, 0
+	case hue < 180:
+		r, g, b = 0, chroma, x
+	case hue < 240:
+		r, g, b = 0, x, chroma
+	case hue < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+
+	channel := func(v float64) int { return int(math.Round((v + m) * 255)) }
+	return fmt.Sprintf("#%02x%02x%02x", channel(r), channel(g), channel(b))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// statusClientClosedRequest is the non-standard status nginx logs when the
+// client disconnects before the response is ready. Nobody receives it, but
+// it keeps abandoned requests apart from failures in logs and metrics.
+const statusClientClosedRequest = 499
+
+// writeWorkError answers a request whose work failed with err: 503 when
+// the request timed out, 499 when the client went away, and 500 otherwise
+func writeWorkError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusServiceUnavailable, "request timed out")
+	case errors.Is(err, context.Canceled):
+		writeError(w, statusClientClosedRequest, "request cancelled")
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// timeoutMiddleware gives every request a deadline. Handlers notice it
+// through the request context. Live streams under /ws/ are meant to stay
+// open and are left alone.
+func timeoutMiddleware(timeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/ws/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		timed := r.WithContext(ctx)
+		next.ServeHTTP(w, timed)
+
+		// The mux records the matched route on the request it was given;
+		// copy it back so the metrics middleware can label by route
+		r.Pattern = timed.Pattern
+	})
+}
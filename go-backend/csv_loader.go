@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadCSVDataset reads vectors from a CSV file with a header row. The
+// idColumn becomes the item ID, numeric columns form the vector in header
+// order, and the remaining columns are kept as string metadata. Numeric
+// columns named like image_embedding[0] form the named vector
+// image_embedding instead of the primary one. Columns are
+// classified using the first well-formed row; later rows that don't fit are
+// skipped and logged. Mismatched vectors are fitted according to strategy.
+func loadCSVDataset(path, idColumn, strategy string) ([]VectorItem, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // Checked per row so bad rows can be skipped
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header of %s: %w", path, err)
+	}
+
+	idIndex := -1
+	for i, name := range header {
+		if name == idColumn {
+			idIndex = i
+			break
+		}
+	}
+	if idIndex < 0 {
+		return nil, fmt.Errorf("%s has no %q column", path, idColumn)
+	}
+
+	var (
+		items   []VectorItem
+		numeric []bool // Classification of each column, set from the first good row
+		skipped int
+	)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Parse errors already name the offending line
+			log.Printf("csv: skipping row: %v", err)
+			skipped++
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			log.Printf("csv: skipping line %d: expected %d fields, got %d", line, len(header), len(record))
+			skipped++
+			continue
+		}
+
+		if numeric == nil {
+			numeric = make([]bool, len(header))
+			for i, field := range record {
+				if i == idIndex {
+					continue
+				}
+				_, err := strconv.ParseFloat(field, 64)
+				numeric[i] = err == nil
+			}
+		}
+
+		item, err := parseCSVRecord(header, record, idIndex, numeric)
+		if err != nil {
+			log.Printf("csv: skipping line %d: %v", line, err)
+			skipped++
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s contains no valid rows", path)
+	}
+	if err := fitLoadedDimensions(items, path, strategy); err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		log.Printf("csv: skipped %d malformed rows in %s", skipped, path)
+	}
+	return items, nil
+}
+
+// parseCSVRecord turns one CSV row into a VectorItem
+func parseCSVRecord(header, record []string, idIndex int, numeric []bool) (VectorItem, error) {
+	item := VectorItem{
+		ID:       record[idIndex],
+		Key:      record[idIndex],
+		Metadata: make(map[string]interface{}),
+		Clusters: []string{},
+	}
+
+	for i, field := range record {
+		switch {
+		case i == idIndex:
+			continue
+		case numeric[i]:
+			value, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return VectorItem{}, fmt.Errorf("column %q: %q is not a number", header[i], field)
+			}
+			if name, ok := namedVectorColumn(header[i]); ok {
+				if item.Vectors == nil {
+					item.Vectors = make(map[string][]float64)
+				}
+				item.Vectors[name] = append(item.Vectors[name], value)
+				continue
+			}
+			item.Vector = append(item.Vector, value)
+		default:
+			item.Metadata[header[i]] = field
+		}
+	}
+
+	return item, nil
+}
+
+// namedVectorColumn returns the vector name of a column header of the form
+// name[index]
+func namedVectorColumn(column string) (string, bool) {
+	name, index, ok := strings.Cut(column, "[")
+	if !ok || name == "" || !strings.HasSuffix(index, "]") {
+		return "", false
+	}
+	if _, err := strconv.Atoi(strings.TrimSuffix(index, "]")); err != nil {
+		return "", false
+	}
+	return name, true
+}
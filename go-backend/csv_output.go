@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csvListSeparator joins the elements of list metadata such as tags into
+// one CSV field
+const csvListSeparator = "|"
+
+// wantsCSV reports whether the client asked for CSV, either with
+// ?format=csv or an Accept header
+func wantsCSV(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "csv"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+// csvMetadataKeys returns the metadata columns for a CSV response: the
+// requested fields, or else the schema's generated fields for generated
+// data and every key of the loaded data in sorted order
+func csvMetadataKeys(output outputOptions, params vectorParams) []string {
+	if output.Fields != nil {
+		return output.Fields
+	}
+	if loadedItems() == nil {
+		include := includedFields(metadataSchema, params.MetadataFields)
+		keys := make([]string, 0, len(metadataSchema))
+		for i, field := range metadataSchema {
+			if include == nil || include[i] {
+				keys = append(keys, field.Name)
+			}
+		}
+		return keys
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, item := range loadedItems() {
+		for key := range item.Metadata {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// csvHeader returns the column names: id, key, one column per vector
+// component, a scale column for quantized vectors, the metadata keys and
+// clusters. Metadata keys that clash with the other columns are prefixed
+// with "metadata_".
+func csvHeader(dimensions int, quantized bool, metadataKeys []string) []string {
+	header := []string{"id", "key"}
+	for i := 0; i < dimensions; i++ {
+		header = append(header, "v"+strconv.Itoa(i))
+	}
+	if quantized {
+		header = append(header, "scale")
+	}
+	reserved := make(map[string]bool, len(header)+1)
+	for _, name := range header {
+		reserved[name] = true
+	}
+	reserved["clusters"] = true
+
+	for _, key := range metadataKeys {
+		if reserved[key] {
+			key = "metadata_" + key
+		}
+		header = append(header, key)
+	}
+	return append(header, "clusters")
+}
+
+// csvValue formats one metadata value as a CSV field. Lists are joined
+// with csvListSeparator and anything else that isn't a scalar is written
+// as JSON.
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []string:
+		return strings.Join(v, csvListSeparator)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, element := range v {
+			parts[i] = fmt.Sprint(element)
+		}
+		return strings.Join(parts, csvListSeparator)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
+
+// streamCSV writes the requested page as CSV one row per item as it is
+// generated, flushing after each row like streamNDJSON. The columns are
+// fixed by the header, so vectors are padded or cut to its width. With
+// quantize the vector columns hold the quantized components.
+func streamCSV(ctx context.Context, w http.ResponseWriter, next func() (VectorItem, bool), params vectorParams, filters []itemFilter, output outputOptions) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+
+	quantized := output.Quantize != ""
+	dimensions := 0
+	if !output.OmitVectors || quantized {
+		dimensions = params.vectorDimensions()
+		if output.VectorDims > 0 {
+			dimensions = output.VectorDims
+		}
+	}
+	metadataKeys := csvMetadataKeys(output, params)
+
+	writer := csv.NewWriter(w)
+	writer.Write(csvHeader(dimensions, quantized, metadataKeys))
+
+	page := pageIterator(next, params, filters)
+	row := make([]string, 0, 4+dimensions+len(metadataKeys))
+	for written := 0; ; written++ {
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			// The client has gone away; nobody is left to tell
+			log.Printf("csv: stream aborted after %d rows: %v", written, err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if err := ctx.Err(); err != nil {
+			log.Printf("csv: stream stopped after %d rows: %v", written, err)
+			return
+		}
+
+		item, ok := page()
+		if !ok {
+			return
+		}
+		item = output.Apply(item)
+
+		row = append(row[:0], item.ID, item.Key)
+		for i := 0; i < dimensions; i++ {
+			switch {
+			case quantized && i < len(item.QuantizedVector):
+				row = append(row, strconv.Itoa(int(item.QuantizedVector[i])))
+			case !quantized && i < len(item.Vector):
+				row = append(row, strconv.FormatFloat(item.Vector[i], 'g', -1, 64))
+			default:
+				row = append(row, "")
+			}
+		}
+		if quantized {
+			scale := ""
+			if item.QuantizationScale != nil {
+				scale = strconv.FormatFloat(*item.QuantizationScale, 'g', -1, 64)
+			}
+			row = append(row, scale)
+		}
+		for _, key := range metadataKeys {
+			row = append(row, csvValue(item.Metadata[key]))
+		}
+		row = append(row, strings.Join(item.Clusters, csvListSeparator))
+		writer.Write(row)
+	}
+}
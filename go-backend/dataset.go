@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// loadedDataset holds the vectors loaded at startup with -data or -csv.
+// When it is nil the server generates synthetic data for every request.
+// Items may be appended at runtime, so handlers read it through
+// loadedItems.
+var loadedDataset []VectorItem
+
+// loadedMu guards loadedDataset. Appends never modify items already in
+// the slice, so a snapshot stays valid after the lock is released.
+var loadedMu sync.RWMutex
+
+// loadedItems returns a snapshot of the loaded dataset, or nil when
+// serving synthetic data
+func loadedItems() []VectorItem {
+	loadedMu.RLock()
+	defer loadedMu.RUnlock()
+	return loadedDataset
+}
+
+// datasetPath is the -data or -csv file the dataset was loaded from, or
+// empty when serving synthetic data
+var datasetPath string
+
+// How loaders treat vectors whose lengths disagree, set with -dim-strategy
+const (
+	dimStrategyStrict   = "strict"   // Refuse the dataset
+	dimStrategyPad      = "pad"      // Zero-pad short vectors to the longest
+	dimStrategyTruncate = "truncate" // Cut long vectors to the shortest
+)
+
+// checkDimStrategy returns an error for an unknown -dim-strategy value
+func checkDimStrategy(strategy string) error {
+	switch strategy {
+	case dimStrategyStrict, dimStrategyPad, dimStrategyTruncate:
+		return nil
+	default:
+		return fmt.Errorf("unknown dimension strategy %q, expected %s, %s or %s", strategy, dimStrategyStrict, dimStrategyPad, dimStrategyTruncate)
+	}
+}
+
+// fitDimensions pads or truncates the vectors of items in place so that
+// the primary vectors share one length, as do the vectors of each name.
+// It returns the number of items changed and leaves items untouched under
+// the strict strategy.
+func fitDimensions(items []VectorItem, strategy string) int {
+	if strategy == dimStrategyStrict {
+		return 0
+	}
+
+	// target picks the length all vectors are fitted to
+	target := func(vector func(VectorItem) ([]float64, bool)) int {
+		length := -1
+		for _, item := range items {
+			v, ok := vector(item)
+			if !ok {
+				continue
+			}
+			if length < 0 || (strategy == dimStrategyPad && len(v) > length) || (strategy == dimStrategyTruncate && len(v) < length) {
+				length = len(v)
+			}
+		}
+		return length
+	}
+	fit := func(v []float64, length int) []float64 {
+		if len(v) > length {
+			return v[:length:length]
+		}
+		return append(v, make([]float64, length-len(v))...)
+	}
+
+	adjusted := make([]bool, len(items))
+	length := target(func(item VectorItem) ([]float64, bool) { return item.Vector, true })
+	for i := range items {
+		if len(items[i].Vector) != length {
+			items[i].Vector = fit(items[i].Vector, length)
+			adjusted[i] = true
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, item := range items {
+		for name := range item.Vectors {
+			names[name] = true
+		}
+	}
+	for name := range names {
+		length := target(func(item VectorItem) ([]float64, bool) {
+			v, ok := item.Vectors[name]
+			return v, ok
+		})
+		for i := range items {
+			if v, ok := items[i].Vectors[name]; ok && len(v) != length {
+				items[i].Vectors[name] = fit(v, length)
+				adjusted[i] = true
+			}
+		}
+	}
+
+	count := 0
+	for _, changed := range adjusted {
+		if changed {
+			count++
+		}
+	}
+	return count
+}
+
+// fitLoadedDimensions applies the -dim-strategy to freshly loaded items
+// and then checks that every vector has the same dimension
+func fitLoadedDimensions(items []VectorItem, path, strategy string) error {
+	if adjusted := fitDimensions(items, strategy); adjusted > 0 {
+		log.Printf("%s: adjusted %d of %d items to a consistent dimension (-dim-strategy %s)", path, adjusted, len(items), strategy)
+	}
+	_, err := checkDimensions(items)
+	return err
+}
+
+// loadJSONDataset reads a JSON array of VectorItems from path and checks
+// that every vector has the same dimension, after fitting mismatched
+// vectors according to strategy
+func loadJSONDataset(path, strategy string) ([]VectorItem, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []VectorItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s contains no vectors", path)
+	}
+
+	if err := fitLoadedDimensions(items, path, strategy); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// checkDimensions returns the dimension shared by all items, or an error
+// naming the first item that differs. Named vectors must be present on
+// every item, each with a consistent dimension of its own.
+func checkDimensions(items []VectorItem) (int, error) {
+	dimensions := len(items[0].Vector)
+	if dimensions == 0 {
+		return 0, fmt.Errorf("item %q has an empty vector", items[0].ID)
+	}
+	for i, item := range items {
+		if len(item.Vector) != dimensions {
+			return 0, fmt.Errorf("item %d (id %q) has %d dimensions, expected %d", i, item.ID, len(item.Vector), dimensions)
+		}
+	}
+
+	for name, first := range items[0].Vectors {
+		if len(first) == 0 {
+			return 0, fmt.Errorf("item %q has an empty %s vector", items[0].ID, name)
+		}
+		for i, item := range items {
+			vector, ok := item.Vectors[name]
+			if !ok {
+				return 0, fmt.Errorf("item %d (id %q) has no %s vector", i, item.ID, name)
+			}
+			if len(vector) != len(first) {
+				return 0, fmt.Errorf("item %d (id %q) has %d %s dimensions, expected %d", i, item.ID, len(vector), name, len(first))
+			}
+		}
+	}
+	for i, item := range items {
+		if len(item.Vectors) != len(items[0].Vectors) {
+			return 0, fmt.Errorf("item %d (id %q) has %d named vectors, expected %d", i, item.ID, len(item.Vectors), len(items[0].Vectors))
+		}
+	}
+	return dimensions, nil
+}
+
+// hasVectorField reports whether the loaded dataset carries a named vector
+// called name. Synthetic data only has the primary vector.
+func hasVectorField(name string) bool {
+	loaded := loadedItems()
+	if len(loaded) == 0 {
+		return false
+	}
+	_, ok := loaded[0].Vectors[name]
+	return ok
+}
+
+// vectorDimensions returns the length of the vectors the request
+// operates on: those of the selected loaded vector, or the generated ones
+func (p vectorParams) vectorDimensions() int {
+	loaded := loadedItems()
+	if len(loaded) == 0 {
+		return p.Dimensions
+	}
+	return len(selectVector(loaded[0], p.VectorField).Vector)
+}
+
+// selectVector returns item with the named vector in place of the primary
+// one. An empty name leaves the item unchanged.
+func selectVector(item VectorItem, name string) VectorItem {
+	if name != "" {
+		item.Vector = item.Vectors[name]
+	}
+	return item
+}
+
+// view returns item as the request sees it: with the selected vector in
+// place of the primary one, normalized if asked. The item's vectors are
+// never modified.
+func (p vectorParams) view(item VectorItem) VectorItem {
+	item = selectVector(item, p.VectorField)
+	if p.Normalize {
+		item = normalizeItem(item)
+	}
+	return item
+}
+
+// normalizeItem returns item with its primary and named vectors scaled to
+// unit length
+func normalizeItem(item VectorItem) VectorItem {
+	item.Vector = normalizeVector(item.Vector)
+	if item.Vectors != nil {
+		vectors := make(map[string][]float64, len(item.Vectors))
+		for name, vector := range item.Vectors {
+			vectors[name] = normalizeVector(vector)
+		}
+		item.Vectors = vectors
+	}
+	return item
+}
+
+// datasetSize returns how many items datasetItems would return, without
+// generating them
+func datasetSize(params vectorParams) int {
+	if loaded := loadedItems(); loaded != nil {
+		return len(loaded)
+	}
+	return params.Size
+}
+
+// datasetItems returns the logical dataset for a request: the loaded data
+// when the server was started with -data, otherwise params.Size generated
+// items. Each item's Vector is the one selected by params.VectorField,
+// normalized when params.Normalize is set. Generation stops early with the
+// context's error if ctx ends.
+func datasetItems(ctx context.Context, params vectorParams) ([]VectorItem, error) {
+	if loaded := loadedItems(); loaded != nil {
+		// Handlers annotate items in place, so give each request its own copy
+		data := make([]VectorItem, len(loaded))
+		for i, item := range loaded {
+			data[i] = params.view(item)
+		}
+		return data, nil
+	}
+	data, err := generateVectorData(ctx, params.Rand(), params.Size, params.generatorConfig)
+	if err != nil {
+		return nil, err
+	}
+	if params.Normalize {
+		for i := range data {
+			data[i] = normalizeItem(data[i])
+		}
+	}
+	return data, nil
+}
+
+// datasetIterator returns a function yielding the same items as
+// datasetItems in order, generating synthetic items only as they are
+// requested. It reports false once the dataset is exhausted.
+func datasetIterator(params vectorParams) func() (VectorItem, bool) {
+	if data := loadedItems(); data != nil {
+		i := 0
+		return func() (VectorItem, bool) {
+			if i >= len(data) {
+				return VectorItem{}, false
+			}
+			i++
+			return params.view(data[i-1]), true
+		}
+	}
+
+	generator := newVectorGenerator(params.Rand(), params.generatorConfig)
+	generated := 0
+	return func() (VectorItem, bool) {
+		if generated >= params.Size {
+			return VectorItem{}, false
+		}
+		generated++
+		return params.view(generator.Next()), true
+	}
+}
+
+// sliceIterator yields the items of data in order, in the same form as
+// datasetIterator
+func sliceIterator(data []VectorItem) func() (VectorItem, bool) {
+	i := 0
+	return func() (VectorItem, bool) {
+		if i >= len(data) {
+			return VectorItem{}, false
+		}
+		i++
+		return data[i-1], true
+	}
+}
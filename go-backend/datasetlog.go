@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DatasetLogEntry records one /api/vectors request. Replay is the request
+// with its seed made explicit, so fetching it returns the same vectors;
+// created timestamps of unseeded requests were not anchored to the day and
+// differ on replay. Count is the number of items returned, and is null for
+// streamed responses and cache hits.
+type DatasetLogEntry struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Path      string           `json:"path"`
+	Replay    string           `json:"replay"`
+	Seed      int64            `json:"seed"`
+	Seeded    bool             `json:"seeded"`
+	Params    GenerationParams `json:"params"`
+	Count     *int             `json:"count"`
+}
+
+// datasetLogger appends one JSON line per request to a file. It is safe
+// for concurrent use.
+type datasetLogger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// datasetLog records /api/vectors requests. It is nil unless -log-requests
+// is set.
+var datasetLog *datasetLogger
+
+// openDatasetLog opens path for appending, creating it if needed
+func openDatasetLog(path string) (*datasetLogger, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &datasetLogger{file: file}, nil
+}
+
+// Record appends an entry for a request answered with count items, or nil
+// when the count isn't known. A failed write is logged and otherwise
+// ignored; the request has already been served.
+func (l *datasetLogger) Record(r *http.Request, params vectorParams, count *int) {
+	query := r.URL.Query()
+	query.Set("seed", strconv.FormatInt(params.Seed, 10))
+	entry := DatasetLogEntry{
+		Timestamp: time.Now().UTC(),
+		Path:      r.URL.Path,
+		Replay:    r.URL.Path + "?" + query.Encode(),
+		Seed:      params.Seed,
+		Seeded:    params.Seeded,
+		Params:    generationParams(params),
+		Count:     count,
+	}
+
+	// Keep & in replay URLs readable
+	var line bytes.Buffer
+	encoder := json.NewEncoder(&line)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(entry); err != nil {
+		log.Printf("dataset log: encoding entry: %v", err)
+		return
+	}
+
+	// One write per line so concurrent entries can't interleave
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(line.Bytes()); err != nil {
+		log.Printf("dataset log: %v", err)
+	}
+}
+
+// Close closes the log file
+func (l *datasetLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// Every point's neighborhood query scans the whole dataset, so DBSCAN is
+// O(n²) and the endpoint refuses larger inputs
+const maxDBSCANItems = 2000
+
+// dbscanNoise is the cluster ID of points that belong to no cluster
+const dbscanNoise = -1
+
+// DBSCANResponse is the response structure for DBSCAN clustering. Items
+// that belong to no cluster have cluster_id -1.
+type DBSCANResponse struct {
+	Data     []VectorItem `json:"data"`
+	Total    int          `json:"total"`
+	Clusters int          `json:"clusters"`
+	Noise    int          `json:"noise"`
+	Eps      float64      `json:"eps"`
+	MinPts   int          `json:"min_pts"`
+	Metric   string       `json:"metric"`
+}
+
+// dbscan labels each vector with a cluster index, or dbscanNoise. A point
+// is a core point when at least minPts points (itself included) lie within
+// eps of it, and clusters grow outward from core points.
+func dbscan(vectors [][]float64, eps float64, minPts int, distance func(a, b []float64) float64) ([]int, int) {
+	const unvisited = -2
+
+	labels := make([]int, len(vectors))
+	for i := range labels {
+		labels[i] = unvisited
+	}
+
+	region := func(p int) []int {
+		var neighbors []int
+		for q := range vectors {
+			if distance(vectors[p], vectors[q]) <= eps {
+				neighbors = append(neighbors, q)
+			}
+		}
+		return neighbors
+	}
+
+	clusters := 0
+	for p := range vectors {
+		if labels[p] != unvisited {
+			continue
+		}
+		neighbors := region(p)
+		if len(neighbors) < minPts {
+			labels[p] = dbscanNoise
+			continue
+		}
+
+		cluster := clusters
+		clusters++
+		labels[p] = cluster
+
+		// Expand the cluster; border points reached from a core point join
+		// it, including ones previously marked as noise
+		queue := neighbors
+		for len(queue) > 0 {
+			q := queue[0]
+			queue = queue[1:]
+			if labels[q] == dbscanNoise {
+				labels[q] = cluster
+			}
+			if labels[q] != unvisited {
+				continue
+			}
+			labels[q] = cluster
+			if expanded := region(q); len(expanded) >= minPts {
+				queue = append(queue, expanded...)
+			}
+		}
+	}
+
+	return labels, clusters
+}
+
+// defaultDBSCANEps returns the typical distance between two points of the
+// same generated cluster, which makes a good eps for synthetic data.
+// Center coordinates are uniform on ±Separation with variance
+// Separation²/3, and each point adds noise with per-dimension variance
+// Spread²/3 (uniform) or Spread² (gaussian). Two points of a cluster are
+// then about sqrt(2·Dimensions·noise) apart in L2. Their cosine distance is
+// about noise / (Separation²/3 + noise), since they share the center but
+// not the noise.
+// Normalizing keeps cosine distance and makes squared L2 distance twice it.
+func defaultDBSCANEps(config generatorConfig, metric similarityMetric, normalized bool) float64 {
+	noise := config.Spread * config.Spread / 3
+	if config.Distribution == distributionGaussian {
+		noise = config.Spread * config.Spread
+	}
+	centers := config.Separation * config.Separation / 3
+	cosineEps := noise / (centers + noise)
+	switch {
+	case metric.Name == metricCosine:
+		return cosineEps
+	case normalized:
+		return math.Sqrt(2 * cosineEps)
+	default:
+		// A coordinate range stretches distances by its factor
+		_, factor := config.coordTransform()
+		return factor * math.Sqrt(2*float64(config.Dimensions)*noise)
+	}
+}
+
+func handleDBSCAN(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// eps is a distance, so default to euclidean rather than cosine
+	metric, err := parseMetric(r.URL.Query(), metricEuclidean)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	distance, ok := metricDistance(metric)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "dbscan needs a distance; use metric cosine or euclidean")
+		return
+	}
+
+	minPts := 5 // Default
+	if minPtsStr := r.URL.Query().Get("min_pts"); minPtsStr != "" {
+		parsedMinPts, err := strconv.Atoi(minPtsStr)
+		if err != nil || parsedMinPts <= 0 {
+			writeError(w, http.StatusBadRequest, "min_pts must be a positive integer")
+			return
+		}
+		minPts = parsedMinPts
+	}
+
+	var eps float64
+	if epsStr := r.URL.Query().Get("eps"); epsStr != "" {
+		parsedEps, err := strconv.ParseFloat(epsStr, 64)
+		if err != nil || !(parsedEps > 0) {
+			writeError(w, http.StatusBadRequest, "eps must be a positive number")
+			return
+		}
+		eps = parsedEps
+	} else if loadedItems() != nil {
+		// The spread of a loaded dataset is unknown
+		writeError(w, http.StatusBadRequest, "eps is required for loaded datasets")
+		return
+	} else {
+		eps = defaultDBSCANEps(params.generatorConfig, metric, params.Normalize)
+	}
+
+	// Refuse oversized requests before generating anything
+	if datasetSize(params) > maxDBSCANItems {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("DBSCAN is limited to %d vectors", maxDBSCANItems))
+		return
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	if len(data) > maxDBSCANItems {
+		// The loaded dataset grew in the meantime
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("DBSCAN is limited to %d vectors", maxDBSCANItems))
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	labels, clusters := dbscan(vectors, eps, minPts, distance)
+	noise := 0
+	for i := range data {
+		clusterID := labels[i]
+		data[i].ClusterID = &clusterID
+		if clusterID == dbscanNoise {
+			noise++
+		}
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := DBSCANResponse{
+		Data:     data,
+		Total:    len(data),
+		Clusters: clusters,
+		Noise:    noise,
+		Eps:      eps,
+		MinPts:   minPts,
+		Metric:   metric.Name,
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDBSCANAllNoise(t *testing.T) {
+	// Every point is further than eps from every other
+	vectors := [][]float64{{0, 0}, {10, 0}, {0, 10}, {10, 10}, {5, 5}}
+	labels, clusters := dbscan(vectors, 1, 2, euclideanDistance)
+	if clusters != 0 {
+		t.Errorf("clusters = %d, want 0", clusters)
+	}
+	for i, label := range labels {
+		if label != dbscanNoise {
+			t.Errorf("point %d has label %d, want noise", i, label)
+		}
+	}
+}
+
+func TestDBSCANMinPtsAboveDatasetIsAllNoise(t *testing.T) {
+	vectors := [][]float64{{0}, {0.1}, {0.2}}
+	labels, clusters := dbscan(vectors, 1, 4, euclideanDistance)
+	if clusters != 0 {
+		t.Errorf("clusters = %d, want 0", clusters)
+	}
+	for i, label := range labels {
+		if label != dbscanNoise {
+			t.Errorf("point %d has label %d, want noise", i, label)
+		}
+	}
+}
+
+func TestDBSCANSingleCluster(t *testing.T) {
+	// A chain of points each within eps of the next forms one cluster even
+	// though its ends are far apart
+	var vectors [][]float64
+	for i := 0; i < 20; i++ {
+		vectors = append(vectors, []float64{float64(i) * 0.5, 0})
+	}
+	labels, clusters := dbscan(vectors, 0.6, 3, euclideanDistance)
+	if clusters != 1 {
+		t.Fatalf("clusters = %d, want 1", clusters)
+	}
+	for i, label := range labels {
+		if label != 0 {
+			t.Errorf("point %d has label %d, want 0", i, label)
+		}
+	}
+}
+
+func TestDBSCANClustersAndNoise(t *testing.T) {
+	vectors := [][]float64{
+		{0, 0}, {0, 0.1}, {0.1, 0}, // First cluster
+		{5, 5}, {5, 5.1}, {5.1, 5}, // Second cluster
+		{10, -10}, // Noise
+	}
+	labels, clusters := dbscan(vectors, 0.5, 3, euclideanDistance)
+	if clusters != 2 {
+		t.Fatalf("clusters = %d, want 2", clusters)
+	}
+	want := []int{0, 0, 0, 1, 1, 1, dbscanNoise}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Errorf("point %d has label %d, want %d", i, labels[i], want[i])
+		}
+	}
+}
+
+func TestDBSCANBorderPointJoinsCluster(t *testing.T) {
+	// The first point is visited first and isn't core, but it is within
+	// eps of a core point, so it ends up in that point's cluster
+	vectors := [][]float64{{-0.9}, {0}, {0.1}, {0.2}}
+	labels, clusters := dbscan(vectors, 1, 4, euclideanDistance)
+	if clusters != 1 {
+		t.Fatalf("clusters = %d, want 1", clusters)
+	}
+	for i, label := range labels {
+		if label != 0 {
+			t.Errorf("point %d has label %d, want 0", i, label)
+		}
+	}
+}
+
+func TestDefaultDBSCANEpsFollowsSeparation(t *testing.T) {
+	// The cosine eps should match the mean cosine distance between points
+	// of one cluster, whatever the separation
+	for _, separation := range []float64{0.5, 1, 4} {
+		config := testGeneratorConfig()
+		config.Dimensions = 64
+		config.Clusters = 1
+		config.Spread = 0.5
+		config.Separation = separation
+		generator := newVectorGenerator(rand.New(rand.NewSource(config.Seed)), config)
+
+		items := make([][]float64, 200)
+		for i := range items {
+			items[i] = generator.Next().Vector
+		}
+		total, pairs := 0.0, 0
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				total += 1 - cosineSimilarity(items[i], items[j])
+				pairs++
+			}
+		}
+		measured := total / float64(pairs)
+
+		eps := defaultDBSCANEps(config, similarityMetric{Name: metricCosine}, false)
+		if math.Abs(eps-measured) > 0.25*measured {
+			t.Errorf("separation %g: eps %.4f, mean cosine distance %.4f", separation, eps, measured)
+		}
+	}
+}
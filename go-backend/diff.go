@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MetadataChange is a metadata field that differs between two datasets. A
+// field missing on one side is null there.
+type MetadataChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// ItemDiff compares the items with one ID. Delta is the B vector minus the
+// A vector and Displacement its length.
+type ItemDiff struct {
+	ID           string                    `json:"id"`
+	Displacement float64                   `json:"displacement"`
+	Delta        []float64                 `json:"delta"`
+	Metadata     map[string]MetadataChange `json:"metadata,omitempty"`
+}
+
+// DiffSummary aggregates a diff. Matched counts the IDs present in both
+// datasets; Added and Removed count those only in B or only in A, which
+// happens with uuid IDs since they derive from the seed. MetadataChanges
+// counts changed fields over all matched items, ChangedItems the items
+// with at least one, and FieldChanges breaks the count down by field.
+type DiffSummary struct {
+	Matched          int            `json:"matched"`
+	Added            int            `json:"added"`
+	Removed          int            `json:"removed"`
+	MeanDisplacement float64        `json:"mean_displacement"`
+	MaxDisplacement  float64        `json:"max_displacement"`
+	MetadataChanges  int            `json:"metadata_changes"`
+	ChangedItems     int            `json:"changed_items"`
+	FieldChanges     map[string]int `json:"field_changes"`
+}
+
+// DiffResponse is the response structure for comparing the datasets two
+// seeds generate. Items, Added and Removed are only returned with
+// detail=true; Items follows dataset A's order.
+type DiffResponse struct {
+	SeedA   int64       `json:"seed_a"`
+	SeedB   int64       `json:"seed_b"`
+	Summary DiffSummary `json:"summary"`
+	Items   []ItemDiff  `json:"items,omitempty"`
+	Added   []string    `json:"added,omitempty"`
+	Removed []string    `json:"removed,omitempty"`
+}
+
+// diffMetadata returns the fields whose values differ between a and b
+func diffMetadata(a, b map[string]interface{}) map[string]MetadataChange {
+	changes := make(map[string]MetadataChange)
+	for key, before := range a {
+		after, ok := b[key]
+		if !ok || !reflect.DeepEqual(before, after) {
+			changes[key] = MetadataChange{Before: before, After: after}
+		}
+	}
+	for key, after := range b {
+		if _, ok := a[key]; !ok {
+			changes[key] = MetadataChange{After: after}
+		}
+	}
+	return changes
+}
+
+// diffDatasets compares the items of b with the items of a that have the
+// same ID
+func diffDatasets(a, b []VectorItem, detail bool) DiffResponse {
+	response := DiffResponse{
+		Summary: DiffSummary{FieldChanges: make(map[string]int)},
+	}
+
+	byID := make(map[string]VectorItem, len(b))
+	for _, item := range b {
+		byID[item.ID] = item
+	}
+	seen := make(map[string]bool, len(a))
+
+	total := 0.0
+	for _, before := range a {
+		seen[before.ID] = true
+		after, ok := byID[before.ID]
+		if !ok {
+			response.Summary.Removed++
+			if detail {
+				response.Removed = append(response.Removed, before.ID)
+			}
+			continue
+		}
+
+		delta := make([]float64, len(before.Vector))
+		for j := range delta {
+			delta[j] = after.Vector[j] - before.Vector[j]
+		}
+		displacement := math.Sqrt(dotProduct(delta, delta))
+		total += displacement
+		response.Summary.MaxDisplacement = math.Max(response.Summary.MaxDisplacement, displacement)
+		response.Summary.Matched++
+
+		changes := diffMetadata(before.Metadata, after.Metadata)
+		if len(changes) > 0 {
+			response.Summary.ChangedItems++
+			response.Summary.MetadataChanges += len(changes)
+			for key := range changes {
+				response.Summary.FieldChanges[key]++
+			}
+		}
+
+		if detail {
+			response.Items = append(response.Items, ItemDiff{
+				ID:           before.ID,
+				Displacement: displacement,
+				Delta:        delta,
+				Metadata:     changes,
+			})
+		}
+	}
+
+	for _, item := range b {
+		if !seen[item.ID] {
+			response.Summary.Added++
+			if detail {
+				response.Added = append(response.Added, item.ID)
+			}
+		}
+	}
+	sort.Strings(response.Added)
+
+	if response.Summary.Matched > 0 {
+		response.Summary.MeanDisplacement = total / float64(response.Summary.Matched)
+	}
+	return response
+}
+
+// handleDiff compares the datasets generated from seed_a and seed_b with
+// otherwise identical parameters
+func handleDiff(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// A loaded dataset is the same for every seed
+	if loadedItems() != nil {
+		writeError(w, http.StatusConflict, "diff compares generated datasets and is not available with -data or -csv")
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	seeds := make([]int64, 2)
+	for i, name := range []string{"seed_a", "seed_b"} {
+		seed, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is required and must be an integer", name))
+			return
+		}
+		seeds[i] = seed
+	}
+
+	detail := false
+	if detailStr := r.URL.Query().Get("detail"); detailStr != "" {
+		detail, err = strconv.ParseBool(detailStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "detail must be true or false")
+			return
+		}
+	}
+
+	// Generate data, both datasets anchored like seeded requests so only
+	// the seed differs
+	datasets := make([][]VectorItem, 2)
+	for i, seed := range seeds {
+		seeded := params
+		seeded.Seed = seed
+		seeded.Seeded = true
+		if !params.FixedTime {
+			seeded.BaseTime = params.BaseTime.UTC().Truncate(24 * time.Hour)
+		}
+		datasets[i], err = datasetItems(r.Context(), seeded)
+		if err != nil {
+			writeWorkError(w, err)
+			return
+		}
+	}
+
+	response := diffDatasets(datasets[0], datasets[1], detail)
+	response.SeedA = seeds[0]
+	response.SeedB = seeds[1]
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(response)
+}
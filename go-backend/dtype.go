@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+)
+
+// Vector element types accepted by dtype. float32 keeps about 7
+// significant digits, well beyond what a plot can show, and halves binary
+// exports; JSON shrinks too since each component prints at most 9 digits.
+// Distances computed from the returned vectors then differ from the
+// server's own in the last digits, so leave it unset when they must match.
+const (
+	dtypeFloat64 = "float64"
+	dtypeFloat32 = "float32"
+)
+
+// parseDType reads the dtype parameter, reporting whether it asks for
+// float32
+func parseDType(query url.Values) (bool, error) {
+	switch query.Get("dtype") {
+	case "", dtypeFloat64:
+		return false, nil
+	case dtypeFloat32:
+		return true, nil
+	default:
+		return false, fmt.Errorf("dtype must be %q or %q", dtypeFloat64, dtypeFloat32)
+	}
+}
+
+// float32Value rounds x to float32 precision. The result is the float64
+// closest to the shortest decimal that identifies float32(x), so JSON
+// prints that decimal rather than every digit of the widened float32.
+func float32Value(x float64) float64 {
+	if narrowed := float32(x); math.IsInf(float64(narrowed), 0) != math.IsInf(x, 0) {
+		// Beyond float32's range; keep the value rather than an infinity
+		return x
+	}
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(x, 'g', -1, 32), 64)
+	return rounded
+}
+
+// float32Vector returns a copy of v rounded to float32 precision
+func float32Vector(v []float64) []float64 {
+	if v == nil {
+		return nil
+	}
+	rounded := make([]float64, len(v))
+	for i, x := range v {
+		rounded[i] = float32Value(x)
+	}
+	return rounded
+}
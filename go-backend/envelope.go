@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// vectorEnvelope wraps a page of vector data in one API version's response
+// structure
+type vectorEnvelope func(response VectorDataResponse, params vectorParams) interface{}
+
+// vectorEnvelopeV1 is the original /api/vectors response, unchanged
+func vectorEnvelopeV1(response VectorDataResponse, _ vectorParams) interface{} {
+	return response
+}
+
+// GenerationParams echoes the parameters a response was generated with,
+// after defaults were applied
+type GenerationParams struct {
+	Size            int       `json:"size"`
+	Dimensions      int       `json:"dimensions"`
+	Clusters        int       `json:"clusters"`
+	MinClusters     int       `json:"min_clusters"`
+	MaxClusters     int       `json:"max_clusters"`
+	ClusterWeights  []float64 `json:"cluster_weights,omitempty"`
+	Spread          float64   `json:"spread"`
+	Separation      float64   `json:"separation"`
+	CoordMin        float64   `json:"coord_min,omitempty"`
+	CoordMax        float64   `json:"coord_max,omitempty"`
+	Distribution    string    `json:"distribution"`
+	BaseTime        time.Time `json:"base_time"`
+	IDFormat        string    `json:"id_format"`
+	KeyFormat       string    `json:"key_format"`
+	Correlated      bool      `json:"correlated"`
+	OutlierFraction float64   `json:"outlier_fraction"`
+	TagOutliers     bool      `json:"tag_outliers"`
+	Sparse          float64   `json:"sparse"`
+	IntrinsicDim    int       `json:"intrinsic_dim,omitempty"`
+	IntrinsicNoise  float64   `json:"intrinsic_noise,omitempty"`
+	MetadataFields  []string  `json:"metadata_fields,omitempty"`
+	Vector          string    `json:"vector,omitempty"`
+	Normalize       bool      `json:"normalize"`
+}
+
+// VectorDataResponseV2 is the /api/v2/vectors response: the v1 fields plus
+// when and how the data was generated. Seed is the seed used even when the
+// request didn't give one, so passing it back reproduces the vectors; it
+// is omitted for loaded datasets. GeneratedAt is only set for unseeded
+// requests: seeded bodies are cached and ETagged, so they must depend on
+// the request alone, and a timestamp in them would either go stale on a
+// cache hit or change the ETag on every fetch.
+type VectorDataResponseV2 struct {
+	VectorDataResponse
+	GeneratedAt *time.Time       `json:"generated_at,omitempty"`
+	Seed        *int64           `json:"seed,omitempty"`
+	Params      GenerationParams `json:"params"`
+}
+
+// generationParams returns the echo of params
+func generationParams(params vectorParams) GenerationParams {
+	return GenerationParams{
+		Size:            params.Size,
+		Dimensions:      params.Dimensions,
+		Clusters:        params.Clusters,
+		MinClusters:     params.MinClusters,
+		MaxClusters:     params.MaxClusters,
+		ClusterWeights:  params.ClusterWeights,
+		Spread:          params.Spread,
+		Separation:      params.Separation,
+		CoordMin:        params.CoordMin,
+		CoordMax:        params.CoordMax,
+		Distribution:    params.Distribution,
+		BaseTime:        params.BaseTime,
+		IDFormat:        params.IDFormat,
+		KeyFormat:       params.KeyFormat,
+		Correlated:      params.Correlated,
+		OutlierFraction: params.OutlierFraction,
+		TagOutliers:     params.TagOutliers,
+		Sparse:          params.Sparsity,
+		IntrinsicDim:    params.IntrinsicDim,
+		IntrinsicNoise:  params.IntrinsicNoise,
+		MetadataFields:  params.MetadataFields,
+		Vector:          params.VectorField,
+		Normalize:       params.Normalize,
+	}
+}
+
+func vectorEnvelopeV2(response VectorDataResponse, params vectorParams) interface{} {
+	v2 := VectorDataResponseV2{
+		VectorDataResponse: response,
+		Params:             generationParams(params),
+	}
+	if !params.Seeded {
+		now := time.Now().UTC()
+		v2.GeneratedAt = &now
+	}
+	if loadedItems() == nil {
+		seed := params.Seed
+		v2.Seed = &seed
+	}
+	return v2
+}
+
+func handleVectorDataV2(w http.ResponseWriter, r *http.Request) {
+	serveVectorData(w, r, vectorEnvelopeV2)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fetchVectorsV2 requests /api/v2/vectors with query and returns the body
+// and its ETag
+func fetchVectorsV2(t *testing.T, query string) (string, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handleVectorDataV2(w, httptest.NewRequest("GET", "/api/v2/vectors?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", query, w.Code, w.Body)
+	}
+	return w.Body.String(), w.Header().Get("ETag")
+}
+
+func TestSeededV2BodyIsStable(t *testing.T) {
+	first, etag := fetchVectorsV2(t, "seed=7&dimensions=4&limit=10")
+	time.Sleep(2 * time.Millisecond)
+	second, secondETag := fetchVectorsV2(t, "seed=7&dimensions=4&limit=10")
+	if first != second || etag != secondETag {
+		t.Error("identical seeded requests got different bodies")
+	}
+
+	var response VectorDataResponseV2
+	if err := json.Unmarshal([]byte(first), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.GeneratedAt != nil {
+		t.Errorf("seeded response has generated_at %v", response.GeneratedAt)
+	}
+}
+
+func TestUnseededV2HasGeneratedAt(t *testing.T) {
+	before := time.Now().UTC()
+	body, _ := fetchVectorsV2(t, "dimensions=4&limit=10")
+	var response VectorDataResponseV2
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.GeneratedAt == nil || response.GeneratedAt.Before(before.Truncate(time.Second)) {
+		t.Errorf("generated_at = %v, want a time after %v", response.GeneratedAt, before)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Export formats accepted by /api/vectors/export
+const (
+	exportNPY     = "npy"     // The vector matrix as a float64 (or dtype) .npy file
+	exportIDs     = "ids"     // The JSON array of IDs matching the .npy rows
+	exportParquet = "parquet" // Items with flattened metadata as a Parquet file
+)
+
+// Vector layouts for Parquet exports
+const (
+	layoutList = "list" // One list column named vector
+	layoutWide = "wide" // One column per dimension, vector_0, vector_1, ...
+)
+
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parsePagedParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters, err := parseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	order, err := parseSort(r.URL.Query(), params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportNPY // Default
+	}
+	if format != exportNPY && format != exportIDs && format != exportParquet {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("format must be %q, %q or %q", exportNPY, exportIDs, exportParquet))
+		return
+	}
+
+	layout := r.URL.Query().Get("vector_layout")
+	if layout == "" {
+		layout = layoutList // Default
+	}
+	if layout != layoutList && layout != layoutWide {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("vector_layout must be %q or %q", layoutList, layoutWide))
+		return
+	}
+
+	asFloat32, err := parseDType(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Select the same page /api/vectors would return
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	data = filterItems(data, filters)
+	order.Apply(data)
+	page, _ := paginate(data, params.Offset, params.Limit)
+
+	switch format {
+	case exportIDs:
+		ids := make([]string, len(page))
+		for i, item := range page {
+			ids[i] = item.ID
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="vectors.ids.json"`)
+		newJSONEncoder(w, r).Encode(ids)
+
+	case exportNPY:
+		cols := 0
+		vectors := make([][]float64, len(page))
+		for i, item := range page {
+			vectors[i] = item.Vector
+		}
+		if len(vectors) > 0 {
+			cols = len(vectors[0])
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="vectors.npy"`)
+		if err := writeNPY(w, vectors, cols, asFloat32); err != nil {
+			log.Printf("export: writing npy: %v", err)
+		}
+
+	case exportParquet:
+		w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+		w.Header().Set("Content-Disposition", `attachment; filename="vectors.parquet"`)
+		if err := writeParquet(w, page, layout, asFloat32); err != nil {
+			log.Printf("export: writing parquet: %v", err)
+		}
+	}
+}
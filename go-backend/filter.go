@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numericMetadataFields are the generated metadata keys holding numbers,
+// the int and float fields of the metadata schema. Each can be bounded
+// with <key>_min and <key>_max query parameters.
+var numericMetadataFields = numericFields(metadataSchema)
+
+// dateMetadataFields are the generated metadata keys holding RFC 3339
+// timestamps. Each can be bounded with <key>_after and <key>_before query
+// parameters.
+var dateMetadataFields = dateFields(metadataSchema)
+
+// itemFilter decides whether an item is kept by a query
+type itemFilter interface {
+	Match(item VectorItem) bool
+}
+
+// equalityFilter keeps items whose metadata field equals a value. List
+// fields such as tags match when any element equals the value.
+type equalityFilter struct {
+	Key   string
+	Value string
+}
+
+// Match reports whether the item's metadata field equals the filter value.
+// Items without the field never match.
+func (f equalityFilter) Match(item VectorItem) bool {
+	value, ok := item.Metadata[f.Key]
+	if !ok {
+		return false
+	}
+
+	switch v := value.(type) {
+	case []string:
+		for _, element := range v {
+			if element == f.Value {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, element := range v {
+			if fmt.Sprint(element) == f.Value {
+				return true
+			}
+		}
+		return false
+	default:
+		return fmt.Sprint(v) == f.Value
+	}
+}
+
+// rangeFilter keeps items whose numeric metadata field lies within
+// [Min, Max]. Items where the field is missing or not a number never match.
+type rangeFilter struct {
+	Key string
+	Min float64
+	Max float64
+}
+
+// Match reports whether the item's metadata field lies within the range
+func (f rangeFilter) Match(item VectorItem) bool {
+	value, ok := toFloat(item.Metadata[f.Key])
+	return ok && value >= f.Min && value <= f.Max
+}
+
+// timeRangeFilter keeps items whose timestamp metadata field lies within
+// [After, Before). Either bound may be nil. Times are compared as instants,
+// so values and bounds may use different UTC offsets. Items where the
+// field is missing or not an RFC 3339 timestamp never match.
+type timeRangeFilter struct {
+	Key    string
+	After  *time.Time
+	Before *time.Time
+}
+
+// Match reports whether the item's timestamp lies within the range
+func (f timeRangeFilter) Match(item VectorItem) bool {
+	s, ok := item.Metadata[f.Key].(string)
+	if !ok {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return false
+	}
+	if f.After != nil && t.Before(*f.After) {
+		return false
+	}
+	return f.Before == nil || t.Before(*f.Before)
+}
+
+// searchFilter keeps items where any of Fields contains Query, ignoring
+// case. List fields such as tags match when any element contains it.
+type searchFilter struct {
+	Query  string // Lower-cased
+	Fields []string
+}
+
+// Match reports whether any searched field contains the query
+func (f searchFilter) Match(item VectorItem) bool {
+	for _, key := range f.Fields {
+		switch v := item.Metadata[key].(type) {
+		case string:
+			if strings.Contains(strings.ToLower(v), f.Query) {
+				return true
+			}
+		case []string:
+			for _, element := range v {
+				if strings.Contains(strings.ToLower(element), f.Query) {
+					return true
+				}
+			}
+		case []interface{}:
+			for _, element := range v {
+				if s, ok := element.(string); ok && strings.Contains(strings.ToLower(s), f.Query) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// toFloat converts a numeric metadata value to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// parseFilters reads the metadata filters from the query string. Each
+// filter parameter has the form key:value, numeric fields can also be
+// bounded with <key>_min and <key>_max, date fields with <key>_after and
+// <key>_before, and q searches the fields listed in search_fields.
+func parseFilters(query url.Values) ([]itemFilter, error) {
+	var filters []itemFilter
+	for _, raw := range query["filter"] {
+		key, value, ok := strings.Cut(raw, ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected key:value", raw)
+		}
+		filters = append(filters, equalityFilter{Key: key, Value: value})
+	}
+
+	for _, key := range numericMetadataFields {
+		minStr := query.Get(key + "_min")
+		maxStr := query.Get(key + "_max")
+		if minStr == "" && maxStr == "" {
+			continue
+		}
+
+		filter := rangeFilter{Key: key, Min: math.Inf(-1), Max: math.Inf(1)}
+		if minStr != "" {
+			parsedMin, err := strconv.ParseFloat(minStr, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s_min must be a number", key)
+			}
+			filter.Min = parsedMin
+		}
+		if maxStr != "" {
+			parsedMax, err := strconv.ParseFloat(maxStr, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s_max must be a number", key)
+			}
+			filter.Max = parsedMax
+		}
+		filters = append(filters, filter)
+	}
+
+	for _, key := range dateMetadataFields {
+		afterStr := query.Get(key + "_after")
+		beforeStr := query.Get(key + "_before")
+		if afterStr == "" && beforeStr == "" {
+			continue
+		}
+
+		filter := timeRangeFilter{Key: key}
+		if afterStr != "" {
+			after, err := time.Parse(time.RFC3339, afterStr)
+			if err != nil {
+				return nil, fmt.Errorf("%s_after must be an RFC 3339 timestamp such as 2024-01-02T15:04:05Z", key)
+			}
+			filter.After = &after
+		}
+		if beforeStr != "" {
+			before, err := time.Parse(time.RFC3339, beforeStr)
+			if err != nil {
+				return nil, fmt.Errorf("%s_before must be an RFC 3339 timestamp such as 2024-01-02T15:04:05Z", key)
+			}
+			filter.Before = &before
+		}
+		if filter.After != nil && filter.Before != nil && !filter.After.Before(*filter.Before) {
+			return nil, fmt.Errorf("%s_after must be earlier than %s_before", key, key)
+		}
+		filters = append(filters, filter)
+	}
+
+	if q := query.Get("q"); q != "" {
+		fields := []string{"name"} // Default
+		if fieldsStr := query.Get("search_fields"); fieldsStr != "" {
+			fields = strings.Split(fieldsStr, ",")
+			for _, field := range fields {
+				if field == "" {
+					return nil, fmt.Errorf("search_fields must be a comma-separated list of metadata keys")
+				}
+			}
+		}
+		filters = append(filters, searchFilter{Query: strings.ToLower(q), Fields: fields})
+	}
+
+	return filters, nil
+}
+
+// matchesFilters reports whether item passes every filter
+func matchesFilters(item VectorItem, filters []itemFilter) bool {
+	for _, filter := range filters {
+		if !filter.Match(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterItems returns the items matching every filter, in their original
+// order
+func filterItems(data []VectorItem, filters []itemFilter) []VectorItem {
+	if len(filters) == 0 {
+		return data
+	}
+
+	filtered := make([]VectorItem, 0, len(data))
+	for _, item := range data {
+		if matchesFilters(item, filters) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// Both the neighbor graph and the spanning tree compare every pair of
+// points, so the endpoint refuses larger inputs
+const maxGraphItems = 2000
+
+// GraphNode is one point of a graph, with what a layout needs to label and
+// color it
+type GraphNode struct {
+	ID       string   `json:"id"`
+	Key      string   `json:"key"`
+	Clusters []string `json:"clusters"`
+}
+
+// GraphEdge is an undirected edge between two nodes, named by ID. As for
+// neighbors, Similarity is set for the similarity metrics and Distance for
+// euclidean.
+type GraphEdge struct {
+	Source     string   `json:"source"`
+	Target     string   `json:"target"`
+	Similarity *float64 `json:"similarity,omitempty"`
+	Distance   *float64 `json:"distance,omitempty"`
+}
+
+// GraphResponse is the response structure for neighbor graphs. Edges joins
+// each node to its k nearest neighbors, listing each pair once, or with
+// mst=true holds the minimum spanning tree of all pairwise distances, in
+// which case K is omitted.
+type GraphResponse struct {
+	Nodes  []GraphNode `json:"nodes"`
+	Edges  []GraphEdge `json:"edges"`
+	Metric string      `json:"metric"`
+	K      int         `json:"k,omitempty"`
+	MST    bool        `json:"mst"`
+	Total  int         `json:"total"`
+}
+
+// graphPair is an edge between two item indices, from < to
+type graphPair struct {
+	from, to int
+	score    float64
+}
+
+// knnEdges returns the undirected edges joining each vector to its k
+// closest under metric. A pair that are each other's neighbors is listed
+// once. Edges are ordered by their first endpoint, then their second.
+func knnEdges(ctx context.Context, vectors [][]float64, k int, metric similarityMetric) ([]graphPair, error) {
+	n := len(vectors)
+	seen := make(map[[2]int]bool)
+	var pairs []graphPair
+
+	order := make([]int, 0, n-1)
+	row := make([]float64, n)
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		order = order[:0]
+		for j := 0; j < n; j++ {
+			if j != i {
+				row[j] = metric.Score(vectors[i], vectors[j])
+				order = append(order, j)
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return metric.Closer(row[order[a]], row[order[b]])
+		})
+
+		for _, j := range order[:min(k, len(order))] {
+			key := [2]int{min(i, j), max(i, j)}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			pairs = append(pairs, graphPair{from: key[0], to: key[1], score: row[j]})
+		}
+	}
+
+	sort.Slice(pairs, func(a, b int) bool {
+		if pairs[a].from != pairs[b].from {
+			return pairs[a].from < pairs[b].from
+		}
+		return pairs[a].to < pairs[b].to
+	})
+	return pairs, nil
+}
+
+// minimumSpanningTree returns the n-1 edges of the minimum spanning tree
+// of the complete graph over vectors under distance, using Prim's
+// algorithm. Ties go to the lower index, so the tree is reproducible. The
+// edges are in the order they were added, starting from vector 0.
+func minimumSpanningTree(ctx context.Context, vectors [][]float64, distance func(a, b []float64) float64) ([]graphPair, error) {
+	n := len(vectors)
+	if n == 0 {
+		return nil, nil
+	}
+
+	inTree := make([]bool, n)
+	best := make([]float64, n) // Distance from each node to the tree
+	parent := make([]int, n)
+	for i := range best {
+		best[i] = math.Inf(1)
+		parent[i] = -1
+	}
+
+	pairs := make([]graphPair, 0, n-1)
+	current := 0
+	for added := 1; added < n; added++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		inTree[current] = true
+		next := -1
+		for j := 0; j < n; j++ {
+			if inTree[j] {
+				continue
+			}
+			if d := distance(vectors[current], vectors[j]); d < best[j] {
+				best[j] = d
+				parent[j] = current
+			}
+			if next < 0 || best[j] < best[next] {
+				next = j
+			}
+		}
+		pairs = append(pairs, graphPair{from: min(parent[next], next), to: max(parent[next], next)})
+		current = next
+	}
+	return pairs, nil
+}
+
+func handleGraph(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	metric, err := parseMetric(r.URL.Query(), metricCosine)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	k := 5 // Default
+	if kStr := r.URL.Query().Get("k"); kStr != "" {
+		parsedK, err := strconv.Atoi(kStr)
+		if err != nil || parsedK <= 0 {
+			writeError(w, http.StatusBadRequest, "k must be a positive integer")
+			return
+		}
+		k = parsedK
+	}
+
+	mst := false
+	if mstStr := r.URL.Query().Get("mst"); mstStr != "" {
+		mst, err = strconv.ParseBool(mstStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "mst must be true or false")
+			return
+		}
+	}
+	distance, ok := metricDistance(metric)
+	if mst && !ok {
+		writeError(w, http.StatusBadRequest, "a spanning tree needs a distance; use metric cosine or euclidean")
+		return
+	}
+
+	// Refuse oversized requests before generating anything
+	if datasetSize(params) > maxGraphItems {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("graphs are limited to %d vectors", maxGraphItems))
+		return
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	if len(data) > maxGraphItems {
+		// The loaded dataset grew in the meantime
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("graphs are limited to %d vectors", maxGraphItems))
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	nodes := make([]GraphNode, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+		nodes[i] = GraphNode{ID: item.ID, Key: item.Key, Clusters: item.Clusters}
+	}
+
+	var pairs []graphPair
+	if mst {
+		pairs, err = minimumSpanningTree(r.Context(), vectors, distance)
+		for i := range pairs {
+			pairs[i].score = metric.Score(vectors[pairs[i].from], vectors[pairs[i].to])
+		}
+	} else {
+		pairs, err = knnEdges(r.Context(), vectors, k, metric)
+	}
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+
+	edges := make([]GraphEdge, len(pairs))
+	for i, pair := range pairs {
+		score := pair.score
+		edges[i] = GraphEdge{Source: data[pair.from].ID, Target: data[pair.to].ID}
+		if metric.IsDistance {
+			edges[i].Distance = &score
+		} else {
+			edges[i].Similarity = &score
+		}
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := GraphResponse{
+		Nodes:  nodes,
+		Edges:  edges,
+		Metric: metric.Name,
+		MST:    mst,
+		Total:  len(nodes),
+	}
+	if !mst {
+		response.K = k
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
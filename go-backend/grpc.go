@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The minimal gRPC server side needed to serve vectors.proto: unary and
+// server-streaming calls over unencrypted HTTP/2, uncompressed messages,
+// and status in the trailers
+
+// gRPC status codes
+const (
+	grpcOK                = 0
+	grpcCanceled          = 1
+	grpcInvalidArgument   = 3
+	grpcDeadlineExceeded  = 4
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+)
+
+// Largest request message accepted, gRPC's usual default
+const maxGRPCMessageBytes = 4 << 20
+
+// newGRPCServer returns a server for the VectorService on addr. Clients
+// connect with HTTP/2 prior knowledge, as gRPC does without TLS.
+func newGRPCServer(addr string, requestTimeout time.Duration) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/avs.v1.VectorService/GetVectors", handleGRPCGetVectors)
+	mux.HandleFunc("/avs.v1.VectorService/StreamVectors", handleGRPCStreamVectors)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		startGRPC(w)
+		finishGRPC(w, grpcUnimplemented, fmt.Sprintf("unknown method %s", r.URL.Path))
+	})
+
+	var handler http.Handler = mux
+	if requestTimeout > 0 {
+		handler = timeoutMiddleware(requestTimeout, handler)
+	}
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Server{
+		Addr:      addr,
+		Handler:   grpcTimeoutMiddleware(handler),
+		Protocols: &protocols,
+	}
+}
+
+// grpcTimeoutMiddleware applies the deadline a client sends in the
+// grpc-timeout header, such as 500m or 2S
+func grpcTimeoutMiddleware(next http.Handler) http.Handler {
+	units := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if timeout := r.Header.Get("Grpc-Timeout"); len(timeout) >= 2 {
+			amount, err := strconv.ParseInt(timeout[:len(timeout)-1], 10, 64)
+			if unit, ok := units[timeout[len(timeout)-1]]; ok && err == nil && amount > 0 {
+				ctx, cancel := context.WithTimeout(r.Context(), time.Duration(amount)*unit)
+				defer cancel()
+				r = r.WithContext(ctx)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// startGRPC sends the response headers. Every call answers 200 and
+// reports failure in its status trailer.
+func startGRPC(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+}
+
+// finishGRPC ends a call with the given status
+func finishGRPC(w http.ResponseWriter, code int, message string) {
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcPercentEncode(message))
+	}
+}
+
+// finishGRPCWork ends a call that failed while generating data, mapping the
+// context's errors like writeWorkError does
+func finishGRPCWork(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		finishGRPC(w, grpcDeadlineExceeded, "request timed out")
+	case errors.Is(err, context.Canceled):
+		finishGRPC(w, grpcCanceled, "request cancelled")
+	default:
+		finishGRPC(w, grpcInternal, err.Error())
+	}
+}
+
+// grpcPercentEncode escapes a status message for the grpc-message trailer
+func grpcPercentEncode(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c < 0x20 || c > 0x7E || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// readGRPCMessage reads the single length-prefixed message of a request
+func readGRPCMessage(r *http.Request) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r.Body, prefix[:]); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > maxGRPCMessageBytes {
+		return nil, fmt.Errorf("message of %d bytes exceeds the limit of %d", length, maxGRPCMessageBytes)
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r.Body, message); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	return message, nil
+}
+
+// writeGRPCMessage sends one length-prefixed message
+func writeGRPCMessage(w http.ResponseWriter, message []byte) error {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(message)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(message)
+	return err
+}
+
+// grpcVectorRequest is a decoded VectorsRequest, parsed like the query
+// string of /api/vectors
+type grpcVectorRequest struct {
+	params  vectorParams
+	filters []itemFilter
+	order   sortOptions
+	output  outputOptions
+}
+
+// parseGRPCVectorRequest reads and parses a call's VectorsRequest. On
+// failure it ends the call and returns false.
+func parseGRPCVectorRequest(w http.ResponseWriter, r *http.Request) (grpcVectorRequest, bool) {
+	var request grpcVectorRequest
+	fail := func(code int, err error) (grpcVectorRequest, bool) {
+		finishGRPC(w, code, err.Error())
+		return request, false
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		return fail(grpcInvalidArgument, fmt.Errorf("content type must be application/grpc"))
+	}
+	message, err := readGRPCMessage(r)
+	if err != nil {
+		return fail(grpcInvalidArgument, err)
+	}
+	query, err := decodeVectorsRequest(message)
+	if err != nil {
+		return fail(grpcInvalidArgument, err)
+	}
+
+	// Parse the parameters from a request carrying them as a query string,
+	// so they mean exactly what they mean over HTTP
+	r = r.Clone(r.Context())
+	r.URL = &url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+
+	if request.params, err = parsePagedParams(r); err != nil {
+		return fail(grpcInvalidArgument, err)
+	}
+	if request.filters, err = parseFilters(query); err != nil {
+		return fail(grpcInvalidArgument, err)
+	}
+	if request.output, err = parseOutputOptions(query, request.params.vectorDimensions()); err != nil {
+		return fail(grpcInvalidArgument, err)
+	}
+	if request.order, err = parseSort(query, request.params); err != nil {
+		return fail(grpcInvalidArgument, err)
+	}
+	if request.output.Nearest, err = parseNearest(query, request.params); err != nil {
+		return fail(grpcInvalidArgument, err)
+	}
+	return request, true
+}
+
+// handleGRPCGetVectors serves VectorService.GetVectors
+func handleGRPCGetVectors(w http.ResponseWriter, r *http.Request) {
+	startGRPC(w)
+	request, ok := parseGRPCVectorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Generate data
+	if err := checkResponseSize(request.params.Limit, request.params, request.output); err != nil {
+		finishGRPC(w, grpcResourceExhausted, err.Error())
+		return
+	}
+	data, err := datasetItems(r.Context(), request.params)
+	if err != nil {
+		finishGRPCWork(w, err)
+		return
+	}
+	response := vectorPage(data, request.params, request.filters, request.order, request.output)
+
+	// Return response
+	seed := request.params.Seed
+	if loadedItems() != nil {
+		seed = 0
+	}
+	message, err := encodeVectorsResponse(response, seed)
+	if err != nil {
+		finishGRPC(w, grpcInternal, err.Error())
+		return
+	}
+	if err := writeGRPCMessage(w, message); err != nil {
+		return // The client is gone
+	}
+	finishGRPC(w, grpcOK, "")
+}
+
+// handleGRPCStreamVectors serves VectorService.StreamVectors, sending each
+// item as soon as it is generated unless a sort needs the whole dataset
+func handleGRPCStreamVectors(w http.ResponseWriter, r *http.Request) {
+	startGRPC(w)
+	request, ok := parseGRPCVectorRequest(w, r)
+	if !ok {
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+
+	// Generate data
+	next, filters := datasetIterator(request.params), request.filters
+	if request.order.Reorders() {
+		data, err := datasetItems(r.Context(), request.params)
+		if err != nil {
+			finishGRPCWork(w, err)
+			return
+		}
+		data = filterItems(data, filters)
+		request.order.Apply(data)
+		next, filters = sliceIterator(data), nil
+	}
+
+	page := pageIterator(next, request.params, filters)
+	for {
+		if err := r.Context().Err(); err != nil {
+			finishGRPCWork(w, err)
+			return
+		}
+		item, ok := page()
+		if !ok {
+			break
+		}
+		message, err := encodeVectorItem(request.output.Apply(item))
+		if err != nil {
+			finishGRPC(w, grpcInternal, err.Error())
+			return
+		}
+		if err := writeGRPCMessage(w, message); err != nil {
+			return // The client is gone
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	finishGRPC(w, grpcOK, "")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/hev/avs-visualizer/go-backend/avspb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Conversion between the API types and the messages generated from
+// vectors.proto into avspb
+
+// Messages are marshaled deterministically, so equal responses encode
+// equally
+var protoMarshal = proto.MarshalOptions{Deterministic: true}
+
+// decodeVectorsRequest reads a VectorsRequest into the equivalent
+// /api/vectors query parameters
+func decodeVectorsRequest(message []byte) (url.Values, error) {
+	var request avspb.VectorsRequest
+	if err := proto.Unmarshal(message, &request); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	for name, v := range map[string]int32{
+		"limit":      request.GetLimit(),
+		"offset":     request.GetOffset(),
+		"size":       request.GetSize(),
+		"dimensions": request.GetDimensions(),
+		"clusters":   request.GetClusters(),
+	} {
+		if v != 0 {
+			query.Set(name, strconv.FormatInt(int64(v), 10))
+		}
+	}
+	if request.Seed != nil {
+		query.Set("seed", strconv.FormatInt(request.GetSeed(), 10))
+	}
+	for _, param := range request.GetParams() {
+		query.Add(param.GetName(), param.GetValue())
+	}
+	return query, nil
+}
+
+// encodeVectorsResponse marshals response as a VectorsResponse
+func encodeVectorsResponse(response VectorDataResponse, seed int64) ([]byte, error) {
+	message := &avspb.VectorsResponse{
+		Data:   make([]*avspb.VectorItem, len(response.Data)),
+		Total:  int32(response.Total),
+		Offset: int32(response.Offset),
+		Limit:  int32(response.Limit),
+		Seed:   seed,
+	}
+	for i, item := range response.Data {
+		message.Data[i] = protoVectorItem(item)
+	}
+	if response.NextOffset != nil {
+		message.NextOffset = proto.Int32(int32(*response.NextOffset))
+	}
+	return protoMarshal.Marshal(message)
+}
+
+// encodeVectorItem marshals item as a VectorItem
+func encodeVectorItem(item VectorItem) ([]byte, error) {
+	return protoMarshal.Marshal(protoVectorItem(item))
+}
+
+// protoVectorItem converts item to its message
+func protoVectorItem(item VectorItem) *avspb.VectorItem {
+	message := &avspb.VectorItem{
+		Id:                item.ID,
+		Key:               item.Key,
+		Vector:            item.Vector,
+		Clusters:          item.Clusters,
+		NearestCluster:    item.NearestCluster,
+		QuantizationScale: item.QuantizationScale,
+	}
+	if item.Metadata != nil {
+		message.Metadata = protoStruct(item.Metadata)
+	}
+	if len(item.QuantizedVector) > 0 {
+		message.QuantizedVector = make([]int32, len(item.QuantizedVector))
+		for i, q := range item.QuantizedVector {
+			message.QuantizedVector[i] = int32(q)
+		}
+	}
+	return message
+}
+
+// protoStruct converts a map to a google.protobuf.Struct
+func protoStruct(fields map[string]interface{}) *structpb.Struct {
+	s := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(fields))}
+	for key, value := range fields {
+		s.Fields[key] = protoValue(value)
+	}
+	return s
+}
+
+// protoValue converts v to a google.protobuf.Value. Unlike
+// structpb.NewValue it takes every metadata type, writing string lists as
+// lists and anything unknown as its string form.
+func protoValue(v interface{}) *structpb.Value {
+	if number, ok := toFloat(v); ok {
+		return structpb.NewNumberValue(number)
+	}
+	switch v := v.(type) {
+	case nil:
+		return structpb.NewNullValue()
+	case string:
+		return structpb.NewStringValue(v)
+	case bool:
+		return structpb.NewBoolValue(v)
+	case map[string]interface{}:
+		return structpb.NewStructValue(protoStruct(v))
+	case []string:
+		list := &structpb.ListValue{Values: make([]*structpb.Value, len(v))}
+		for i, s := range v {
+			list.Values[i] = structpb.NewStringValue(s)
+		}
+		return structpb.NewListValue(list)
+	case []interface{}:
+		list := &structpb.ListValue{Values: make([]*structpb.Value, len(v))}
+		for i, element := range v {
+			list.Values[i] = protoValue(element)
+		}
+		return structpb.NewListValue(list)
+	default:
+		return structpb.NewStringValue(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hev/avs-visualizer/go-backend/avspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// The golden messages below were marshaled in deterministic mode from
+// messages built by hand against vectors.proto, so they pin how the API
+// types map onto its fields.
+
+const (
+	goldenRequest              = "0819103218e8072008280330f9ffffffffffffffff013a0d0a067370726561641203302e353a170a0666696c746572120d7374617475733a6163746976653a170a0666696c746572120d69734163746976653a74727565"
+	goldenRequestNegativeLimit = "08ffffffffffffffffff013000"
+	goldenItem                 = "0a02343212046b2d34321a20000000000000f83f000000000000d0bf0000000000000000182d4454fb21094022d5010a0e0a086172636869766564120220000a0b0a05656d707479120232000a0e0a086973416374697665120220010a0d0a076d697373696e67120208000a1f0a056d69786564121632140a0911000000000000f03f0a031a01780a0208000a150a046e616d65120d1a0b426c7565205769646765740a200a066e657374656412162a140a120a05646570746812091100000000000000400a130a06726174696e6712091100000000000010400a140a0474616773120c320a0a031a01610a031a01620a120a0576616c7565120911ae47e17a14ae28402a09636c75737465725f302a09636c75737465725f323209636c75737465725f303a09fe01fd010002018001410000000000000000"
+	goldenItemEmptyMetadata    = "0a01372200"
+	goldenResponse             = "0a0d0a01301a08000000000000f03f0a0d0a01311a08000000000000f03f10e80718322002283430f9ffffffffffffffff01"
+	goldenResponseZeroNext     = "280030ffffffffffffffff7f"
+	goldenResponseEmpty        = ""
+)
+
+// mustDecodeHex decodes a golden message
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// checkGolden compares an encoded message with its golden bytes
+func checkGolden(t *testing.T, name string, got []byte, golden string) {
+	t.Helper()
+	if want := mustDecodeHex(t, golden); !bytes.Equal(got, want) {
+		t.Errorf("%s encodes as\n%x\nwant\n%x", name, got, want)
+	}
+}
+
+func TestDecodeVectorsRequestGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		golden string
+		want   map[string][]string
+	}{
+		{"every field", goldenRequest, map[string][]string{
+			"limit":      {"25"},
+			"offset":     {"50"},
+			"size":       {"1000"},
+			"dimensions": {"8"},
+			"clusters":   {"3"},
+			"seed":       {"-7"},
+			"spread":     {"0.5"},
+			"filter":     {"status:active", "isActive:true"},
+		}},
+		// A set optional seed of zero is still a seed; int32 fields are
+		// sign-extended to ten bytes on the wire
+		{"negative limit and zero seed", goldenRequestNegativeLimit, map[string][]string{
+			"limit": {"-1"},
+			"seed":  {"0"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := decodeVectorsRequest(mustDecodeHex(t, tt.golden))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(map[string][]string(query), tt.want) {
+				t.Errorf("decoded %v, want %v", query, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeVectorItemGolden(t *testing.T) {
+	scale := 0.0
+	item := VectorItem{
+		ID:     "42",
+		Key:    "k-42",
+		Vector: []float64{1.5, -0.25, 0, math.Pi},
+		Metadata: map[string]interface{}{
+			"name":     "Blue Widget",
+			"rating":   4,
+			"value":    12.34,
+			"isActive": true,
+			"archived": false,
+			"missing":  nil,
+			"tags":     []string{"a", "b"},
+			"empty":    []string{},
+			"nested":   map[string]interface{}{"depth": 2},
+			"mixed":    []interface{}{1, "x", nil},
+		},
+		Clusters:          []string{"cluster_0", "cluster_2"},
+		NearestCluster:    "cluster_0",
+		QuantizedVector:   []int8{127, -127, 0, 1, -1, 64},
+		QuantizationScale: &scale,
+	}
+	got, err := encodeVectorItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "item", got, goldenItem)
+
+	// Empty metadata is still a present Struct
+	got, err = encodeVectorItem(VectorItem{ID: "7", Metadata: map[string]interface{}{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "item with empty metadata", got, goldenItemEmptyMetadata)
+}
+
+func TestEncodeVectorsResponseGolden(t *testing.T) {
+	next, zero := 52, 0
+	response := VectorDataResponse{
+		Data:       []VectorItem{{ID: "0", Vector: []float64{1}}, {ID: "1", Vector: []float64{1}}},
+		Total:      1000,
+		Offset:     50,
+		Limit:      2,
+		NextOffset: &next,
+	}
+	tests := []struct {
+		name     string
+		response VectorDataResponse
+		seed     int64
+		golden   string
+	}{
+		{"response", response, -7, goldenResponse},
+		{"response with next_offset 0", VectorDataResponse{NextOffset: &zero}, math.MaxInt64, goldenResponseZeroNext},
+		{"empty response", VectorDataResponse{}, 0, goldenResponseEmpty},
+	}
+	for _, tt := range tests {
+		got, err := encodeVectorsResponse(tt.response, tt.seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, tt.name, got, tt.golden)
+	}
+}
+
+func TestGRPCGetVectorsRoundTrip(t *testing.T) {
+	request, err := proto.Marshal(&avspb.VectorsRequest{
+		Limit:      3,
+		Dimensions: 4,
+		Seed:       proto.Int64(9),
+		Params:     []*avspb.Param{{Name: "sort", Value: "id"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(request)))
+	r := httptest.NewRequest("POST", "/avs.v1.VectorService/GetVectors", bytes.NewReader(append(body, request...)))
+	r.Header.Set("Content-Type", "application/grpc")
+	w := httptest.NewRecorder()
+	handleGRPCGetVectors(w, r)
+
+	result := w.Result()
+	if status := result.Trailer.Get("Grpc-Status"); status != "0" {
+		t.Fatalf("grpc-status %s: %s", status, result.Trailer.Get("Grpc-Message"))
+	}
+	message := w.Body.Bytes()
+	if len(message) < 5 || int(binary.BigEndian.Uint32(message[1:5])) != len(message)-5 {
+		t.Fatalf("malformed response frame %x", message)
+	}
+	var response avspb.VectorsResponse
+	if err := proto.Unmarshal(message[5:], &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.GetSeed() != 9 || len(response.GetData()) != 3 {
+		t.Fatalf("seed %d with %d items, want seed 9 with 3", response.GetSeed(), len(response.GetData()))
+	}
+	for _, item := range response.GetData() {
+		if len(item.GetVector()) != 4 || item.GetMetadata() == nil {
+			t.Errorf("item %s has %d dimensions and metadata %v", item.GetId(), len(item.GetVector()), item.GetMetadata())
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipResponseWriter compresses everything written through it, unless the
+// status is one that has no body
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz       *gzip.Writer
+	bodyless bool
+}
+
+// newGzipResponseWriter marks the response as gzip-encoded and returns a
+// writer that compresses the body. Callers must Close it when done.
+func newGzipResponseWriter(w http.ResponseWriter) *gzipResponseWriter {
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Del("Content-Length")
+	return &gzipResponseWriter{ResponseWriter: w, gz: gzip.NewWriter(w)}
+}
+
+// WriteHeader drops the gzip encoding for 204 and 304 responses, which
+// have no body to compress
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		w.bodyless = true
+		w.Header().Del("Content-Encoding")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.bodyless {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Flush pushes buffered compressed data to the client so streaming
+// responses stay incremental
+func (w *gzipResponseWriter) Flush() {
+	if !w.bodyless {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close writes the gzip footer, if there is a body to end
+func (w *gzipResponseWriter) Close() error {
+	if w.bodyless {
+		return nil
+	}
+	return w.gz.Close()
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding header allows a
+// gzip response
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		// gzip;q=0 explicitly refuses it
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+		return true
+	}
+	return false
+}
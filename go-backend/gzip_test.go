@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"br", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/vectors", nil)
+		r.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsGzip(r); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGzipVectorResponse(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/vectors?seed=1&limit=50&dimensions=8", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handleVectorData(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(body, []byte("{")) {
+		t.Errorf("decompressed body starts %q, want a JSON object", body[:min(len(body), 20)])
+	}
+}
+
+func TestGzipNotModifiedHasNoBody(t *testing.T) {
+	const target = "/api/vectors?seed=1&limit=50&dimensions=8"
+	first := httptest.NewRecorder()
+	handleVectorData(first, httptest.NewRequest("GET", target, nil))
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("seeded response has no ETag")
+	}
+
+	r := httptest.NewRequest("GET", target, nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("If-None-Match", etag)
+	w := httptest.NewRecorder()
+	handleVectorData(w, r)
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want 304", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("304 body is %d bytes, want none", w.Body.Len())
+	}
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("304 Content-Encoding = %q, want none", got)
+	}
+}
+
+// BenchmarkGzipVectorResponse reports how much gzip shrinks a typical
+// 1000-vector response
+func BenchmarkGzipVectorResponse(b *testing.B) {
+	const target = "/api/vectors?seed=1&limit=1000&dimensions=100"
+	plain := httptest.NewRecorder()
+	handleVectorData(plain, httptest.NewRequest("GET", target, nil))
+
+	var compressed int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := httptest.NewRequest("GET", target, nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handleVectorData(w, r)
+		compressed = w.Body.Len()
+	}
+	b.ReportMetric(float64(plain.Body.Len()), "plain-bytes")
+	b.ReportMetric(float64(compressed), "gzip-bytes")
+	b.ReportMetric(float64(plain.Body.Len())/float64(compressed), "ratio")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HealthResponse is the body of the health and readiness endpoints
+type HealthResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func writeHealth(w http.ResponseWriter, status int, response HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleHealthz reports that the process is up. It never touches the
+// dataset, so it stays cheap for load balancer probes.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// handleReadyz reports whether the server can answer data requests. When
+// started with -data or -csv that requires the dataset to be loaded.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if datasetPath != "" && loadedItems() == nil {
+		writeHealth(w, http.StatusServiceUnavailable, HealthResponse{
+			Status: "unavailable",
+			Reason: "dataset not loaded",
+		})
+		return
+	}
+	writeHealth(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// The full distance matrix is kept in memory, so the endpoint refuses
+// inputs whose matrix would be too large
+const maxHierarchicalItems = 2000
+
+// Linkage criteria for merging clusters
+const (
+	linkageSingle   = "single"   // Distance between the closest members
+	linkageComplete = "complete" // Distance between the farthest members
+	linkageAverage  = "average"  // Mean distance over all member pairs
+)
+
+// HierarchicalMerge is one step of the dendrogram. Nodes 0 to n-1 are the
+// items in dataset order and merge i creates node n+i, as in SciPy's
+// linkage matrix.
+type HierarchicalMerge struct {
+	Left     int     `json:"left"`
+	Right    int     `json:"right"`
+	Distance float64 `json:"distance"`
+	Size     int     `json:"size"`
+}
+
+// HierarchicalResponse is the response structure for agglomerative
+// clustering. Merges is the whole dendrogram; each item's cluster_id comes
+// from cutting it into Clusters clusters.
+type HierarchicalResponse struct {
+	Data              []VectorItem        `json:"data"`
+	Total             int                 `json:"total"`
+	Merges            []HierarchicalMerge `json:"merges"`
+	Clusters          int                 `json:"clusters"`
+	Linkage           string              `json:"linkage"`
+	Metric            string              `json:"metric"`
+	DistanceThreshold *float64            `json:"distance_threshold,omitempty"`
+}
+
+// agglomerate builds the dendrogram of vectors with the nearest-neighbor
+// chain algorithm, which needs O(n²) time for the reducible linkages
+// supported here. Merges are returned in order of increasing distance.
+func agglomerate(ctx context.Context, vectors [][]float64, linkage string, distance func(a, b []float64) float64) ([]HierarchicalMerge, error) {
+	n := len(vectors)
+	d := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			dist := distance(vectors[i], vectors[j])
+			d[i*n+j] = dist
+			d[j*n+i] = dist
+		}
+	}
+
+	// Each cluster lives in the slot of one of its members
+	active := make([]bool, n)
+	size := make([]int, n)
+	for i := range active {
+		active[i] = true
+		size[i] = 1
+	}
+
+	type slotMerge struct {
+		a, b     int
+		distance float64
+	}
+	merges := make([]slotMerge, 0, n-1)
+	var chain []int
+	for len(merges) < n-1 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(chain) == 0 {
+			for i := range active {
+				if active[i] {
+					chain = append(chain, i)
+					break
+				}
+			}
+		}
+
+		// Find the nearest neighbor of the chain's tip, preferring the
+		// previous link on ties so the chain can't cycle
+		a := chain[len(chain)-1]
+		previous := -1
+		if len(chain) > 1 {
+			previous = chain[len(chain)-2]
+		}
+		nearest, best := -1, math.Inf(1)
+		for c := 0; c < n; c++ {
+			if active[c] && c != a && d[a*n+c] < best {
+				nearest, best = c, d[a*n+c]
+			}
+		}
+		if previous >= 0 && d[a*n+previous] <= best {
+			nearest, best = previous, d[a*n+previous]
+		}
+
+		if nearest != previous {
+			chain = append(chain, nearest)
+			continue
+		}
+
+		// a and previous are reciprocal nearest neighbors: merge them into
+		// a's slot and update its distances with the Lance-Williams formula
+		chain = chain[:len(chain)-2]
+		b := previous
+		merges = append(merges, slotMerge{a: a, b: b, distance: best})
+		for k := 0; k < n; k++ {
+			if !active[k] || k == a || k == b {
+				continue
+			}
+			dak, dbk := d[a*n+k], d[b*n+k]
+			var merged float64
+			switch linkage {
+			case linkageSingle:
+				merged = math.Min(dak, dbk)
+			case linkageComplete:
+				merged = math.Max(dak, dbk)
+			default:
+				merged = (float64(size[a])*dak + float64(size[b])*dbk) / float64(size[a]+size[b])
+			}
+			d[a*n+k] = merged
+			d[k*n+a] = merged
+		}
+		size[a] += size[b]
+		active[b] = false
+	}
+
+	// The chain finds merges out of order; sorting them and relabeling the
+	// slots gives the dendrogram
+	sort.SliceStable(merges, func(i, j int) bool {
+		return merges[i].distance < merges[j].distance
+	})
+	parent := newUnionFind(n)
+	node := make([]int, n) // Dendrogram node of each set, by root
+	nodeSize := make([]int, n)
+	for i := range node {
+		node[i] = i
+		nodeSize[i] = 1
+	}
+	tree := make([]HierarchicalMerge, len(merges))
+	for i, m := range merges {
+		ra, rb := parent.Find(m.a), parent.Find(m.b)
+		left, right := node[ra], node[rb]
+		if left > right {
+			left, right = right, left
+		}
+		merged := nodeSize[ra] + nodeSize[rb]
+		tree[i] = HierarchicalMerge{Left: left, Right: right, Distance: m.distance, Size: merged}
+
+		root := parent.Union(ra, rb)
+		node[root] = n + i
+		nodeSize[root] = merged
+	}
+	return tree, nil
+}
+
+// cutDendrogram applies the first merges of the dendrogram and returns each
+// item's cluster, numbered in order of first appearance
+func cutDendrogram(n int, merges []HierarchicalMerge, applied int) ([]int, int) {
+	sets := newUnionFind(n + len(merges))
+	for i := 0; i < applied; i++ {
+		sets.Union(n+i, merges[i].Left)
+		sets.Union(n+i, merges[i].Right)
+	}
+
+	labels := make([]int, n)
+	ids := make(map[int]int)
+	for i := range labels {
+		root := sets.Find(i)
+		id, ok := ids[root]
+		if !ok {
+			id = len(ids)
+			ids[root] = id
+		}
+		labels[i] = id
+	}
+	return labels, len(ids)
+}
+
+// unionFind is a disjoint-set forest with path halving
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	parent := make(unionFind, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	return parent
+}
+
+// Find returns the root of x's set
+func (u unionFind) Find(x int) int {
+	for u[x] != x {
+		u[x] = u[u[x]]
+		x = u[x]
+	}
+	return x
+}
+
+// Union joins the sets containing a and b and returns the new root
+func (u unionFind) Union(a, b int) int {
+	ra, rb := u.Find(a), u.Find(b)
+	u[rb] = ra
+	return ra
+}
+
+func handleHierarchical(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	metric, err := parseMetric(r.URL.Query(), metricEuclidean)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	distance, ok := metricDistance(metric)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "hierarchical clustering needs a distance; use metric cosine or euclidean")
+		return
+	}
+
+	linkage := r.URL.Query().Get("linkage")
+	if linkage == "" {
+		linkage = linkageAverage // Default
+	}
+	if linkage != linkageSingle && linkage != linkageComplete && linkage != linkageAverage {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("linkage must be %q, %q or %q", linkageSingle, linkageComplete, linkageAverage))
+		return
+	}
+
+	nClustersStr := r.URL.Query().Get("n_clusters")
+	thresholdStr := r.URL.Query().Get("distance_threshold")
+	if nClustersStr != "" && thresholdStr != "" {
+		writeError(w, http.StatusBadRequest, "set at most one of n_clusters and distance_threshold")
+		return
+	}
+
+	nClusters := params.Clusters // Default
+	if nClustersStr != "" {
+		parsedNClusters, err := strconv.Atoi(nClustersStr)
+		if err != nil || parsedNClusters <= 0 {
+			writeError(w, http.StatusBadRequest, "n_clusters must be a positive integer")
+			return
+		}
+		nClusters = parsedNClusters
+	}
+
+	var threshold *float64
+	if thresholdStr != "" {
+		parsedThreshold, err := strconv.ParseFloat(thresholdStr, 64)
+		if err != nil || !(parsedThreshold >= 0) {
+			writeError(w, http.StatusBadRequest, "distance_threshold must be a non-negative number")
+			return
+		}
+		threshold = &parsedThreshold
+	}
+
+	// Refuse oversized requests before generating anything
+	if datasetSize(params) > maxHierarchicalItems {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("hierarchical clustering is limited to %d vectors", maxHierarchicalItems))
+		return
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	if len(data) > maxHierarchicalItems {
+		// The loaded dataset grew in the meantime
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("hierarchical clustering is limited to %d vectors", maxHierarchicalItems))
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	merges, err := agglomerate(r.Context(), vectors, linkage, distance)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+
+	// Cut the dendrogram after the merges below the threshold, or when the
+	// requested number of clusters is left
+	applied := max(len(data)-nClusters, 0)
+	if threshold != nil {
+		applied = sort.Search(len(merges), func(i int) bool {
+			return merges[i].Distance > *threshold
+		})
+	}
+	labels, clusters := cutDendrogram(len(data), merges, applied)
+	for i := range data {
+		data[i].ClusterID = &labels[i]
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := HierarchicalResponse{
+		Data:              data,
+		Total:             len(data),
+		Merges:            merges,
+		Clusters:          clusters,
+		Linkage:           linkage,
+		Metric:            metric.Name,
+		DistanceThreshold: threshold,
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// The most bins a histogram may ask for
+const maxHistogramBins = 1000
+
+// HistogramResponse is the response structure for a histogram of one
+// vector dimension. Counts[i] is the number of values in [Edges[i],
+// Edges[i+1]), with the last bin closed on the right as in NumPy. When
+// min or max was given, values outside the range are counted in Underflow
+// and Overflow instead.
+type HistogramResponse struct {
+	Dim       int       `json:"dim"`
+	Edges     []float64 `json:"edges"`
+	Counts    []int     `json:"counts"`
+	Underflow int       `json:"underflow"`
+	Overflow  int       `json:"overflow"`
+	Total     int       `json:"total"`
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+	Mean      float64   `json:"mean"`
+	StdDev    float64   `json:"std_dev"`
+}
+
+// binValues sorts values into bins equal-width bins over [lo, hi]
+func binValues(values []float64, bins int, lo, hi float64) ([]float64, []int, int, int) {
+	edges := make([]float64, bins+1)
+	width := (hi - lo) / float64(bins)
+	for i := range edges {
+		edges[i] = lo + float64(i)*width
+	}
+	edges[bins] = hi
+
+	counts := make([]int, bins)
+	underflow, overflow := 0, 0
+	for _, x := range values {
+		switch {
+		case x < lo:
+			underflow++
+		case x > hi:
+			overflow++
+		default:
+			// The right edge belongs to the last bin
+			bin := min(int((x-lo)/width), bins-1)
+			counts[bin]++
+		}
+	}
+	return edges, counts, underflow, overflow
+}
+
+func handleHistogram(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters, err := parseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dimensions := params.vectorDimensions()
+	dim, err := strconv.Atoi(r.URL.Query().Get("dim"))
+	if err != nil || dim < 0 || dim >= dimensions {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("dim must be an integer between 0 and %d", dimensions-1))
+		return
+	}
+
+	bins := 20 // Default
+	if binsStr := r.URL.Query().Get("bins"); binsStr != "" {
+		parsedBins, err := strconv.Atoi(binsStr)
+		if err != nil || parsedBins <= 0 || parsedBins > maxHistogramBins {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("bins must be an integer between 1 and %d", maxHistogramBins))
+			return
+		}
+		bins = parsedBins
+	}
+
+	// An explicit range fixes the bins; otherwise they span the data
+	var lo, hi *float64
+	if minStr := r.URL.Query().Get("min"); minStr != "" {
+		parsedMin, err := strconv.ParseFloat(minStr, 64)
+		if err != nil || math.IsInf(parsedMin, 0) || math.IsNaN(parsedMin) {
+			writeError(w, http.StatusBadRequest, "min must be a finite number")
+			return
+		}
+		lo = &parsedMin
+	}
+	if maxStr := r.URL.Query().Get("max"); maxStr != "" {
+		parsedMax, err := strconv.ParseFloat(maxStr, 64)
+		if err != nil || math.IsInf(parsedMax, 0) || math.IsNaN(parsedMax) {
+			writeError(w, http.StatusBadRequest, "max must be a finite number")
+			return
+		}
+		hi = &parsedMax
+	}
+	if lo != nil && hi != nil && !(*lo < *hi) {
+		writeError(w, http.StatusBadRequest, "min must be less than max")
+		return
+	}
+
+	// Generate data, keeping only the requested component of each vector
+	next := datasetIterator(params)
+	var values []float64
+	sum := 0.0
+	for {
+		if err := r.Context().Err(); err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		item, ok := next()
+		if !ok {
+			break
+		}
+		if !matchesFilters(item, filters) {
+			continue
+		}
+		values = append(values, item.Vector[dim])
+		sum += item.Vector[dim]
+	}
+
+	response := HistogramResponse{Dim: dim, Total: len(values)}
+	if len(values) > 0 {
+		response.Min, response.Max = values[0], values[0]
+		for _, x := range values {
+			response.Min = math.Min(response.Min, x)
+			response.Max = math.Max(response.Max, x)
+		}
+		response.Mean = sum / float64(len(values))
+		variance := 0.0
+		for _, x := range values {
+			variance += (x - response.Mean) * (x - response.Mean)
+		}
+		response.StdDev = math.Sqrt(variance / float64(len(values)))
+	}
+
+	low, high := response.Min, response.Max
+	if lo != nil {
+		low = *lo
+	}
+	if hi != nil {
+		high = *hi
+	}
+	if !(low < high) {
+		// All values are equal, or the one bound given lies beyond them;
+		// fall back to a unit-wide range
+		if lo != nil {
+			high = low + 1
+		} else if hi != nil {
+			low = high - 1
+		} else {
+			low, high = low-0.5, high+0.5
+		}
+	}
+	response.Edges, response.Counts, response.Underflow, response.Overflow = binValues(values, bins, low, high)
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(response)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strconv"
+)
+
+// Formats for generated item IDs
+const (
+	idFormatSequential = "sequential" // The item index: "0", "1", ...
+	idFormatUUID       = "uuid"       // UUID v5 of the seed and index
+)
+
+// Formats for generated item keys
+const (
+	keyFormatRandom = "random" // Drawn from the item's random source
+	keyFormatHash   = "hash"   // Hash of the seed and index
+)
+
+// keyLength is the length of generated keys
+const keyLength = 8
+
+// idNamespace is the UUID v5 namespace for generated IDs, itself derived
+// from the RFC 4122 URL namespace
+var idNamespace = uuidV5([16]byte{
+	0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1,
+	0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8,
+}, "https://github.com/hev/avs-visualizer")
+
+// itemID returns the ID of the generated item at index. UUIDs depend
+// only on the seed and index, so a seeded dataset keeps its IDs.
+func itemID(format string, seed int64, index int) string {
+	if format == idFormatUUID {
+		return formatUUID(uuidV5(idNamespace, fmt.Sprintf("%d/%d", seed, index)))
+	}
+	return strconv.Itoa(index)
+}
+
+// hashedKey returns a key for the generated item at index that depends
+// only on the seed and index, drawn from the same alphabet as random keys
+func hashedKey(seed int64, index int) string {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	sum := sha1.Sum([]byte(fmt.Sprintf("key/%d/%d", seed, index)))
+	key := make([]byte, keyLength)
+	for i := range key {
+		key[i] = chars[int(sum[i])%len(chars)]
+	}
+	return string(key)
+}
+
+// uuidV5 returns the name-based UUID of name within namespace (RFC 4122
+// section 4.3)
+func uuidV5(namespace [16]byte, name string) [16]byte {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+
+	var uuid [16]byte
+	copy(uuid[:], h.Sum(nil))
+	uuid[6] = uuid[6]&0x0f | 0x50 // Version 5
+	uuid[8] = uuid[8]&0x3f | 0x80 // RFC 4122 variant
+	return uuid
+}
+
+func formatUUID(uuid [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Longest line an import accepts, enough for one item with a few named
+// vectors of maxDimensions each
+const maxImportLineBytes = 16 << 20
+
+// Imports carry whole datasets, so they may be larger than appends
+const maxImportBodyBytes = 1 << 30
+
+// Most rejected lines an import response describes; the rest are only
+// counted
+const maxImportRejections = 20
+
+var errImportLineTooLong = fmt.Errorf("line exceeds %d bytes", maxImportLineBytes)
+
+// ImportRejection describes a line that was not imported. Lines count
+// from 1.
+type ImportRejection struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportResponse is the response structure for JSONL imports. Total is the
+// size of the loaded dataset afterwards; Rejections describes the first
+// rejected lines.
+type ImportResponse struct {
+	Imported   int               `json:"imported"`
+	Rejected   int               `json:"rejected"`
+	Total      int               `json:"total"`
+	Rejections []ImportRejection `json:"rejections"`
+}
+
+// readImportLine returns the next line of r without its line ending. A
+// line longer than maxImportLineBytes is skipped and reported with
+// errImportLineTooLong, after which reading can carry on.
+func readImportLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxImportLineBytes {
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = r.ReadSlice('\n')
+			}
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			return nil, errImportLineTooLong
+		}
+		line = append(line, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err == io.EOF && len(line) > 0 {
+			err = nil
+		}
+		return bytes.TrimRight(line, "\r\n"), err
+	}
+}
+
+// handleImportVectors loads a JSON-lines body, one item per line, into the
+// in-memory dataset. The body is decoded line by line as it arrives, so it
+// is never held whole. Lines that don't parse or whose vectors don't match
+// the dataset's are rejected and the rest imported; the accepted items are
+// added in one step at the end, so a failed upload changes nothing. The
+// items are appended to the loaded dataset, or replace it with
+// replace=true; when serving synthetic data they become the dataset. Like
+// appends it changes what every client is served, so it takes the admin
+// token.
+func handleImportVectors(w http.ResponseWriter, r *http.Request) {
+	if !beginPOST(w, r) {
+		return
+	}
+	if !beginAdmin(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	replace := false // Default
+	if replaceStr := r.URL.Query().Get("replace"); replaceStr != "" {
+		parsedReplace, err := strconv.ParseBool(replaceStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "replace must be true or false")
+			return
+		}
+		replace = parsedReplace
+	}
+
+	// New items must match the dataset they join, or else the first
+	// accepted line
+	var reference *VectorItem
+	if existing := loadedItems(); existing != nil && !replace {
+		reference = &existing[0]
+	}
+
+	response := ImportResponse{Rejections: []ImportRejection{}}
+	reject := func(line int, err error) {
+		response.Rejected++
+		if len(response.Rejections) < maxImportRejections {
+			response.Rejections = append(response.Rejections, ImportRejection{Line: line, Error: err.Error()})
+		}
+	}
+
+	var items []VectorItem
+	reader := bufio.NewReaderSize(http.MaxBytesReader(w, r.Body, maxImportBodyBytes), 64<<10)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := readImportLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, errImportLineTooLong) {
+			reject(lineNumber, err)
+			continue
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("reading request body: %v", err))
+			return
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var item VectorItem
+		if err := json.Unmarshal(line, &item); err != nil {
+			reject(lineNumber, fmt.Errorf("invalid item: %v", err))
+			continue
+		}
+		if reference == nil {
+			if _, err := checkDimensions([]VectorItem{item}); err != nil {
+				reject(lineNumber, err)
+				continue
+			}
+		} else if err := sameLayout(*reference, item); err != nil {
+			reject(lineNumber, err)
+			continue
+		}
+
+		if item.Metadata == nil {
+			item.Metadata = map[string]interface{}{}
+		}
+		if reference == nil {
+			reference = &item
+		}
+		if len(items) == maxLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("an import must not exceed %d vectors", maxLimit))
+			return
+		}
+		items = append(items, item)
+	}
+
+	// Add the items, checking again in case the dataset changed while the
+	// body was read
+	loadedMu.Lock()
+	if len(items) > 0 {
+		switch {
+		case replace || loadedDataset == nil:
+			loadedDataset = items
+		case sameLayout(loadedDataset[0], items[0]) != nil:
+			loadedMu.Unlock()
+			writeError(w, http.StatusConflict, "the dataset changed during the import; retry it")
+			return
+		default:
+			loadedDataset = append(loadedDataset, items...)
+		}
+	}
+	response.Imported = len(items)
+	response.Total = len(loadedDataset)
+	loadedMu.Unlock()
+
+	// Cached responses and indexes describe the dataset before the import
+	if len(items) > 0 {
+		if vectorCache != nil {
+			vectorCache.Clear()
+		}
+		annIndexes.Clear()
+		normDatasets.Clear()
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(response)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// importStatus posts body to the import endpoint with the given
+// Authorization header
+func importStatus(authorization, body string) int {
+	r := httptest.NewRequest("POST", "/api/vectors/import?replace=true", strings.NewReader(body))
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	handleImportVectors(w, r)
+	return w.Code
+}
+
+func TestImportRequiresAdminToken(t *testing.T) {
+	defer func(token string) { adminToken = token }(adminToken)
+	withLoadedDataset(t, nil)
+
+	tests := []struct {
+		token         string
+		authorization string
+		want          int
+	}{
+		{"", "", http.StatusForbidden},
+		{"", "Bearer secret", http.StatusForbidden},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		adminToken = tt.token
+		if got := importStatus(tt.authorization, `{"id":"x","vector":[1]}`); got != tt.want {
+			t.Errorf("token %q, Authorization %q: status %d, want %d", tt.token, tt.authorization, got, tt.want)
+		}
+		if tt.want != http.StatusOK && loadedItems() != nil {
+			t.Fatalf("token %q, Authorization %q: refused import still loaded data", tt.token, tt.authorization)
+		}
+	}
+}
+
+func TestImportIsCapped(t *testing.T) {
+	defer func(token string) { adminToken = token }(adminToken)
+	adminToken = "secret"
+	withLoadedDataset(t, nil)
+
+	var body strings.Builder
+	for i := 0; i <= maxLimit; i++ {
+		fmt.Fprintf(&body, "{\"id\":\"%d\",\"vector\":[1]}\n", i)
+	}
+	if got := importStatus("Bearer secret", body.String()); got != http.StatusBadRequest {
+		t.Errorf("importing %d vectors: status %d, want 400", maxLimit+1, got)
+	}
+	if loadedItems() != nil {
+		t.Error("an oversized import loaded data")
+	}
+}
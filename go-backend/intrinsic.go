@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Default standard deviation of the noise added off the latent subspace
+const defaultIntrinsicNoise = 0.01
+
+// Center seed for -stable-centers when -default-seed is not set
+const fixedCenterSeed = 1
+
+// stableCenterSeed seeds the cluster centers of every dataset when
+// -stable-centers is set, so only the points around them vary between
+// requests; nil draws centers from each request's seed
+var stableCenterSeed *int64
+
+// randomBasis returns rank orthonormal vectors of the given dimensions,
+// spanning a uniformly random subspace. They come from Gram-Schmidt
+// orthogonalization of Gaussian vectors.
+func randomBasis(rng *rand.Rand, rank, dimensions int) [][]float64 {
+	basis := make([][]float64, 0, rank)
+	for len(basis) < rank {
+		v := make([]float64, dimensions)
+		for j := range v {
+			v[j] = rng.NormFloat64()
+		}
+		for _, b := range basis {
+			projection := dotProduct(v, b)
+			for j := range v {
+				v[j] -= projection * b[j]
+			}
+		}
+		norm := math.Sqrt(dotProduct(v, v))
+		if norm < 1e-9 {
+			continue // Numerically in the span already; draw again
+		}
+		for j := range v {
+			v[j] /= norm
+		}
+		basis = append(basis, v)
+	}
+	return basis
+}
+
+// embed maps a latent point to the full space spanned by basis
+func embed(basis [][]float64, latent []float64, dimensions int) []float64 {
+	vector := make([]float64, dimensions)
+	for k, b := range basis {
+		for j := range vector {
+			vector[j] += latent[k] * b[j]
+		}
+	}
+	return vector
+}
+
+// generateSpace draws a dataset's cluster centers and, for datasets with a
+// low intrinsic dimensionality, the basis they are embedded with. Only the
+// centers are drawn otherwise, so other datasets keep their random
+// sequence. With -stable-centers both come from the fixed center seed
+// instead of rng, so they are the same for every request of a shape.
+//
+// With IntrinsicDim set, centers and points are drawn exactly as usual but
+// with IntrinsicDim components, in latent coordinates. Each point is then
+// mapped through the basis, a random orthonormal basis of an
+// IntrinsicDim-dimensional subspace, and offset by Gaussian noise of
+// standard deviation IntrinsicNoise in every dimension. The embedding
+// preserves distances, so spread and separation keep their meaning, and
+// PCA finds IntrinsicDim components carrying nearly all the variance
+// followed by a floor of noise.
+func generateSpace(rng *rand.Rand, config generatorConfig) ([][]float64, [][]float64) {
+	if stableCenterSeed != nil {
+		rng = rand.New(rand.NewSource(*stableCenterSeed))
+	}
+	if config.IntrinsicDim == 0 {
+		return generateClusterCenters(rng, config.Clusters, config.Dimensions, config.Separation), nil
+	}
+	centers := generateClusterCenters(rng, config.Clusters, config.IntrinsicDim, config.Separation)
+	return centers, randomBasis(rng, config.IntrinsicDim, config.Dimensions)
+}
+
+// clusterCenters returns the cluster centers of the dataset rng and config
+// generate, in the full space and the coordinate range
+func clusterCenters(rng *rand.Rand, config generatorConfig) [][]float64 {
+	centers, basis := generateSpace(rng, config)
+	for i, center := range centers {
+		if basis != nil {
+			centers[i] = embed(basis, center, config.Dimensions)
+		}
+		if config.CoordMin != config.CoordMax {
+			rescaleCoords(centers[i], config)
+		}
+	}
+	return centers
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// handleVectorByID returns the item with the given ID from the same logical
+// dataset /api/vectors pages through. Generation stops at the match, so
+// low sequential IDs are cheap to fetch.
+func handleVectorByID(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parsePagedParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	output, err := parseOutputOptions(r.URL.Query(), params.vectorDimensions())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Unseeded synthetic data changes on every request, so its IDs can't
+	// be looked up again
+	if loadedItems() == nil && !params.Seeded {
+		writeError(w, http.StatusBadRequest, "seed is required to look up generated vectors")
+		return
+	}
+
+	// Generate data
+	id := r.PathValue("id")
+	next := datasetIterator(params)
+	for {
+		if err := r.Context().Err(); err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		item, ok := next()
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("vector %q not found", id))
+			return
+		}
+		if item.ID != id {
+			continue
+		}
+
+		// Return response
+		w.Header().Set("Content-Type", "application/json")
+		newJSONEncoder(w, r).Encode(output.Apply(item))
+		return
+	}
+}
+
+// handleRandomVector returns one item picked uniformly from the dataset,
+// or from the items matching the filters. Seeded requests always pick the
+// same item.
+func handleRandomVector(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parsePagedParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters, err := parseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	output, err := parseOutputOptions(r.URL.Query(), params.vectorDimensions())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Generate data
+	picked, _ := reservoirSample(datasetIterator(params), filters, 1, params.sampleRand())
+	if len(picked) == 0 {
+		writeError(w, http.StatusNotFound, "no vectors match the filters")
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(output.Apply(picked[0]))
+}
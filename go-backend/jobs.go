@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Job statuses
+const (
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// Results are whole datasets held in memory, so the store keeps only a few
+// jobs and forgets finished ones after a while
+const (
+	maxJobs      = 16
+	jobRetention = 10 * time.Minute
+)
+
+// JobStatus is the response structure for a generation job. Progress is
+// the percentage of items generated so far.
+type JobStatus struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Progress   float64    `json:"progress"`
+	Generated  int        `json:"generated"`
+	Size       int        `json:"size"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// job is one asynchronous generation. The goroutine running it counts
+// generated items on progress; every other field is guarded by the store's
+// mutex.
+type job struct {
+	id       string
+	size     int
+	progress atomic.Int64
+	cancel   context.CancelFunc
+	created  time.Time
+
+	status   string
+	err      string
+	finished time.Time
+	result   []byte // Encoded VectorDataResponse once completed
+}
+
+// jobStore holds the jobs of this server. It is safe for concurrent use.
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+var jobs = &jobStore{jobs: make(map[string]*job)}
+
+// Start registers a job generating size items and runs work for it in a
+// new goroutine. It fails when the store is full of jobs that are still
+// running or recently finished.
+func (s *jobStore) Start(size int, work func(ctx context.Context) ([]byte, error)) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(time.Now())
+	if len(s.jobs) >= maxJobs {
+		return nil, fmt.Errorf("too many jobs; try again later")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:      newJobID(),
+		size:    size,
+		cancel:  cancel,
+		created: time.Now(),
+		status:  jobRunning,
+	}
+	s.jobs[j.id] = j
+
+	go func() {
+		defer cancel()
+		result, err := work(withGenerationProgress(ctx, &j.progress))
+		s.finish(j, result, err)
+	}()
+	return j, nil
+}
+
+// finish records the outcome of a job's work. A cancelled job stays
+// cancelled whatever its work returned.
+func (s *jobStore) finish(j *job, result []byte, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j.status != jobRunning {
+		return
+	}
+	j.finished = time.Now()
+	if err != nil {
+		j.status = jobFailed
+		j.err = err.Error()
+		return
+	}
+	j.status = jobCompleted
+	j.result = result
+}
+
+// Get returns the job with the given ID
+func (s *jobStore) Get(id string) (*job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(time.Now())
+	j, ok := s.jobs[id]
+	return j, ok
+}
+
+// Cancel stops a running job; its status stays available until it
+// expires. Cancelling a finished job discards it and its result.
+func (s *jobStore) Cancel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return false
+	}
+	if j.status != jobRunning {
+		delete(s.jobs, id)
+		return true
+	}
+	j.cancel()
+	j.status = jobCancelled
+	j.finished = time.Now()
+	return true
+}
+
+// ClearFinished forgets every job that is no longer running, with its
+// result, and returns how many there were
+func (s *jobStore) ClearFinished() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cleared := 0
+	for id, j := range s.jobs {
+		if j.status != jobRunning {
+			delete(s.jobs, id)
+			cleared++
+		}
+	}
+	return cleared
+}
+
+// Status reports the job's current state
+func (s *jobStore) Status(j *job) JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := JobStatus{
+		ID:        j.id,
+		Status:    j.status,
+		Generated: min(int(j.progress.Load()), j.size),
+		Size:      j.size,
+		Error:     j.err,
+		CreatedAt: j.created,
+	}
+	if j.status == jobCompleted {
+		status.Generated = j.size
+	}
+	if j.size > 0 {
+		status.Progress = 100 * float64(status.Generated) / float64(j.size)
+	}
+	if j.status != jobRunning {
+		finished := j.finished
+		status.FinishedAt = &finished
+	}
+	return status
+}
+
+// Result returns the encoded result of a completed job
+func (s *jobStore) Result(j *job) ([]byte, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return j.result, j.status
+}
+
+// expire forgets jobs that finished more than jobRetention ago. The caller
+// must hold s.mu.
+func (s *jobStore) expire(now time.Time) {
+	for id, j := range s.jobs {
+		if j.status != jobRunning && now.Sub(j.finished) > jobRetention {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// newJobID returns a random, unguessable job ID
+func newJobID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+type progressKey struct{}
+
+// withGenerationProgress returns a context whose generation work counts
+// finished items on progress
+func withGenerationProgress(ctx context.Context, progress *atomic.Int64) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// generationProgress returns ctx's progress counter, or nil if it has none
+func generationProgress(ctx context.Context) *atomic.Int64 {
+	progress, _ := ctx.Value(progressKey{}).(*atomic.Int64)
+	return progress
+}
+
+func handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	if !beginPOST(w, r) {
+		return
+	}
+
+	// The job is described by the same query string as GET /api/vectors,
+	// and its result is the response that request would get
+	params, err := parsePagedParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters, err := parseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	output, err := parseOutputOptions(r.URL.Query(), params.vectorDimensions())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	order, err := parseSort(r.URL.Query(), params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	output.Nearest, err = parseNearest(r.URL.Query(), params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if r.URL.Query().Get("sample") != "" {
+		writeError(w, http.StatusBadRequest, "sample is not supported for jobs")
+		return
+	}
+
+	// Generate data
+	j, err := jobs.Start(datasetSize(params), func(ctx context.Context) ([]byte, error) {
+		data, err := datasetItems(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		body, err := json.Marshal(vectorPage(data, params, filters, order, output))
+		if err != nil {
+			return nil, err
+		}
+		return append(body, '\n'), nil
+	})
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+j.id)
+	w.WriteHeader(http.StatusAccepted)
+	newJSONEncoder(w, r).Encode(jobs.Status(j))
+}
+
+// handleJob reports a job's status on GET and cancels it on DELETE
+func handleJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	j, ok := jobs.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("job %q not found", id))
+		return
+	}
+
+	if r.Method == "DELETE" {
+		jobs.Cancel(id)
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(jobs.Status(j))
+}
+
+func handleJobResult(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	id := r.PathValue("id")
+	j, ok := jobs.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("job %q not found", id))
+		return
+	}
+
+	body, status := jobs.Result(j)
+	if status != jobCompleted {
+		writeError(w, http.StatusConflict, fmt.Sprintf("job %q is %s", id, status))
+		return
+	}
+
+	// Return response
+	if acceptsGzip(r) {
+		gz := newGzipResponseWriter(w)
+		defer gz.Close()
+		w = gz
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(indentJSON(r, body))
+}
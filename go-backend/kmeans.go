@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+)
+
+// Lloyd's algorithm stops when no assignment changes, or after the
+// iteration cap
+const defaultKMeansMaxIterations = 100
+
+// The most clusters k-means may ask for
+const maxKMeansK = 100
+
+// KMeansResponse is the response structure for k-means clustering
+type KMeansResponse struct {
+	Data       []VectorItem `json:"data"`
+	Total      int          `json:"total"`
+	Centroids  [][]float64  `json:"centroids"`
+	Iterations int          `json:"iterations"`
+	Converged  bool         `json:"converged"`
+}
+
+// kmeansResult holds the outcome of a k-means run
+type kmeansResult struct {
+	Assignments []int
+	Centroids   [][]float64
+	Iterations  int
+	Converged   bool
+}
+
+// kMeans clusters vectors into k groups with Lloyd's algorithm, seeding the
+// centroids with k-means++ drawn from rng. It gives up with the context's
+// error if ctx ends first, checking once per iteration.
+func kMeans(ctx context.Context, vectors [][]float64, k, maxIterations int, rng *rand.Rand) (kmeansResult, error) {
+	centroids, err := kMeansPlusPlus(ctx, vectors, k, rng)
+	if err != nil {
+		return kmeansResult{}, err
+	}
+	assignments := make([]int, len(vectors))
+	for i := range assignments {
+		assignments[i] = -1
+	}
+
+	result := kmeansResult{Assignments: assignments, Centroids: centroids}
+	for result.Iterations < maxIterations {
+		if err := ctx.Err(); err != nil {
+			return kmeansResult{}, err
+		}
+		result.Iterations++
+
+		// Assignment step
+		changed := false
+		for i, v := range vectors {
+			nearest := nearestCentroid(v, centroids)
+			if nearest != assignments[i] {
+				assignments[i] = nearest
+				changed = true
+			}
+		}
+		if !changed {
+			result.Converged = true
+			break
+		}
+
+		// Update step; an empty cluster keeps its previous centroid
+		sums := make([][]float64, k)
+		counts := make([]int, k)
+		for i, v := range vectors {
+			c := assignments[i]
+			if sums[c] == nil {
+				sums[c] = make([]float64, len(v))
+			}
+			for j, x := range v {
+				sums[c][j] += x
+			}
+			counts[c]++
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue
+			}
+			for j := range sums[c] {
+				sums[c][j] /= float64(counts[c])
+			}
+			centroids[c] = sums[c]
+		}
+	}
+
+	return result, nil
+}
+
+// kMeansPlusPlus picks k initial centroids, each chosen with probability
+// proportional to its squared distance from the centroids picked so far
+func kMeansPlusPlus(ctx context.Context, vectors [][]float64, k int, rng *rand.Rand) ([][]float64, error) {
+	centroids := make([][]float64, 0, k)
+	centroids = append(centroids, copyVector(vectors[rng.Intn(len(vectors))]))
+
+	distances := make([]float64, len(vectors))
+	for len(centroids) < k {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		total := 0.0
+		for i, v := range vectors {
+			distances[i] = squaredEuclidean(v, centroids[nearestCentroid(v, centroids)])
+			total += distances[i]
+		}
+
+		// Every point coincides with a centroid; fall back to uniform
+		if total == 0 {
+			centroids = append(centroids, copyVector(vectors[rng.Intn(len(vectors))]))
+			continue
+		}
+
+		target := rng.Float64() * total
+		chosen := len(vectors) - 1
+		for i, d := range distances {
+			target -= d
+			if target < 0 {
+				chosen = i
+				break
+			}
+		}
+		centroids = append(centroids, copyVector(vectors[chosen]))
+	}
+
+	return centroids, nil
+}
+
+// nearestCentroid returns the index of the centroid closest to v
+func nearestCentroid(v []float64, centroids [][]float64) int {
+	nearest := 0
+	best := squaredEuclidean(v, centroids[0])
+	for c := 1; c < len(centroids); c++ {
+		if d := squaredEuclidean(v, centroids[c]); d < best {
+			nearest, best = c, d
+		}
+	}
+	return nearest
+}
+
+func copyVector(v []float64) []float64 {
+	c := make([]float64, len(v))
+	copy(c, v)
+	return c
+}
+
+func handleKMeans(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	k := 5 // Default
+	if kStr := r.URL.Query().Get("k"); kStr != "" {
+		parsedK, err := strconv.Atoi(kStr)
+		if err != nil || parsedK <= 0 || parsedK > maxKMeansK {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("k must be an integer between 1 and %d", maxKMeansK))
+			return
+		}
+		k = parsedK
+	}
+
+	maxIterations := defaultKMeansMaxIterations
+	if maxIterStr := r.URL.Query().Get("max_iter"); maxIterStr != "" {
+		parsedMaxIter, err := strconv.Atoi(maxIterStr)
+		if err != nil || parsedMaxIter <= 0 {
+			writeError(w, http.StatusBadRequest, "max_iter must be a positive integer")
+			return
+		}
+		maxIterations = parsedMaxIter
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	if k > len(data) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("k must not exceed the number of vectors (%d)", len(data)))
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	// Seed the centroids from the generation seed so results are reproducible
+	result, err := kMeans(r.Context(), vectors, k, maxIterations, params.Rand())
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	for i := range data {
+		clusterID := result.Assignments[i]
+		data[i].ClusterID = &clusterID
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := KMeansResponse{
+		Data:       data,
+		Total:      len(data),
+		Centroids:  result.Centroids,
+		Iterations: result.Iterations,
+		Converged:  result.Converged,
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKMeansStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vectors := [][]float64{{0}, {1}, {10}, {11}}
+	if _, err := kMeans(ctx, vectors, 2, defaultKMeansMaxIterations, rand.New(rand.NewSource(1))); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestKMeansKIsCapped(t *testing.T) {
+	target := fmt.Sprintf("/api/vectors/kmeans?seed=1&limit=%d&k=%d", maxKMeansK+1, maxKMeansK+1)
+	w := httptest.NewRecorder()
+	handleKMeans(w, httptest.NewRequest("GET", target, nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400: %s", w.Code, w.Body)
+	}
+}
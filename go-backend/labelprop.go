@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Building the neighbor graph compares every pair of points, so the
+// endpoint refuses larger inputs
+const maxLabelPropagationItems = 5000
+
+// Propagation stops once no label score moves by more than this
+const labelPropagationTolerance = 1e-5
+
+// Seed bodies map IDs to short labels
+const maxLabelSeedBodyBytes = 16 << 20
+
+// LabelPrediction is the propagated label of one item. Label is omitted
+// when no seed label reaches the item through the graph. Confidence is
+// the share of the item's label score held by Label; seeds keep their
+// label with confidence 1.
+type LabelPrediction struct {
+	ID         string  `json:"id"`
+	Label      string  `json:"label,omitempty"`
+	Confidence float64 `json:"confidence"`
+	Seed       bool    `json:"seed"`
+}
+
+// LabelPropagationResponse is the response structure for label
+// propagation. Labels lists the distinct seed labels in the order used to
+// break ties; Counts is the number of items predicted for each.
+type LabelPropagationResponse struct {
+	Items      []LabelPrediction `json:"items"`
+	Labels     []string          `json:"labels"`
+	Counts     map[string]int    `json:"counts"`
+	K          int               `json:"k"`
+	Metric     string            `json:"metric"`
+	Iterations int               `json:"iterations"`
+	Converged  bool              `json:"converged"`
+	Total      int               `json:"total"`
+}
+
+// labelEdge is a weighted link to a neighbor
+type labelEdge struct {
+	to     int
+	weight float64
+}
+
+// labelGraph returns the symmetric k-nearest-neighbor graph of vectors.
+// Edges are weighted with a Gaussian kernel whose width is the mean
+// neighbor distance, so a point listens most to its closest neighbors. It
+// gives up with the context's error if ctx ends first.
+func labelGraph(ctx context.Context, vectors [][]float64, k int, distance func(a, b []float64) float64) ([][]labelEdge, error) {
+	n := len(vectors)
+	neighbors := make([][]int, n)
+	distances := make([][]float64, n)
+
+	order := make([]int, 0, n-1)
+	row := make([]float64, n)
+	total, count := 0.0, 0
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		order = order[:0]
+		for j := 0; j < n; j++ {
+			if j != i {
+				row[j] = distance(vectors[i], vectors[j])
+				order = append(order, j)
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return row[order[a]] < row[order[b]]
+		})
+
+		neighbors[i] = append([]int(nil), order[:k]...)
+		distances[i] = make([]float64, k)
+		for m, j := range neighbors[i] {
+			distances[i][m] = row[j]
+			total += row[j]
+			count++
+		}
+	}
+
+	sigma := total / float64(count)
+	if sigma == 0 {
+		sigma = 1
+	}
+
+	// Link both ways, keeping one edge per pair
+	weights := make([]map[int]float64, n)
+	for i := range weights {
+		weights[i] = make(map[int]float64)
+	}
+	for i := range neighbors {
+		for m, j := range neighbors[i] {
+			d := distances[i][m] / sigma
+			w := math.Exp(-d * d)
+			weights[i][j] = w
+			weights[j][i] = w
+		}
+	}
+
+	graph := make([][]labelEdge, n)
+	for i, links := range weights {
+		graph[i] = make([]labelEdge, 0, len(links))
+		for j, w := range links {
+			graph[i] = append(graph[i], labelEdge{to: j, weight: w})
+		}
+		// Map order is random; fix the summation order so results repeat
+		sort.Slice(graph[i], func(a, b int) bool {
+			return graph[i][a].to < graph[i][b].to
+		})
+	}
+	return graph, nil
+}
+
+// propagateLabels spreads the seed labels over graph. seeds[i] is the
+// label index of item i, or -1 for unlabeled items. Every iteration sets
+// each unlabeled item's scores to the weighted mean of its neighbors'
+// from the previous iteration, while seeds stay fixed. It returns the
+// scores, the number of iterations run, and whether they converged.
+func propagateLabels(ctx context.Context, graph [][]labelEdge, seeds []int, labels, maxIterations int) ([][]float64, int, bool, error) {
+	n := len(graph)
+	scores := make([][]float64, n)
+	next := make([][]float64, n)
+	for i := range scores {
+		scores[i] = make([]float64, labels)
+		next[i] = make([]float64, labels)
+		if seeds[i] >= 0 {
+			scores[i][seeds[i]] = 1
+			next[i][seeds[i]] = 1
+		}
+	}
+
+	for iteration := 1; iteration <= maxIterations; iteration++ {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, false, err
+		}
+		change := 0.0
+		for i, edges := range graph {
+			if seeds[i] >= 0 {
+				continue
+			}
+			clear(next[i])
+			total := 0.0
+			for _, edge := range edges {
+				for l, score := range scores[edge.to] {
+					next[i][l] += edge.weight * score
+				}
+				total += edge.weight
+			}
+			for l := range next[i] {
+				if total > 0 {
+					next[i][l] /= total
+				}
+				change = math.Max(change, math.Abs(next[i][l]-scores[i][l]))
+			}
+		}
+		scores, next = next, scores
+		if change < labelPropagationTolerance {
+			return scores, iteration, true, nil
+		}
+	}
+	return scores, maxIterations, false, nil
+}
+
+// parseLabelPropagationOptions reads the k and max_iterations query
+// parameters
+func parseLabelPropagationOptions(query url.Values) (int, int, error) {
+	k := 10 // Default
+	if kStr := query.Get("k"); kStr != "" {
+		parsedK, err := strconv.Atoi(kStr)
+		if err != nil || parsedK <= 0 {
+			return 0, 0, fmt.Errorf("k must be a positive integer")
+		}
+		k = parsedK
+	}
+
+	maxIterations := 1000 // Default
+	if iterationsStr := query.Get("max_iterations"); iterationsStr != "" {
+		parsedIterations, err := strconv.Atoi(iterationsStr)
+		if err != nil || parsedIterations <= 0 {
+			return 0, 0, fmt.Errorf("max_iterations must be a positive integer")
+		}
+		maxIterations = parsedIterations
+	}
+	return k, maxIterations, nil
+}
+
+// handleLabelPropagation predicts a label for every item from the labels
+// posted for a few of them, as a JSON object mapping IDs to labels
+func handleLabelPropagation(w http.ResponseWriter, r *http.Request) {
+	if !beginPOST(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	metric, err := parseMetric(r.URL.Query(), metricEuclidean)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	distance, ok := metricDistance(metric)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "label propagation needs a distance; use metric cosine or euclidean")
+		return
+	}
+
+	k, maxIterations, err := parseLabelPropagationOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Unseeded synthetic data changes on every request, so IDs labeled
+	// from an earlier response wouldn't mean the same vectors
+	if loadedItems() == nil && !params.Seeded {
+		writeError(w, http.StatusBadRequest, "seed is required to propagate labels over generated vectors")
+		return
+	}
+
+	// Refuse oversized requests before reading the body or generating
+	// anything
+	if datasetSize(params) > maxLabelPropagationItems {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("label propagation is limited to %d vectors", maxLabelPropagationItems))
+		return
+	}
+
+	var seedLabels map[string]string
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLabelSeedBodyBytes))
+	if err := decoder.Decode(&seedLabels); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if len(seedLabels) == 0 {
+		writeError(w, http.StatusBadRequest, "request body must map at least one ID to a label")
+		return
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	if len(data) > maxLabelPropagationItems {
+		// The loaded dataset grew in the meantime
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("label propagation is limited to %d vectors", maxLabelPropagationItems))
+		return
+	}
+	if k >= len(data) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("k must be less than the number of vectors (%d)", len(data)))
+		return
+	}
+
+	// Sorted labels make tie-breaking independent of the body's order
+	labelIndex := make(map[string]int)
+	for _, label := range seedLabels {
+		if label == "" {
+			writeError(w, http.StatusBadRequest, "labels must not be empty")
+			return
+		}
+		labelIndex[label] = 0
+	}
+	labels := make([]string, 0, len(labelIndex))
+	for label := range labelIndex {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for l, label := range labels {
+		labelIndex[label] = l
+	}
+
+	seeds := make([]int, len(data))
+	found := 0
+	for i, item := range data {
+		seeds[i] = -1
+		if label, ok := seedLabels[item.ID]; ok {
+			seeds[i] = labelIndex[label]
+			found++
+		}
+	}
+	if found < len(seedLabels) {
+		for id := range seedLabels {
+			if !containsID(data, id) {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("vector %q not found", id))
+				return
+			}
+		}
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	graph, err := labelGraph(r.Context(), vectors, k, distance)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	scores, iterations, converged, err := propagateLabels(r.Context(), graph, seeds, len(labels), maxIterations)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+
+	response := LabelPropagationResponse{
+		Items:      make([]LabelPrediction, len(data)),
+		Labels:     labels,
+		Counts:     make(map[string]int, len(labels)),
+		K:          k,
+		Metric:     metric.Name,
+		Iterations: iterations,
+		Converged:  converged,
+		Total:      len(data),
+	}
+	for i, item := range data {
+		prediction := LabelPrediction{ID: item.ID, Seed: seeds[i] >= 0}
+
+		// The first label with the top score wins ties
+		best, total := -1, 0.0
+		for l, score := range scores[i] {
+			total += score
+			if score > 0 && (best < 0 || score > scores[i][best]) {
+				best = l
+			}
+		}
+		if best >= 0 {
+			prediction.Label = labels[best]
+			prediction.Confidence = scores[i][best] / total
+			response.Counts[labels[best]]++
+		}
+		response.Items[i] = prediction
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(response)
+}
+
+// containsID reports whether any item of data has the given ID
+func containsID(data []VectorItem, id string) bool {
+	for _, item := range data {
+		if item.ID == id {
+			return true
+		}
+	}
+	return false
+}
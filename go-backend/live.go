@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxLiveConnections caps concurrent /ws/vectors clients, set from a flag
+// in main
+var maxLiveConnections int64 = 16
+
+// liveConnections counts the open /ws/vectors connections
+var liveConnections atomic.Int64
+
+// liveShutdown is closed when the server shuts down. Hijacked connections
+// aren't tracked by http.Server, so the streams watch it themselves.
+var liveShutdown = make(chan struct{})
+
+// liveStreams tracks the running streams so shutdown can wait for them to
+// send their close frames
+var liveStreams sync.WaitGroup
+
+// The fastest update interval a client may request
+const minLiveInterval = 50 * time.Millisecond
+
+// LiveMessage is a message sent on /ws/vectors. The first has type "init"
+// and carries the dataset; every later one has type "update" and carries
+// the new position of each vector.
+type LiveMessage struct {
+	Type    string       `json:"type"`
+	Tick    int          `json:"tick"`
+	Data    []VectorItem `json:"data,omitempty"`
+	Updates []LiveUpdate `json:"updates,omitempty"`
+}
+
+// LiveUpdate is the new position of one vector
+type LiveUpdate struct {
+	ID     string    `json:"id"`
+	Vector []float64 `json:"vector"`
+}
+
+func handleLiveVectors(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parsePagedParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	interval := time.Second // Default
+	if intervalStr := r.URL.Query().Get("interval"); intervalStr != "" {
+		parsedInterval, err := time.ParseDuration(intervalStr)
+		if err != nil || parsedInterval < minLiveInterval {
+			writeError(w, http.StatusBadRequest, "interval must be a duration of at least "+minLiveInterval.String())
+			return
+		}
+		interval = parsedInterval
+	}
+
+	step := 0.01 // Default
+	if stepStr := r.URL.Query().Get("step"); stepStr != "" {
+		parsedStep, err := strconv.ParseFloat(stepStr, 64)
+		if err != nil || !(parsedStep > 0 && parsedStep <= maxSpread) {
+			writeError(w, http.StatusBadRequest, "step must be a positive number")
+			return
+		}
+		step = parsedStep
+	}
+
+	if liveConnections.Add(1) > maxLiveConnections {
+		liveConnections.Add(-1)
+		writeError(w, http.StatusServiceUnavailable, "too many live connections")
+		return
+	}
+	defer liveConnections.Add(-1)
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	page, _ := paginate(data, params.Offset, params.Limit)
+	for i := range page {
+		// The walk moves vectors in place, so detach them from the dataset
+		page[i].Vector = copyVector(page[i].Vector)
+	}
+
+	liveStreams.Add(1)
+	defer liveStreams.Done()
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		conn.readLoop()
+		close(closed)
+	}()
+
+	send := func(message LiveMessage) bool {
+		payload, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("live: encoding message: %v", err)
+			return false
+		}
+		return conn.WriteText(payload) == nil
+	}
+
+	if !send(LiveMessage{Type: "init", Data: page}) {
+		return
+	}
+
+	rng := params.Rand()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for tick := 1; ; tick++ {
+		select {
+		case <-closed:
+			return
+		case <-liveShutdown:
+			return
+		case <-ticker.C:
+		}
+
+		// Take a small gaussian random-walk step in every dimension
+		updates := make([]LiveUpdate, len(page))
+		for i := range page {
+			for j := range page[i].Vector {
+				page[i].Vector[j] += rng.NormFloat64() * step
+			}
+			updates[i] = LiveUpdate{ID: page[i].ID, Vector: page[i].Vector}
+		}
+		if !send(LiveMessage{Type: "update", Tick: tick, Updates: updates}) {
+			return
+		}
+	}
+}
+
+// waitLiveStreams waits for every live stream to finish after liveShutdown
+// is closed, or for ctx to end
+func waitLiveStreams(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		liveStreams.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
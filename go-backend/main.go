@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -14,15 +25,62 @@ import (
 type VectorItem struct {
 	ID       string                 `json:"id"`
 	Key      string                 `json:"key"`
-	Vector   []float64              `json:"vector"`
+	Vector   []float64              `json:"vector,omitempty"`
 	Metadata map[string]interface{} `json:"metadata"`
 	Clusters []string               `json:"clusters"`
+
+	// Vectors holds additional named embeddings, such as text_embedding and
+	// image_embedding. The vector query parameter selects one of them in
+	// place of Vector.
+	Vectors map[string][]float64 `json:"vectors,omitempty"`
+
+	// Projection is set by the dimension-reduction endpoints
+	Projection []float64 `json:"projection,omitempty"`
+	// ClusterID is set by the clustering endpoints
+	ClusterID *int `json:"cluster_id,omitempty"`
+	// NearestCluster is set when annotate_nearest is requested
+	NearestCluster string `json:"nearest_cluster,omitempty"`
+	// QuantizedVector and QuantizationScale are set when quantize is
+	// requested; Vector is approximately QuantizedVector * QuantizationScale
+	QuantizedVector   []int8   `json:"quantized_vector,omitempty"`
+	QuantizationScale *float64 `json:"quantization_scale,omitempty"`
+	// SparseVector replaces Vector when format=sparse is requested
+	SparseVector *SparseVector `json:"sparse_vector,omitempty"`
 }
 
-// VectorDataResponse is the response structure for vector data
+// VectorDataResponse is the response structure for vector data. Total is
+// the size of the whole logical dataset; NextOffset is null on the last page.
+// For sampled responses Total is the sample size and Population the number
+// of items sampled from.
 type VectorDataResponse struct {
-	Data  []VectorItem `json:"data"`
-	Total int          `json:"total"`
+	Data       []VectorItem `json:"data"`
+	Total      int          `json:"total"`
+	Offset     int          `json:"offset"`
+	Limit      int          `json:"limit"`
+	NextOffset *int         `json:"next_offset"`
+	Population *int         `json:"population,omitempty"`
+	// Scaling is set when scale is requested
+	Scaling       *ScalingParams `json:"scaling,omitempty"`
+	AppliedParams *AppliedParams `json:"applied_params"`
+}
+
+// ClusterInfo describes a generated cluster and its true center. Count is
+// the number of items assigned to the cluster and is only set when the
+// request includes a limit. Color is stable for a given cluster name.
+type ClusterInfo struct {
+	Name   string    `json:"name"`
+	Color  string    `json:"color"`
+	Center []float64 `json:"center"`
+	Count  *int      `json:"count,omitempty"`
+}
+
+// ClusterResponse is the response structure for cluster data
+type ClusterResponse struct {
+	Clusters []ClusterInfo `json:"clusters"`
+	Total    int           `json:"total"`
+	// StableCenters is set when the server fixes the centers for every
+	// request with -stable-centers
+	StableCenters bool `json:"stable_centers"`
 }
 
 // ErrorResponse represents an API error
@@ -58,8 +116,6 @@ var (
 		"Type I", "Type J",
 	}
 
-	sampleRatings = []int{1, 2, 3, 4, 5}
-
 	sampleStatuses = []string{
 		"Active", "Inactive", "Pending", "Archived", "Draft",
 	}
@@ -77,46 +133,94 @@ var (
 	}
 )
 
+// Upper bounds on request parameters, set from flags in main
+var (
+	maxDimensions = 4096
+	maxLimit      = 100000
+)
+
+// Size of the logical dataset the listing endpoints page through when the
+// request doesn't give one
+const defaultDatasetSize = 10000
+
+// defaultSeed seeds requests that omit seed when -default-seed is set, so
+// the default dataset is the same across restarts; nil keeps them random
+var defaultSeed *int64
+
+// Bounds on the shape of generated datasets
+const (
+	maxClusters   = 100
+	maxSpread     = 10.0
+	maxSeparation = 100.0
+
+	maxCoordMagnitude = 1e9
+)
+
 // Helper functions
-func getRandomItem(items interface{}) interface{} {
+func getRandomItem(rng *rand.Rand, items interface{}) interface{} {
 	switch v := items.(type) {
 	case []string:
-		return v[rand.Intn(len(v))]
+		return v[rng.Intn(len(v))]
 	case []int:
-		return v[rand.Intn(len(v))]
+		return v[rng.Intn(len(v))]
 	default:
 		return nil
 	}
 }
 
-func getRandomItems(items []string, minItems, maxItems int) []string {
-	numItems := rand.Intn(maxItems-minItems+1) + minItems
-	result := make([]string, 0, numItems)
-	
+func getRandomItems(rng *rand.Rand, items []string, minItems, maxItems int) []string {
+	numItems := rng.Intn(maxItems-minItems+1) + minItems
+
 	// Create a copy of the items to shuffle
 	shuffled := make([]string, len(items))
 	copy(shuffled, items)
 	
 	// Fisher-Yates shuffle
 	for i := len(shuffled) - 1; i > 0; i-- {
-		j := rand.Intn(i + 1)
+		j := rng.Intn(i + 1)
 		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
 	}
 	
 	return shuffled[:numItems]
 }
 
-func generateRandomKey(length int) string {
+// getWeightedItems is getRandomItems with the first item drawn in
+// proportion to weights, which sum to 1; the rest are drawn uniformly from
+// the others
+func getWeightedItems(rng *rand.Rand, items []string, weights []float64, minItems, maxItems int) []string {
+	numItems := rng.Intn(maxItems-minItems+1) + minItems
+
+	first := len(items) - 1
+	for i, target := 0, rng.Float64(); i < len(weights); i++ {
+		if target -= weights[i]; target < 0 {
+			first = i
+			break
+		}
+	}
+
+	// Put the first item in front and shuffle the others behind it
+	shuffled := make([]string, len(items))
+	copy(shuffled, items)
+	shuffled[0], shuffled[first] = shuffled[first], shuffled[0]
+	for i := len(shuffled) - 1; i > 1; i-- {
+		j := rng.Intn(i) + 1
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+
+	return shuffled[:numItems]
+}
+
+func generateRandomKey(rng *rand.Rand, length int) string {
 	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
 	result := make([]byte, length)
 	for i := 0; i < length; i++ {
-		result[i] = chars[rand.Intn(len(chars))]
+		result[i] = chars[rng.Intn(len(chars))]
 	}
 	return string(result)
 }
 
-func getRandomNumber(min, max float64, decimals int) float64 {
-	value := min + rand.Float64()*(max-min)
+func getRandomNumber(rng *rand.Rand, min, max float64, decimals int) float64 {
+	value := min + rng.Float64()*(max-min)
 	factor := float64(1)
 	for i := 0; i < decimals; i++ {
 		factor *= 10
@@ -124,133 +228,1203 @@ func getRandomNumber(min, max float64, decimals int) float64 {
 	return float64(int(value*factor)) / factor
 }
 
-// Generate vector data
-func generateVectorData(limit, dimensions int) []VectorItem {
-	// Generate cluster centers (one per possible cluster)
-	clusterCenters := make([][]float64, len(sampleClusters))
+// clusterNames returns the names of the first count clusters. The sample
+// names are used first, then further letters (Group K, ..., Group AA).
+func clusterNames(count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		if i < len(sampleClusters) {
+			names[i] = sampleClusters[i]
+			continue
+		}
+		label := ""
+		for n := i + 1; n > 0; n = (n - 1) / 26 {
+			label = string(rune('A'+(n-1)%26)) + label
+		}
+		names[i] = "Group " + label
+	}
+	return names
+}
+
+// Generate one center per cluster, in clusterNames order, uniformly within
+// ±separation per dimension
+func generateClusterCenters(rng *rand.Rand, count, dimensions int, separation float64) [][]float64 {
+	clusterCenters := make([][]float64, count)
 	for i := range clusterCenters {
 		clusterCenters[i] = make([]float64, dimensions)
 		for j := range clusterCenters[i] {
-			clusterCenters[i][j] = rand.Float64()*2 - 1
+			clusterCenters[i][j] = (rng.Float64()*2 - 1) * separation
 		}
 	}
+	return clusterCenters
+}
 
-	data := make([]VectorItem, 0, limit)
+// coordTransform returns the offset and factor that map the coordinates a
+// dataset is generated in, within ±(Separation+Spread), onto CoordMin to
+// CoordMax. Without a coordinate range they leave coordinates unchanged.
+func (c generatorConfig) coordTransform() (float64, float64) {
+	if c.CoordMin == c.CoordMax {
+		return 0, 1
+	}
+	extent := c.Separation + c.Spread
+	return (c.CoordMax + c.CoordMin) / 2, (c.CoordMax - c.CoordMin) / (2 * extent)
+}
 
-	// Generate points
-	for i := 0; i < limit; i++ {
-		// Assign 1-3 clusters to this item
-		clusters := getRandomItems(sampleClusters, 1, 3)
-		
-		// Choose primary cluster for vector generation
-		primaryClusterIdx := -1
-		for idx, cluster := range sampleClusters {
-			if cluster == clusters[0] {
-				primaryClusterIdx = idx
-				break
-			}
+// rescaleCoords maps v in place from generated coordinates onto the
+// coordinate range, as coordTransform describes
+func rescaleCoords(v []float64, config generatorConfig) {
+	offset, factor := config.coordTransform()
+	for j := range v {
+		v[j] = offset + v[j]*factor
+	}
+}
+
+// Point distributions around a cluster center
+const (
+	distributionUniform  = "uniform"  // Uniform within ±Spread per dimension
+	distributionGaussian = "gaussian" // Normal with standard deviation Spread
+)
+
+// generatorConfig describes the shape of a synthetic dataset. Points are
+// scattered around their primary cluster's center according to
+// Distribution and Spread, and created timestamps are offsets from BaseTime.
+// IDs follow IDFormat; UUIDs are derived from Seed and the item index.
+// Keys follow KeyFormat, and hashed keys are derived the same way.
+// Correlated biases enum metadata by primary cluster as the schema says.
+// Each item belongs to between MinClusters and MaxClusters clusters, the
+// first of which places its vector. ClusterWeights, when not nil, holds one
+// weight per cluster, summing to 1, for how often it comes first.
+// OutlierFraction of the items are outliers drawn uniformly over the whole
+// space instead of near a center; TagOutliers marks them in metadata.
+// Sparsity is the fraction of each vector's components set to zero.
+// CoordMin and CoordMax, when set, stretch and shift the generated space so
+// centers and points span that range instead of ±(Separation+Spread);
+// both are zero otherwise.
+// MetadataFields, when not nil, names the metadata fields generated.
+// IntrinsicDim, when set, confines the data to a random subspace of that
+// many dimensions plus IntrinsicNoise; see generateSpace.
+//
+// Centers are drawn within ±Separation per dimension, so how much clusters
+// overlap depends on the ratio Separation/Spread rather than on either
+// alone: the default 1/0.25 gives distinct blobs, while a ratio near 1 or
+// below makes neighbouring clusters run into each other.
+type generatorConfig struct {
+	Dimensions   int
+	Clusters     int
+	MinClusters  int
+	MaxClusters  int
+	Spread       float64
+	Separation   float64
+	Distribution string
+	BaseTime     time.Time
+	IDFormat     string
+	KeyFormat    string
+	Seed         int64
+	Correlated   bool
+
+	OutlierFraction float64
+	TagOutliers     bool
+	Sparsity        float64
+	IntrinsicDim    int
+	IntrinsicNoise  float64
+	MetadataFields  []string
+	ClusterWeights  []float64
+	CoordMin        float64
+	CoordMax        float64
+}
+
+// generationChunkSize is the number of consecutive items drawn from one
+// chunk source. Chunks are the unit of parallel generation, and because
+// their size is fixed a dataset doesn't depend on how many workers made it.
+const generationChunkSize = 1024
+
+// vectorGenerator produces the items of a synthetic dataset one at a time.
+// The cluster centers and a chunk seed come from rng; each chunk of items
+// then draws from its own source derived from that seed, so a seeded rng
+// reproduces the same dataset whether it is generated serially or in
+// parallel.
+type vectorGenerator struct {
+	config         generatorConfig
+	clusterNames   []string
+	clusterCenters [][]float64 // In latent coordinates when basis is set
+	basis          [][]float64
+	includeFields  []bool // Metadata fields to keep, or nil for all
+	chunkSeed      int64
+	chunkRng       *rand.Rand
+	index          int
+}
+
+// newVectorGenerator draws the cluster centers and returns a generator
+// positioned at the first item
+func newVectorGenerator(rng *rand.Rand, config generatorConfig) *vectorGenerator {
+	g := &vectorGenerator{
+		config:       config,
+		clusterNames:  clusterNames(config.Clusters),
+		includeFields: includedFields(metadataSchema, config.MetadataFields),
+	}
+	// Generate cluster centers (one per possible cluster)
+	g.clusterCenters, g.basis = generateSpace(rng, config)
+	g.chunkSeed = rng.Int63()
+	return g
+}
+
+// chunkRand returns the source for the given chunk. The chunk index is
+// mixed with SplitMix64 so neighbouring chunks get unrelated seeds.
+func (g *vectorGenerator) chunkRand(chunk int) *rand.Rand {
+	z := uint64(g.chunkSeed) + uint64(chunk+1)*0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	z ^= z >> 31
+	return rand.New(rand.NewSource(int64(z)))
+}
+
+// Next generates the next item of the dataset
+func (g *vectorGenerator) Next() VectorItem {
+	if g.index%generationChunkSize == 0 {
+		g.chunkRng = g.chunkRand(g.index / generationChunkSize)
+	}
+	item := g.generate(g.chunkRng, g.index)
+	g.index++
+	return item
+}
+
+// generate makes the item at index, drawing every random choice from rng.
+// It only reads the generator, so chunks can be generated concurrently.
+func (g *vectorGenerator) generate(rng *rand.Rand, index int) VectorItem {
+	// Assign MinClusters-MaxClusters clusters to this item
+	var clusters []string
+	if g.config.ClusterWeights != nil {
+		clusters = getWeightedItems(rng, g.clusterNames, g.config.ClusterWeights, g.config.MinClusters, g.config.MaxClusters)
+	} else {
+		clusters = getRandomItems(rng, g.clusterNames, g.config.MinClusters, g.config.MaxClusters)
+	}
+
+	// Choose primary cluster for vector generation
+	primaryClusterIdx := -1
+	for idx, cluster := range g.clusterNames {
+		if cluster == clusters[0] {
+			primaryClusterIdx = idx
+			break
 		}
-		
-		center := clusterCenters[primaryClusterIdx]
+	}
 
-		// Generate a point near the cluster center
-		vector := make([]float64, dimensions)
-		for j := range center {
-			vector[j] = center[j] + (rand.Float64()*0.5 - 0.25)
+	center := g.clusterCenters[primaryClusterIdx]
+
+	// Outliers are drawn only when asked for, so other datasets keep the
+	// same random sequence
+	outlier := g.config.OutlierFraction > 0 && rng.Float64() < g.config.OutlierFraction
+
+	// Generate a point near the cluster center, or anywhere in the box the
+	// clusters occupy for an outlier
+	vector := make([]float64, len(center))
+	for j := range center {
+		if outlier {
+			extent := g.config.Separation + g.config.Spread
+			vector[j] = (rng.Float64()*2 - 1) * extent
+		} else if g.config.Distribution == distributionGaussian {
+			vector[j] = center[j] + rng.NormFloat64()*g.config.Spread
+		} else {
+			vector[j] = center[j] + (rng.Float64()*2-1)*g.config.Spread
 		}
+	}
 
-		// Generate random metadata
-		metadata := map[string]interface{}{
-			"name":       fmt.Sprintf("%s %s", getRandomItem(sampleAttributes).(string), getRandomItem(sampleNames).(string)),
-			"type":       getRandomItem(sampleTypes).(string),
-			"category":   getRandomItem(sampleCategories).(string),
-			"rating":     getRandomItem(sampleRatings).(int),
-			"value":      getRandomNumber(10, 1000, 2),
-			"status":     getRandomItem(sampleStatuses).(string),
-			"priority":   getRandomItem(samplePriorities).(string),
-			"region":     getRandomItem(sampleRegions).(string),
-			"department": getRandomItem(sampleDepartments).(string),
-			"created":    time.Now().Add(-time.Duration(rand.Intn(365)) * 24 * time.Hour).Format(time.RFC3339),
-			"isActive":   rand.Float64() > 0.2,
-			"score":      rand.Intn(100) + 1,
-			"tags":       getRandomItems(sampleAttributes, 0, 5),
+	if g.basis != nil {
+		vector = embed(g.basis, vector, g.config.Dimensions)
+		for j := range vector {
+			vector[j] += rng.NormFloat64() * g.config.IntrinsicNoise
 		}
+	}
 
-		// Add data point
-		data = append(data, VectorItem{
-			ID:       strconv.Itoa(i),
-			Key:      generateRandomKey(8),
-			Vector:   vector,
-			Metadata: metadata,
-			Clusters: clusters,
-		})
+	if g.config.CoordMin != g.config.CoordMax {
+		rescaleCoords(vector, g.config)
 	}
 
-	return data
+	if g.config.Sparsity > 0 {
+		sparsify(rng, vector, g.config.Sparsity)
+	}
+
+	// Generate random metadata, biased by the primary cluster if asked
+	var cluster *metadataCluster
+	if g.config.Correlated {
+		cluster = &metadataCluster{Index: primaryClusterIdx, Name: clusters[0]}
+	}
+	metadata := generateMetadata(rng, metadataSchema, g.config.BaseTime, cluster, g.includeFields)
+	if g.config.TagOutliers {
+		metadata["outlier"] = outlier
+	}
+
+	// The random key is drawn either way, so the rest of the item is the
+	// same whichever format is asked for
+	key := generateRandomKey(rng, keyLength)
+	if g.config.KeyFormat == keyFormatHash {
+		key = hashedKey(g.config.Seed, index)
+	}
+
+	vectorsGenerated.Add(1)
+	return VectorItem{
+		ID:       itemID(g.config.IDFormat, g.config.Seed, index),
+		Key:      key,
+		Vector:   vector,
+		Metadata: metadata,
+		Clusters: clusters,
+	}
 }
 
-// API handlers
-func handleVectorData(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	
-	// Handle preflight request
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
+// Generate vector data using rng for every random choice, so a seeded rng
+// reproduces the same dataset. Datasets larger than one chunk are
+// generated by runtime.NumCPU() workers, each taking whole chunks. The
+// workers stop between chunks once ctx ends, and count finished items on
+// ctx's progress counter if it has one.
+func generateVectorData(ctx context.Context, rng *rand.Rand, limit int, config generatorConfig) ([]VectorItem, error) {
+	return generateWithWorkers(ctx, rng, limit, config, runtime.NumCPU())
+}
+
+// generateWithWorkers is generateVectorData with at most the given number
+// of workers. The dataset is the same for any number.
+func generateWithWorkers(ctx context.Context, rng *rand.Rand, limit int, config generatorConfig, maxWorkers int) ([]VectorItem, error) {
+	generator := newVectorGenerator(rng, config)
+	progress := generationProgress(ctx)
+
+	data := make([]VectorItem, limit)
+	chunks := (limit + generationChunkSize - 1) / generationChunkSize
+	workers := min(maxWorkers, chunks)
+
+	// Generate points
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				chunk := int(next.Add(1) - 1)
+				if chunk >= chunks || ctx.Err() != nil {
+					return
+				}
+				chunkRng := generator.chunkRand(chunk)
+				start := chunk * generationChunkSize
+				end := min(start+generationChunkSize, limit)
+				for i := start; i < end; i++ {
+					data[i] = generator.generate(chunkRng, i)
+				}
+				if progress != nil {
+					progress.Add(int64(end - start))
+				}
+			}
+		}()
 	}
-	
-	// Only allow GET requests
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	return data, nil
+}
 
-	// Parse query parameters
+// writeError writes an ErrorResponse with the given status code
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
+
+// vectorParams holds the generation parameters shared by the API handlers.
+// Size is the number of items in the logical dataset and Offset/Limit select
+// a page of it. VectorField names the embedding the analysis endpoints
+// operate on; empty means the primary vector. Normalize scales every
+// vector to unit length. Adjustments lists the parameters that were not
+// used as given.
+type vectorParams struct {
+	generatorConfig
+	Limit       int
+	Offset      int
+	Size        int
+	Seeded      bool
+	FixedTime   bool // BaseTime came from base_time
+	VectorField string
+	Normalize   bool
+	Adjustments []ParamAdjustment
+}
+
+// Rand returns a new source for the request's seed
+func (p vectorParams) Rand() *rand.Rand {
+	return rand.New(rand.NewSource(p.Seed))
+}
+
+// parseClusterWeights reads a comma-separated weight for each of the
+// clusters and scales them to sum to 1
+func parseClusterWeights(weightsStr string, clusters int) ([]float64, error) {
+	fields := strings.Split(weightsStr, ",")
+	if len(fields) != clusters {
+		return nil, fmt.Errorf("cluster_weights must list one weight per cluster (%d), not %d", clusters, len(fields))
+	}
+
+	weights := make([]float64, len(fields))
+	total := 0.0
+	for i, field := range fields {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || !(weight >= 0) || math.IsInf(weight, 1) {
+			return nil, fmt.Errorf("cluster_weights must be non-negative numbers")
+		}
+		weights[i] = weight
+		total += weight
+	}
+	if !(total > 0) || math.IsInf(total, 1) {
+		return nil, fmt.Errorf("cluster_weights must include a positive weight")
+	}
+
+	for i := range weights {
+		weights[i] /= total
+	}
+	return weights, nil
+}
+
+// parseVectorParams reads the generation parameters from the query string.
+// Invalid limit and dimensions values fall back to the defaults, noted in
+// Adjustments; an invalid seed is an error since silently ignoring it would
+// return random data, and values above the configured maximums are errors
+// rather than allocations. Without a size the dataset is the requested
+// items, which is what the analysis endpoints work on.
+func parseVectorParams(r *http.Request) (vectorParams, error) {
+	return parseDatasetParams(r, false)
+}
+
+// parsePagedParams is parseVectorParams for the endpoints that page through
+// the dataset. Without a size the dataset holds defaultDatasetSize items,
+// or limit when that is more, so it is the same whatever the offset and
+// total and next_offset agree on every page.
+func parsePagedParams(r *http.Request) (vectorParams, error) {
+	return parseDatasetParams(r, true)
+}
+
+func parseDatasetParams(r *http.Request, paged bool) (vectorParams, error) {
 	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+	sizeStr := r.URL.Query().Get("size")
 	dimensionsStr := r.URL.Query().Get("dimensions")
+	seedStr := r.URL.Query().Get("seed")
+
+	params := vectorParams{
+		generatorConfig: generatorConfig{
+			Dimensions:   100,                 // Default
+			Clusters:     10,                  // Default
+			Spread:       0.25,                // Default
+			Separation:   1,                   // Default
+			Distribution: distributionUniform, // Default
+			IDFormat:     idFormatSequential,  // Default
+			KeyFormat:    keyFormatRandom,     // Default
+			BaseTime:     time.Now(),
+		},
+		Limit: 500, // Default
+	}
 
-	limit := 500 // Default
 	if limitStr != "" {
 		parsedLimit, err := strconv.Atoi(limitStr)
 		if err == nil && parsedLimit > 0 {
-			limit = parsedLimit
+			params.Limit = parsedLimit
+		} else {
+			params.adjust("limit", limitStr, params.Limit, "not a positive integer; using the default")
+		}
+	}
+
+	if offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err == nil && parsedOffset > 0 {
+			params.Offset = parsedOffset
+		} else if err != nil || parsedOffset < 0 {
+			params.adjust("offset", offsetStr, params.Offset, "not a non-negative integer; using the default")
+		}
+	}
+
+	// Refuse sizes that would allocate unreasonable amounts of memory
+	if params.Limit > maxLimit {
+		return params, fmt.Errorf("limit must not exceed %d", maxLimit)
+	}
+	if params.Offset > maxLimit-params.Limit {
+		return params, fmt.Errorf("offset + limit must not exceed %d", maxLimit)
+	}
+
+	// Without an explicit size the dataset ends at the requested page, or
+	// is a fixed size when paging through it
+	params.Size = params.Offset + params.Limit
+	if paged {
+		params.Size = max(min(defaultDatasetSize, maxLimit), params.Limit)
+	}
+	if sizeStr != "" {
+		parsedSize, err := strconv.Atoi(sizeStr)
+		if err == nil && parsedSize > 0 {
+			params.Size = parsedSize
+		} else {
+			params.adjust("size", sizeStr, params.Size, "not a positive integer; using the default")
 		}
 	}
+	if params.Size > maxLimit {
+		return params, fmt.Errorf("size must not exceed %d", maxLimit)
+	}
 
-	dimensions := 100 // Default
 	if dimensionsStr != "" {
 		parsedDimensions, err := strconv.Atoi(dimensionsStr)
 		if err == nil && parsedDimensions > 0 {
-			dimensions = parsedDimensions
+			params.Dimensions = parsedDimensions
+		} else {
+			params.adjust("dimensions", dimensionsStr, params.Dimensions, "not a positive integer; using the default")
+		}
+	}
+	if params.Dimensions > maxDimensions {
+		return params, fmt.Errorf("dimensions must not exceed %d", maxDimensions)
+	}
+
+	if clustersStr := r.URL.Query().Get("clusters"); clustersStr != "" {
+		parsedClusters, err := strconv.Atoi(clustersStr)
+		if err != nil || parsedClusters <= 0 || parsedClusters > maxClusters {
+			return params, fmt.Errorf("clusters must be an integer between 1 and %d", maxClusters)
+		}
+		params.Clusters = parsedClusters
+	}
+
+	// Items belong to 1-3 clusters unless asked otherwise, never more than
+	// there are
+	params.MinClusters = 1                       // Default
+	params.MaxClusters = min(3, params.Clusters) // Default
+	if minStr := r.URL.Query().Get("min_clusters"); minStr != "" {
+		parsedMin, err := strconv.Atoi(minStr)
+		if err != nil || parsedMin <= 0 {
+			return params, fmt.Errorf("min_clusters must be a positive integer")
+		}
+		params.MinClusters = parsedMin
+		params.MaxClusters = max(params.MaxClusters, min(parsedMin, params.Clusters))
+	}
+	if maxStr := r.URL.Query().Get("max_clusters"); maxStr != "" {
+		parsedMax, err := strconv.Atoi(maxStr)
+		if err != nil || parsedMax <= 0 {
+			return params, fmt.Errorf("max_clusters must be a positive integer")
 		}
+		params.MaxClusters = parsedMax
+	}
+	if params.MinClusters > params.MaxClusters || params.MaxClusters > params.Clusters {
+		return params, fmt.Errorf("min_clusters must not exceed max_clusters, and max_clusters must not exceed clusters (%d)", params.Clusters)
+	}
+
+	if weightsStr := r.URL.Query().Get("cluster_weights"); weightsStr != "" {
+		weights, err := parseClusterWeights(weightsStr, params.Clusters)
+		if err != nil {
+			return params, err
+		}
+		params.ClusterWeights = weights
+	}
+
+	if spreadStr := r.URL.Query().Get("spread"); spreadStr != "" {
+		parsedSpread, err := strconv.ParseFloat(spreadStr, 64)
+		if err != nil || !(parsedSpread > 0 && parsedSpread <= maxSpread) {
+			return params, fmt.Errorf("spread must be a number greater than 0 and at most %g", maxSpread)
+		}
+		params.Spread = parsedSpread
 	}
 
-	// Generate data
-	data := generateVectorData(limit, dimensions)
+	if separationStr := r.URL.Query().Get("separation"); separationStr != "" {
+		parsedSeparation, err := strconv.ParseFloat(separationStr, 64)
+		if err != nil || !(parsedSeparation > 0 && parsedSeparation <= maxSeparation) {
+			return params, fmt.Errorf("separation must be a number greater than 0 and at most %g", maxSeparation)
+		}
+		params.Separation = parsedSeparation
+	}
+
+	// Either end of the coordinate range defaults to the extent of the generated
+	// space, so giving one only moves that end
+	coordMinStr, coordMaxStr := r.URL.Query().Get("coord_min"), r.URL.Query().Get("coord_max")
+	if coordMinStr != "" || coordMaxStr != "" {
+		extent := params.Separation + params.Spread
+		params.CoordMin, params.CoordMax = -extent, extent
+		if coordMinStr != "" {
+			parsedMin, err := strconv.ParseFloat(coordMinStr, 64)
+			if err != nil || !(math.Abs(parsedMin) <= maxCoordMagnitude) {
+				return params, fmt.Errorf("coord_min must be a number between %g and %g", -maxCoordMagnitude, maxCoordMagnitude)
+			}
+			params.CoordMin = parsedMin
+		}
+		if coordMaxStr != "" {
+			parsedMax, err := strconv.ParseFloat(coordMaxStr, 64)
+			if err != nil || !(math.Abs(parsedMax) <= maxCoordMagnitude) {
+				return params, fmt.Errorf("coord_max must be a number between %g and %g", -maxCoordMagnitude, maxCoordMagnitude)
+			}
+			params.CoordMax = parsedMax
+		}
+		if params.CoordMin >= params.CoordMax {
+			return params, fmt.Errorf("coord_min must be less than coord_max")
+		}
+	}
+
+	if distribution := r.URL.Query().Get("distribution"); distribution != "" {
+		if distribution != distributionUniform && distribution != distributionGaussian {
+			return params, fmt.Errorf("distribution must be %q or %q", distributionUniform, distributionGaussian)
+		}
+		params.Distribution = distribution
+	}
+
+	if idFormat := r.URL.Query().Get("id_format"); idFormat != "" {
+		if idFormat != idFormatSequential && idFormat != idFormatUUID {
+			return params, fmt.Errorf("id_format must be %q or %q", idFormatSequential, idFormatUUID)
+		}
+		params.IDFormat = idFormat
+	}
+
+	if keyFormat := r.URL.Query().Get("key_format"); keyFormat != "" {
+		if keyFormat != keyFormatRandom && keyFormat != keyFormatHash {
+			return params, fmt.Errorf("key_format must be %q or %q", keyFormatRandom, keyFormatHash)
+		}
+		params.KeyFormat = keyFormat
+	}
+
+	if correlatedStr := r.URL.Query().Get("correlated"); correlatedStr != "" {
+		correlated, err := strconv.ParseBool(correlatedStr)
+		if err != nil {
+			return params, fmt.Errorf("correlated must be true or false")
+		}
+		params.Correlated = correlated
+	}
+
+	if fractionStr := r.URL.Query().Get("outlier_fraction"); fractionStr != "" {
+		fraction, err := strconv.ParseFloat(fractionStr, 64)
+		if err != nil || !(fraction >= 0 && fraction <= 1) {
+			return params, fmt.Errorf("outlier_fraction must be a number between 0 and 1")
+		}
+		params.OutlierFraction = fraction
+	}
+
+	if tagStr := r.URL.Query().Get("tag_outliers"); tagStr != "" {
+		tag, err := strconv.ParseBool(tagStr)
+		if err != nil {
+			return params, fmt.Errorf("tag_outliers must be true or false")
+		}
+		params.TagOutliers = tag
+	}
+
+	if intrinsicStr := r.URL.Query().Get("intrinsic_dim"); intrinsicStr != "" {
+		intrinsicDim, err := strconv.Atoi(intrinsicStr)
+		if err != nil || intrinsicDim <= 0 || intrinsicDim > params.Dimensions {
+			return params, fmt.Errorf("intrinsic_dim must be an integer between 1 and dimensions (%d)", params.Dimensions)
+		}
+		params.IntrinsicDim = intrinsicDim
+		params.IntrinsicNoise = defaultIntrinsicNoise // Default
+	}
+	if noiseStr := r.URL.Query().Get("intrinsic_noise"); noiseStr != "" {
+		noise, err := strconv.ParseFloat(noiseStr, 64)
+		if err != nil || !(noise >= 0 && noise <= maxSpread) {
+			return params, fmt.Errorf("intrinsic_noise must be a number between 0 and %g", maxSpread)
+		}
+		if params.IntrinsicDim == 0 {
+			return params, fmt.Errorf("intrinsic_noise needs intrinsic_dim")
+		}
+		params.IntrinsicNoise = noise
+	}
+
+	// An empty list asks for no metadata at all
+	if r.URL.Query().Has("metadata_fields") {
+		params.MetadataFields = []string{}
+		for _, name := range strings.Split(r.URL.Query().Get("metadata_fields"), ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			known := false
+			for _, field := range metadataSchema {
+				known = known || field.Name == name
+			}
+			if !known {
+				return params, fmt.Errorf("unknown metadata field %q", name)
+			}
+			params.MetadataFields = append(params.MetadataFields, name)
+		}
+	}
+
+	if sparseStr := r.URL.Query().Get("sparse"); sparseStr != "" {
+		sparsity, err := strconv.ParseFloat(sparseStr, 64)
+		if err != nil || !(sparsity >= 0 && sparsity < 1) {
+			return params, fmt.Errorf("sparse must be a number at least 0 and less than 1")
+		}
+		params.Sparsity = sparsity
+	}
+
+	if vectorField := r.URL.Query().Get("vector"); vectorField != "" {
+		if !hasVectorField(vectorField) {
+			return params, fmt.Errorf("unknown vector field %q", vectorField)
+		}
+		params.VectorField = vectorField
+	}
+
+	if normalizeStr := r.URL.Query().Get("normalize"); normalizeStr != "" {
+		normalize, err := strconv.ParseBool(normalizeStr)
+		if err != nil {
+			return params, fmt.Errorf("normalize must be true or false")
+		}
+		params.Normalize = normalize
+	}
+
+	// created timestamps count back from base_time when it is given, so a
+	// seeded dataset is the same whenever it is fetched
+	if baseTimeStr := r.URL.Query().Get("base_time"); baseTimeStr != "" {
+		baseTime, err := time.Parse(time.RFC3339, baseTimeStr)
+		if err != nil {
+			return params, fmt.Errorf("base_time must be an RFC 3339 timestamp, such as 2024-01-01T00:00:00Z")
+		}
+		params.BaseTime = baseTime
+		params.FixedTime = true
+	}
+
+	// Seeded requests get their own source so identical queries return
+	// identical data; otherwise use -default-seed, or draw a fresh seed from
+	// the global source. Without base_time, seeded timestamps are anchored
+	// to the start of the UTC day so the created field doesn't drift between
+	// otherwise identical requests.
+	if seedStr != "" {
+		parsedSeed, err := strconv.ParseInt(seedStr, 10, 64)
+		if err != nil {
+			return params, fmt.Errorf("seed must be an integer")
+		}
+		params.Seed = parsedSeed
+		params.Seeded = true
+	} else if defaultSeed != nil {
+		params.Seed = *defaultSeed
+		params.Seeded = true
+	} else {
+		// The global source is randomly seeded, so unseeded requests differ
+		params.Seed = rand.Int63()
+	}
+	if params.Seeded && !params.FixedTime {
+		params.BaseTime = params.BaseTime.UTC().Truncate(24 * time.Hour)
+	}
+
+	return params, nil
+}
+
+// paginate returns the page of data starting at offset, and the offset of
+// the following page or nil when there is none
+func paginate(data []VectorItem, offset, limit int) ([]VectorItem, *int) {
+	if offset >= len(data) {
+		return []VectorItem{}, nil
+	}
+
+	end := offset + limit
+	if end >= len(data) {
+		return data[offset:], nil
+	}
+	return data[offset:end], &end
+}
+
+// beginGET rejects anything other than GET. It reports whether the handler
+// should continue. CORS and preflight requests are handled by
+// corsMiddleware.
+func beginGET(w http.ResponseWriter, r *http.Request) bool {
+	// Only allow GET requests
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	return true
+}
+
+// beginPOST is beginGET for endpoints that take a request body
+func beginPOST(w http.ResponseWriter, r *http.Request) bool {
+	// Only allow POST requests
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	return true
+}
+
+// API handlers
+func handleVectorData(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		handleAppendVectors(w, r)
+		return
+	}
+	serveVectorData(w, r, vectorEnvelopeV1)
+}
+
+// serveVectorData answers a vector data request. Every API version shares
+// the generation logic; envelope builds the version's response body from
+// the page it produced.
+func serveVectorData(w http.ResponseWriter, r *http.Request, envelope vectorEnvelope) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Compress the response when the client supports it
+	if acceptsGzip(r) {
+		gz := newGzipResponseWriter(w)
+		defer gz.Close()
+		w = gz
+	}
+
+	// Parse query parameters
+	params, err := parsePagedParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters, err := parseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	output, err := parseOutputOptions(r.URL.Query(), params.vectorDimensions())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sample, err := parseSample(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	order, err := parseSort(r.URL.Query(), params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	output.Nearest, err = parseNearest(r.URL.Query(), params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scale, err := parseScale(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if stream, format := streamWriter(r); stream != nil {
+		if sample > 0 {
+			writeError(w, http.StatusBadRequest, "sample is not supported with "+format)
+			return
+		}
+		if scale != "" {
+			// There is nowhere to put the scaling parameters
+			writeError(w, http.StatusBadRequest, "scale is not supported with "+format)
+			return
+		}
+		if order.Reorders() {
+			// Reordering needs the whole dataset before the first line
+			data, err := datasetItems(r.Context(), params)
+			if err != nil {
+				writeWorkError(w, err)
+				return
+			}
+			data = filterItems(data, filters)
+			order.Apply(data)
+			stream(r.Context(), w, sliceIterator(data), params, nil, output)
+		} else {
+			stream(r.Context(), w, datasetIterator(params), params, filters, output)
+		}
+		if datasetLog != nil {
+			datasetLog.Record(r, params, nil)
+		}
+		return
+	}
+
+	// Streams are written as they are generated, but this body is built
+	// whole before it is sent
+	items := params.Limit
+	if sample > 0 {
+		items = sample
+	}
+	if writeResponseSizeError(w, items, params, output) {
+		return
+	}
+
+	// Only seeded requests are deterministic enough to cache
+	key := ""
+	if params.Seeded && vectorCache != nil {
+		key = cacheKey(r)
+		if body, ok := vectorCache.Get(key); ok {
+			w.Header().Set("X-Cache", "HIT")
+			writeWithETag(w, r, body)
+			if datasetLog != nil {
+				datasetLog.Record(r, params, nil)
+			}
+			return
+		}
+	}
+
+	var response VectorDataResponse
+	if sample > 0 {
+		// Sample the whole logical dataset while it is generated, holding
+		// only the sample in memory. Scaling needs a first pass of its own.
+		if scale != "" {
+			output.Scale = fitMinMax(datasetIterator(params))
+		}
+		sampled, population := reservoirSample(datasetIterator(params), filters, sample, params.sampleRand())
+		order.Apply(sampled)
+		response = VectorDataResponse{
+			Data:       output.ApplyAll(sampled),
+			Total:      len(sampled),
+			Limit:      sample,
+			Population: &population,
+		}
+		response.AppliedParams = appliedParams(params, sample)
+	} else {
+		// Generate the whole logical dataset so a given ID is the same item
+		// no matter which page it is fetched from, then filter before paging
+		data, err := datasetItems(r.Context(), params)
+		if err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		if scale != "" {
+			output.Scale = fitMinMax(sliceIterator(data))
+		}
+		response = vectorPage(data, params, filters, order, output)
+	}
+	if output.Scale != nil {
+		response.Scaling = output.Scale.Params()
+	}
 
 	// Return response
+	body, err := json.Marshal(envelope(response, params))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	body = append(indentJSON(r, body), '\n')
+
+	if datasetLog != nil {
+		count := len(response.Data)
+		datasetLog.Record(r, params, &count)
+	}
+	if key != "" {
+		vectorCache.Set(key, body)
+		w.Header().Set("X-Cache", "MISS")
+	}
+	if params.Seeded {
+		// Seeded responses are reproducible, so clients can revalidate them
+		writeWithETag(w, r, body)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	response := VectorDataResponse{
-		Data:  data,
-		Total: len(data),
+	w.Write(body)
+}
+
+// vectorPage filters and sorts the whole dataset, then returns the
+// requested page of it
+func vectorPage(data []VectorItem, params vectorParams, filters []itemFilter, order sortOptions, output outputOptions) VectorDataResponse {
+	data = filterItems(data, filters)
+	order.Apply(data)
+	page, nextOffset := paginate(data, params.Offset, params.Limit)
+
+	return VectorDataResponse{
+		Data:          output.ApplyAll(page),
+		Total:         len(data),
+		Offset:        params.Offset,
+		Limit:         params.Limit,
+		NextOffset:    nextOffset,
+		AppliedParams: appliedParams(params, params.Limit),
 	}
-	
-	json.NewEncoder(w).Encode(response)
+}
+
+func handleClusters(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Centers are the first thing drawn from the source, so they match the
+	// ones /api/vectors uses for the same seed and dimensions, or for any
+	// seed with -stable-centers
+	names := clusterNames(params.Clusters)
+	centers := clusterCenters(params.Rand(), params.generatorConfig)
+
+	// Only count assignments when the caller asks for a dataset size
+	var counts map[string]int
+	if r.URL.Query().Get("limit") != "" || r.URL.Query().Get("size") != "" {
+		counts = make(map[string]int, len(names))
+		data, err := generateVectorData(r.Context(), params.Rand(), params.Size, params.generatorConfig)
+		if err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		for _, item := range data {
+			for _, cluster := range item.Clusters {
+				counts[cluster]++
+			}
+		}
+	}
+
+	clusters := make([]ClusterInfo, 0, len(names))
+	for i, name := range names {
+		info := ClusterInfo{
+			Name:   name,
+			Color:  clusterColor(name),
+			Center: centers[i],
+		}
+		if counts != nil {
+			count := counts[name]
+			info.Count = &count
+		}
+		clusters = append(clusters, info)
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := ClusterResponse{
+		Clusters:      clusters,
+		Total:         len(clusters),
+		StableCenters: stableCenterSeed != nil,
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
+
+// resolvePort picks the listen port from the -port flag, then the PORT
+// environment variable, then the default of 8080
+func resolvePort(flagValue, envValue string) (int, error) {
+	value := "8080"
+	if envValue != "" {
+		value = envValue
+	}
+	if flagValue != "" {
+		value = flagValue
+	}
+
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("%q is not a valid port number (1-65535)", value)
+	}
+	return port, nil
 }
 
 func main() {
-	// Seed the random number generator
-	rand.Seed(time.Now().UnixNano())
+	dataPath := flag.String("data", "", "serve vectors from a JSON array of items instead of generating them")
+	csvPath := flag.String("csv", "", "serve vectors loaded from a CSV file instead of generating them")
+	csvIDColumn := flag.String("csv-id", "id", "CSV column holding the item ID")
+	dimStrategy := flag.String("dim-strategy", dimStrategyStrict, "how -data and -csv treat vectors of differing lengths: strict (refuse), pad (zero-pad to the longest) or truncate (cut to the shortest)")
+	schemaPath := flag.String("schema", "", "JSON file describing the generated metadata fields (defaults to the built-in fields)")
+	flag.IntVar(&maxDimensions, "max-dimensions", maxDimensions, "largest dimensions value a request may ask for")
+	flag.IntVar(&maxLimit, "max-limit", maxLimit, "largest number of vectors a request may ask for")
+	cacheSize := flag.Int("cache-size", 128, "number of seeded responses to cache (0 disables caching)")
+	cacheTTL := flag.Duration("cache-ttl", 5*time.Minute, "how long cached responses stay valid")
+	corsOrigins := flag.String("cors-origins", "*", "comma-separated list of origins allowed to call the API, or * for any")
+	logFormat := flag.String("log-format", "text", "request log format: text or json")
+	portFlag := flag.String("port", "", "port to listen on (defaults to $PORT, then 8080)")
+	grpcPort := flag.Int("grpc-port", 0, "port for the gRPC VectorService described in vectors.proto (0 disables it)")
+	requestTimeout := flag.Duration("request-timeout", 30*time.Second, "longest a request may run before it is abandoned with 503 (0 disables the limit)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "how long to wait for in-flight requests on shutdown")
+	flag.Int64Var(&maxResponseBytes, "max-response-bytes", maxResponseBytes, "largest estimated size of a buffered /api/vectors response, refused with 413 beyond it (0 disables the limit)")
+	flag.Int64Var(&maxLiveConnections, "max-ws-connections", maxLiveConnections, "largest number of concurrent /ws/vectors clients")
+	rateLimit := flag.Float64("rate-limit", 0, "requests per second allowed from each client IP (0, the default, disables rate limiting)")
+	rateBurst := flag.Int("rate-burst", 40, "requests a client IP may make at once before -rate-limit applies")
+	logRequests := flag.String("log-requests", "", "append the params and seed of every /api/vectors request to this JSONL file")
+	defaultSeedFlag := flag.String("default-seed", "", "seed for requests that omit seed, so the default dataset survives restarts (defaults to a random seed per request)")
+	flag.StringVar(&adminToken, "admin-token", "", "bearer token for the /api/admin endpoints, appends and imports (empty disables them)")
+	stableCenters := flag.Bool("stable-centers", false, "draw cluster centers from -default-seed, or a fixed seed, for every request so only the points around them vary")
+	flag.Parse()
+
+	if *dataPath != "" && *csvPath != "" {
+		log.Fatal("Only one of -data and -csv may be set")
+	}
+	if err := checkDimStrategy(*dimStrategy); err != nil {
+		log.Fatalf("Invalid -dim-strategy: %v", err)
+	}
+
+	requestLogger, err := newRequestLogger(*logFormat)
+	if err != nil {
+		log.Fatalf("Invalid -log-format: %v", err)
+	}
+
+	if *grpcPort < 0 || *grpcPort > 65535 {
+		log.Fatalf("Invalid -grpc-port: %d is not a valid port number (0-65535)", *grpcPort)
+	}
+
+	if *rateLimit < 0 || (*rateLimit > 0 && *rateBurst < 1) {
+		log.Fatal("-rate-limit must not be negative and -rate-burst must be at least 1")
+	}
+
+	if *defaultSeedFlag != "" {
+		seed, err := strconv.ParseInt(*defaultSeedFlag, 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid -default-seed: %q is not an integer", *defaultSeedFlag)
+		}
+		defaultSeed = &seed
+	}
+	if *stableCenters {
+		seed := int64(fixedCenterSeed)
+		if defaultSeed != nil {
+			seed = *defaultSeed
+		}
+		stableCenterSeed = &seed
+	}
+
+	if *cacheSize > 0 {
+		vectorCache = newResponseCache(*cacheSize, *cacheTTL)
+	}
+
+	if *logRequests != "" {
+		datasetLog, err = openDatasetLog(*logRequests)
+		if err != nil {
+			log.Fatalf("Failed to open request log: %v", err)
+		}
+		defer datasetLog.Close()
+	}
+
+	// Replace the built-in metadata fields if a schema was given
+	if *schemaPath != "" {
+		schema, err := loadMetadataSchema(*schemaPath)
+		if err != nil {
+			log.Fatalf("Failed to load metadata schema: %v", err)
+		}
+		metadataSchema = schema
+		numericMetadataFields = numericFields(schema)
+		dateMetadataFields = dateFields(schema)
+		log.Printf("Loaded %d metadata fields from %s", len(schema), *schemaPath)
+	}
+
+	// Load a fixed dataset if one was given
+	if *dataPath != "" {
+		items, err := loadJSONDataset(*dataPath, *dimStrategy)
+		if err != nil {
+			log.Fatalf("Failed to load dataset: %v", err)
+		}
+		loadedDataset = items
+		datasetPath = *dataPath
+		reloadDataset = func() ([]VectorItem, error) { return loadJSONDataset(*dataPath, *dimStrategy) }
+		log.Printf("Loaded %d vectors with %d dimensions from %s", len(items), len(items[0].Vector), *dataPath)
+	}
+	if *csvPath != "" {
+		items, err := loadCSVDataset(*csvPath, *csvIDColumn, *dimStrategy)
+		if err != nil {
+			log.Fatalf("Failed to load CSV dataset: %v", err)
+		}
+		loadedDataset = items
+		datasetPath = *csvPath
+		reloadDataset = func() ([]VectorItem, error) { return loadCSVDataset(*csvPath, *csvIDColumn, *dimStrategy) }
+		log.Printf("Loaded %d vectors with %d dimensions from %s", len(items), len(items[0].Vector), *csvPath)
+	}
 
 	// Define API routes
-	http.HandleFunc("/api/vectors", handleVectorData)
+	api := http.NewServeMux()
+	api.HandleFunc("/api/vectors", handleVectorData)
+	api.HandleFunc("/api/v2/vectors", handleVectorDataV2)
+	api.HandleFunc("/api/clusters", handleClusters)
+	api.HandleFunc("/api/vectors/batch", handleBatch)
+	api.HandleFunc("/api/vectors/import", handleImportVectors)
+	api.HandleFunc("/api/vectors/search", handleSearch)
+	api.HandleFunc("/api/vectors/search/batch", handleBatchSearch)
+	api.HandleFunc("/api/vectors/centroid", handleCentroid)
+	api.HandleFunc("/api/vectors/export", handleExport)
+	api.HandleFunc("/api/vectors/pca", handlePCA)
+	api.HandleFunc("/api/vectors/pca/variance", handlePCAVariance)
+	api.HandleFunc("/api/vectors/histogram", handleHistogram)
+	api.HandleFunc("/api/vectors/bounds", handleBounds)
+	api.HandleFunc("/api/vectors/diff", handleDiff)
+	api.HandleFunc("/api/vectors/random", handleRandomVector)
+	api.HandleFunc("/api/vectors/{id}", handleVectorByID)
+	api.HandleFunc("/api/vectors/{id}/neighbors", handleNeighbors)
+	api.HandleFunc("/api/vectors/similarity-matrix", handleSimilarityMatrix)
+	api.HandleFunc("/api/vectors/graph", handleGraph)
+	api.HandleFunc("/api/vectors/kmeans", handleKMeans)
+	api.HandleFunc("/api/vectors/dbscan", handleDBSCAN)
+	api.HandleFunc("/api/vectors/pipeline", handlePipeline)
+	api.HandleFunc("/api/vectors/hierarchical", handleHierarchical)
+	api.HandleFunc("/api/vectors/metrics", handleClusterMetrics)
+	api.HandleFunc("/api/vectors/label-propagation", handleLabelPropagation)
+	api.HandleFunc("/api/vectors/tsne", handleTSNE)
+	api.HandleFunc("/api/vectors/umap", handleUMAP)
+	api.HandleFunc("/api/points", handlePoints)
+	api.HandleFunc("/api/stats", handleStats)
+	api.HandleFunc("/api/metadata/{field}/values", handleMetadataValues)
+	api.HandleFunc("/api/jobs", handleCreateJob)
+	api.HandleFunc("/api/jobs/{id}", handleJob)
+	api.HandleFunc("/api/jobs/{id}/result", handleJobResult)
+	api.HandleFunc("/api/admin/reset", handleAdminReset)
+	api.HandleFunc("/api/schema", handleSchema)
+
+	// Health checks are for infrastructure, not browsers, so they sit
+	// outside the CORS middleware. The live stream isn't covered by CORS,
+	// so it checks the Origin against the same allowlist.
+	origins := parseOrigins(*corsOrigins)
+	mux := http.NewServeMux()
+	mux.Handle("/api/", corsMiddleware(origins, api))
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", handleReadyz)
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.Handle("/ws/vectors", originMiddleware(origins, http.HandlerFunc(handleLiveVectors)))
+
+	// Throttled and timed-out requests still show up in the logs and metrics
+	var handler http.Handler = mux
+	if *requestTimeout > 0 {
+		handler = timeoutMiddleware(*requestTimeout, handler)
+	}
+	if *rateLimit > 0 {
+		handler = rateLimitMiddleware(newRateLimiter(*rateLimit, *rateBurst), handler)
+	}
 
 	// Start server
-	port := 8080
-	fmt.Printf("Server starting on port %d...\n", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
+	port, err := resolvePort(*portFlag, os.Getenv("PORT"))
+	if err != nil {
+		log.Fatalf("Invalid port: %v", err)
+	}
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: loggingMiddleware(requestLogger, metricsMiddleware(metrics, handler)),
+	}
+	server.RegisterOnShutdown(func() { close(liveShutdown) })
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		fmt.Printf("Server starting on port %d...\n", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// The gRPC server shares the generator but not the HTTP middleware
+	var grpcServer *http.Server
+	if *grpcPort != 0 {
+		grpcServer = newGRPCServer(fmt.Sprintf(":%d", *grpcPort), *requestTimeout)
+		go func() {
+			fmt.Printf("gRPC server starting on port %d...\n", *grpcPort)
+			if err := grpcServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	// Wait for a signal, then let in-flight requests drain
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", *shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown did not complete cleanly: %v", err)
+		return
+	}
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("gRPC shutdown did not complete cleanly: %v", err)
+			return
+		}
+	}
+	if err := waitLiveStreams(shutdownCtx); err != nil {
+		log.Printf("Live streams did not close cleanly: %v", err)
+		return
+	}
+	log.Print("Shutdown complete")
 }
 
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// testGeneratorConfig returns the default generation parameters with each
+// item in one cluster, so every point belongs to the center it was drawn
+// around
+func testGeneratorConfig() generatorConfig {
+	return generatorConfig{
+		Dimensions:   8,
+		Clusters:     3,
+		MinClusters:  1,
+		MaxClusters:  1,
+		Spread:       0.25,
+		Separation:   1,
+		Distribution: distributionUniform,
+		BaseTime:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		IDFormat:     idFormatSequential,
+		KeyFormat:    keyFormatRandom,
+		Seed:         42,
+	}
+}
+
+// checkClusterMeans generates n items and checks each cluster's sample
+// mean lies within four standard errors of its center, for points whose
+// coordinates have standard deviation sigma
+func checkClusterMeans(t *testing.T, config generatorConfig, n int, sigma float64) {
+	t.Helper()
+	generator := newVectorGenerator(rand.New(rand.NewSource(config.Seed)), config)
+
+	sums := make([][]float64, config.Clusters)
+	counts := make([]int, config.Clusters)
+	for i := range sums {
+		sums[i] = make([]float64, config.Dimensions)
+	}
+	for i := 0; i < n; i++ {
+		item := generator.Next()
+		c := -1
+		for idx, name := range generator.clusterNames {
+			if name == item.Clusters[0] {
+				c = idx
+			}
+		}
+		counts[c]++
+		for j, x := range item.Vector {
+			sums[c][j] += x
+		}
+	}
+
+	for c, center := range generator.clusterCenters {
+		if counts[c] == 0 {
+			t.Fatalf("cluster %d got no items", c)
+		}
+		tolerance := 4 * sigma / math.Sqrt(float64(counts[c]))
+		for j, sum := range sums[c] {
+			mean := sum / float64(counts[c])
+			if math.Abs(mean-center[j]) > tolerance {
+				t.Errorf("cluster %d dimension %d: mean %.4f, center %.4f, tolerance %.4f", c, j, mean, center[j], tolerance)
+			}
+		}
+	}
+}
+
+func TestGaussianSampleMeanNearCenter(t *testing.T) {
+	config := testGeneratorConfig()
+	config.Distribution = distributionGaussian
+	checkClusterMeans(t, config, 6000, config.Spread)
+}
+
+func TestUniformSampleMeanNearCenter(t *testing.T) {
+	// Uniform on ±spread has standard deviation spread/√3
+	config := testGeneratorConfig()
+	checkClusterMeans(t, config, 6000, config.Spread/math.Sqrt(3))
+}
+
+func TestParallelGenerationMatchesSerial(t *testing.T) {
+	config := testGeneratorConfig()
+	config.MaxClusters = 3
+	config.Distribution = distributionGaussian
+	config.OutlierFraction = 0.05
+	const size = 3*generationChunkSize + 17
+
+	serial, err := generateWithWorkers(context.Background(), rand.New(rand.NewSource(config.Seed)), size, config, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.Marshal(serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The iterator generates one item at a time without workers
+	generator := newVectorGenerator(rand.New(rand.NewSource(config.Seed)), config)
+	iterated := make([]VectorItem, size)
+	for i := range iterated {
+		iterated[i] = generator.Next()
+	}
+	if got, _ := json.Marshal(iterated); !bytes.Equal(got, want) {
+		t.Error("iterating the generator differs from serial generation")
+	}
+
+	for _, workers := range []int{2, 3, 8, runtime.NumCPU()} {
+		parallel, err := generateWithWorkers(context.Background(), rand.New(rand.NewSource(config.Seed)), size, config, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := json.Marshal(parallel); !bytes.Equal(got, want) {
+			t.Errorf("generation with %d workers differs from serial generation", workers)
+		}
+	}
+}
+
+// benchmarkGenerate generates a 50k×512 dataset with the given number of
+// workers
+func benchmarkGenerate(b *testing.B, workers int) {
+	config := testGeneratorConfig()
+	config.Dimensions = 512
+	config.Clusters = 10
+	for i := 0; i < b.N; i++ {
+		if _, err := generateWithWorkers(context.Background(), rand.New(rand.NewSource(config.Seed)), 50000, config, workers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerateSerial(b *testing.B) {
+	benchmarkGenerate(b, 1)
+}
+
+func BenchmarkGenerateParallel(b *testing.B) {
+	benchmarkGenerate(b, runtime.NumCPU())
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Metadata field types
+const (
+	fieldEnum   = "enum"   // One of Values
+	fieldPhrase = "phrase" // One value from each of Parts, joined by spaces
+	fieldTags   = "tags"   // MinItems to MaxItems distinct Values
+	fieldInt    = "int"    // Integer in [Min, Max]
+	fieldFloat  = "float"  // Number in [Min, Max) truncated to Decimals places
+	fieldDate   = "date"   // RFC 3339 time up to MaxDays days before the base time
+	fieldBool   = "bool"   // True with Probability
+)
+
+// metadataField describes how one generated metadata field is drawn
+type metadataField struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Values      []string   `json:"values,omitempty"`
+	Parts       [][]string `json:"parts,omitempty"`
+	MinItems    int        `json:"min_items,omitempty"`
+	MaxItems    int        `json:"max_items,omitempty"`
+	Min         float64    `json:"min,omitempty"`
+	Max         float64    `json:"max,omitempty"`
+	Decimals    int        `json:"decimals,omitempty"`
+	MaxDays     int        `json:"max_days,omitempty"`
+	Probability float64    `json:"probability,omitempty"`
+
+	// Correlation and Bias tie an enum field to the item's primary cluster
+	// when a request asks for correlated metadata. Bias maps a cluster
+	// name to the probability of each listed value, with the remaining
+	// probability spread evenly over all values. Clusters without a Bias
+	// entry prefer one value, chosen by cluster index, with probability
+	// Correlation.
+	Correlation float64                       `json:"correlation,omitempty"`
+	Bias        map[string]map[string]float64 `json:"bias,omitempty"`
+}
+
+// metadataCluster is the primary cluster correlated metadata depends on
+type metadataCluster struct {
+	Index int
+	Name  string
+}
+
+// metadataSchemaFile is the format of the -schema file
+type metadataSchemaFile struct {
+	Fields []metadataField `json:"fields"`
+}
+
+// metadataSchema lists the fields of generated metadata in draw order. It
+// is the built-in schema unless -schema replaces it at startup.
+var metadataSchema = builtinMetadataSchema()
+
+// builtinMetadataSchema returns the default metadata fields. Fields are
+// drawn in this order, so changing it changes seeded datasets.
+func builtinMetadataSchema() []metadataField {
+	return []metadataField{
+		{Name: "name", Type: fieldPhrase, Parts: [][]string{sampleAttributes, sampleNames}},
+		{Name: "type", Type: fieldEnum, Values: sampleTypes},
+		{Name: "category", Type: fieldEnum, Values: sampleCategories},
+		{Name: "rating", Type: fieldInt, Min: 1, Max: 5},
+		{Name: "value", Type: fieldFloat, Min: 10, Max: 1000, Decimals: 2},
+		{Name: "status", Type: fieldEnum, Values: sampleStatuses},
+		{Name: "priority", Type: fieldEnum, Values: samplePriorities, Correlation: 0.7},
+		{Name: "region", Type: fieldEnum, Values: sampleRegions},
+		{Name: "department", Type: fieldEnum, Values: sampleDepartments, Correlation: 0.5},
+		{Name: "created", Type: fieldDate, MaxDays: 365},
+		{Name: "isActive", Type: fieldBool, Probability: 0.8},
+		{Name: "score", Type: fieldInt, Min: 1, Max: 100},
+		{Name: "tags", Type: fieldTags, Values: sampleAttributes, MinItems: 0, MaxItems: 5},
+	}
+}
+
+// loadMetadataSchema reads and validates a -schema file
+func loadMetadataSchema(path string) ([]metadataField, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file metadataSchemaFile
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(file.Fields) == 0 {
+		return nil, fmt.Errorf("%s defines no fields", path)
+	}
+
+	seen := make(map[string]bool, len(file.Fields))
+	for i, field := range file.Fields {
+		if field.Name == "" {
+			return nil, fmt.Errorf("field %d has no name", i)
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("field %q is defined twice", field.Name)
+		}
+		seen[field.Name] = true
+		if err := field.validate(); err != nil {
+			return nil, fmt.Errorf("field %q: %w", field.Name, err)
+		}
+	}
+	return file.Fields, nil
+}
+
+// validate checks that the field's settings suit its type
+func (f metadataField) validate() error {
+	if (f.Correlation != 0 || f.Bias != nil) && f.Type != fieldEnum {
+		return fmt.Errorf("correlation and bias only apply to enum fields")
+	}
+	if f.Correlation < 0 || f.Correlation > 1 {
+		return fmt.Errorf("correlation must be between 0 and 1")
+	}
+	for cluster, weights := range f.Bias {
+		total := 0.0
+		for value, weight := range weights {
+			if !slices.Contains(f.Values, value) {
+				return fmt.Errorf("bias for %q names unknown value %q", cluster, value)
+			}
+			if weight < 0 {
+				return fmt.Errorf("bias for %q must not be negative", cluster)
+			}
+			total += weight
+		}
+		if total > 1 {
+			return fmt.Errorf("bias for %q adds up to more than 1", cluster)
+		}
+	}
+
+	switch f.Type {
+	case fieldEnum:
+		if len(f.Values) == 0 {
+			return fmt.Errorf("enum needs values")
+		}
+	case fieldPhrase:
+		if len(f.Parts) == 0 {
+			return fmt.Errorf("phrase needs parts")
+		}
+		for _, part := range f.Parts {
+			if len(part) == 0 {
+				return fmt.Errorf("phrase parts must not be empty")
+			}
+		}
+	case fieldTags:
+		if len(f.Values) == 0 {
+			return fmt.Errorf("tags needs values")
+		}
+		if f.MinItems < 0 || f.MinItems > f.MaxItems || f.MaxItems > len(f.Values) {
+			return fmt.Errorf("tags needs 0 <= min_items <= max_items <= %d", len(f.Values))
+		}
+	case fieldInt:
+		if f.Min != float64(int(f.Min)) || f.Max != float64(int(f.Max)) || f.Min > f.Max {
+			return fmt.Errorf("int needs integer min <= max")
+		}
+	case fieldFloat:
+		if !(f.Min < f.Max) {
+			return fmt.Errorf("float needs min < max")
+		}
+		if f.Decimals < 0 {
+			return fmt.Errorf("decimals must not be negative")
+		}
+	case fieldDate:
+		if f.MaxDays <= 0 {
+			return fmt.Errorf("date needs a positive max_days")
+		}
+	case fieldBool:
+		if f.Probability < 0 || f.Probability > 1 {
+			return fmt.Errorf("bool probability must be between 0 and 1")
+		}
+	default:
+		return fmt.Errorf("unknown type %q, expected one of %s", f.Type,
+			strings.Join([]string{fieldEnum, fieldPhrase, fieldTags, fieldInt, fieldFloat, fieldDate, fieldBool}, ", "))
+	}
+	return nil
+}
+
+// Generate draws a value for the field. Dates are offsets from baseTime.
+// Enum fields are biased towards cluster when it is set.
+func (f metadataField) Generate(rng *rand.Rand, baseTime time.Time, cluster *metadataCluster) interface{} {
+	switch f.Type {
+	case fieldEnum:
+		if cluster != nil {
+			if weights, ok := f.Bias[cluster.Name]; ok {
+				return f.drawWeighted(rng, weights)
+			}
+			if f.Correlation > 0 && rng.Float64() < f.Correlation {
+				return f.Values[cluster.Index%len(f.Values)]
+			}
+		}
+		return getRandomItem(rng, f.Values).(string)
+	case fieldPhrase:
+		words := make([]string, len(f.Parts))
+		for i, part := range f.Parts {
+			words[i] = getRandomItem(rng, part).(string)
+		}
+		return strings.Join(words, " ")
+	case fieldTags:
+		return getRandomItems(rng, f.Values, f.MinItems, f.MaxItems)
+	case fieldInt:
+		return int(f.Min) + rng.Intn(int(f.Max)-int(f.Min)+1)
+	case fieldFloat:
+		return getRandomNumber(rng, f.Min, f.Max, f.Decimals)
+	case fieldDate:
+		return baseTime.Add(-time.Duration(rng.Intn(f.MaxDays)) * 24 * time.Hour).Format(time.RFC3339)
+	case fieldBool:
+		return rng.Float64() > 1-f.Probability
+	default:
+		return nil
+	}
+}
+
+// Skip makes the same random draws as Generate without building a value,
+// so leaving a field out doesn't change the rest of the dataset. It must
+// be kept in step with Generate.
+func (f metadataField) Skip(rng *rand.Rand, cluster *metadataCluster) {
+	switch f.Type {
+	case fieldEnum:
+		if cluster != nil {
+			if _, ok := f.Bias[cluster.Name]; ok {
+				rng.Float64()
+				return
+			}
+			if f.Correlation > 0 && rng.Float64() < f.Correlation {
+				return
+			}
+		}
+		rng.Intn(len(f.Values))
+	case fieldPhrase:
+		for _, part := range f.Parts {
+			rng.Intn(len(part))
+		}
+	case fieldTags:
+		rng.Intn(f.MaxItems - f.MinItems + 1)
+		for i := len(f.Values) - 1; i > 0; i-- {
+			rng.Intn(i + 1)
+		}
+	case fieldInt:
+		rng.Intn(int(f.Max) - int(f.Min) + 1)
+	case fieldDate:
+		rng.Intn(f.MaxDays)
+	case fieldFloat, fieldBool:
+		rng.Float64()
+	}
+}
+
+// drawWeighted picks a value with the given probabilities, spreading the
+// probability they leave over every value
+func (f metadataField) drawWeighted(rng *rand.Rand, weights map[string]float64) string {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	even := (1 - total) / float64(len(f.Values))
+
+	u := rng.Float64()
+	for _, value := range f.Values {
+		u -= weights[value] + even
+		if u < 0 {
+			return value
+		}
+	}
+	// Rounding can leave a sliver at the end
+	return f.Values[len(f.Values)-1]
+}
+
+// generateMetadata draws every field of schema in order. A nil cluster
+// draws every value uniformly. When include is not nil only the fields it
+// marks are kept; the others are skipped.
+func generateMetadata(rng *rand.Rand, schema []metadataField, baseTime time.Time, cluster *metadataCluster, include []bool) map[string]interface{} {
+	metadata := make(map[string]interface{}, len(schema))
+	for i, field := range schema {
+		if include != nil && !include[i] {
+			field.Skip(rng, cluster)
+			continue
+		}
+		metadata[field.Name] = field.Generate(rng, baseTime, cluster)
+	}
+	return metadata
+}
+
+// includedFields marks the fields of schema named in names, or returns nil
+// to keep every field when names is nil
+func includedFields(schema []metadataField, names []string) []bool {
+	if names == nil {
+		return nil
+	}
+	include := make([]bool, len(schema))
+	for i, field := range schema {
+		for _, name := range names {
+			if field.Name == name {
+				include[i] = true
+			}
+		}
+	}
+	return include
+}
+
+// numericFields returns the names of the schema's int and float fields
+func numericFields(schema []metadataField) []string {
+	var names []string
+	for _, field := range schema {
+		if field.Type == fieldInt || field.Type == fieldFloat {
+			names = append(names, field.Name)
+		}
+	}
+	return names
+}
+
+// dateFields returns the names of the schema's date fields
+func dateFields(schema []metadataField) []string {
+	var names []string
+	for _, field := range schema {
+		if field.Type == fieldDate {
+			names = append(names, field.Name)
+		}
+	}
+	return names
+}
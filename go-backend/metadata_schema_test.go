@@ -0,0 +1,175 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// enumFrequencies draws n values of an enum field for cluster and returns
+// the share of each value
+func enumFrequencies(field metadataField, cluster *metadataCluster, n int) map[string]float64 {
+	rng := rand.New(rand.NewSource(1))
+	counts := make(map[string]float64)
+	for i := 0; i < n; i++ {
+		counts[field.Generate(rng, time.Time{}, cluster).(string)]++
+	}
+	for value := range counts {
+		counts[value] /= float64(n)
+	}
+	return counts
+}
+
+// checkFrequencies compares observed shares with expected ones within a
+// tolerance of four standard errors
+func checkFrequencies(t *testing.T, got, want map[string]float64, n int) {
+	t.Helper()
+	for value, p := range want {
+		tolerance := 4 * math.Sqrt(p*(1-p)/float64(n))
+		if math.Abs(got[value]-p) > tolerance {
+			t.Errorf("%q drawn with frequency %.4f, want %.4f ± %.4f", value, got[value], p, tolerance)
+		}
+	}
+}
+
+func TestCorrelatedEnumFrequencies(t *testing.T) {
+	const n = 20000
+	field := metadataField{Name: "priority", Type: fieldEnum, Values: []string{"Low", "Medium", "High", "Critical"}, Correlation: 0.7}
+	uniform := 1.0 / float64(len(field.Values))
+
+	// Without a cluster every value is equally likely
+	want := make(map[string]float64)
+	for _, value := range field.Values {
+		want[value] = uniform
+	}
+	checkFrequencies(t, enumFrequencies(field, nil, n), want, n)
+
+	// Each cluster prefers the value at its index, and otherwise draws
+	// uniformly
+	for index := 0; index < 6; index++ {
+		cluster := &metadataCluster{Index: index, Name: clusterNames(6)[index]}
+		preferred := field.Values[index%len(field.Values)]
+		want := make(map[string]float64)
+		for _, value := range field.Values {
+			want[value] = (1 - field.Correlation) * uniform
+		}
+		want[preferred] += field.Correlation
+
+		got := enumFrequencies(field, cluster, n)
+		checkFrequencies(t, got, want, n)
+		if got[preferred] < uniform+0.5 {
+			t.Errorf("cluster %d draws %q with frequency %.4f, barely above uniform", index, preferred, got[preferred])
+		}
+	}
+}
+
+func TestBiasedEnumFrequencies(t *testing.T) {
+	const n = 20000
+	field := metadataField{
+		Name:   "priority",
+		Type:   fieldEnum,
+		Values: []string{"Low", "Medium", "High"},
+		Bias: map[string]map[string]float64{
+			"Group A": {"High": 0.6},
+			"Group B": {"Low": 0.3, "Medium": 0.3},
+		},
+		Correlation: 0.9,
+	}
+	if err := field.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cluster metadataCluster
+		want    map[string]float64
+	}{
+		// The probability the bias leaves is spread over every value
+		{metadataCluster{Index: 0, Name: "Group A"}, map[string]float64{"Low": 0.4 / 3, "Medium": 0.4 / 3, "High": 0.6 + 0.4/3}},
+		{metadataCluster{Index: 1, Name: "Group B"}, map[string]float64{"Low": 0.3 + 0.4/3, "Medium": 0.3 + 0.4/3, "High": 0.4 / 3}},
+		// A cluster without a bias entry falls back to the correlation
+		{metadataCluster{Index: 2, Name: "Group C"}, map[string]float64{"Low": 0.1 / 3, "Medium": 0.1 / 3, "High": 0.9 + 0.1/3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cluster.Name, func(t *testing.T) {
+			checkFrequencies(t, enumFrequencies(field, &tt.cluster, n), tt.want, n)
+		})
+	}
+}
+
+func TestCorrelatedDatasetDiffersByCluster(t *testing.T) {
+	config := testGeneratorConfig()
+	config.Clusters = 4
+	config.Correlated = true
+	generator := newVectorGenerator(rand.New(rand.NewSource(config.Seed)), config)
+
+	// Count priorities by primary cluster
+	counts := make(map[string]map[string]int)
+	totals := make(map[string]int)
+	for i := 0; i < 8000; i++ {
+		item := generator.Next()
+		cluster := item.Clusters[0]
+		if counts[cluster] == nil {
+			counts[cluster] = make(map[string]int)
+		}
+		counts[cluster][item.Metadata["priority"].(string)]++
+		totals[cluster]++
+	}
+
+	uniform := 1.0 / float64(len(samplePriorities))
+	for index, cluster := range clusterNames(config.Clusters) {
+		preferred := samplePriorities[index%len(samplePriorities)]
+		share := float64(counts[cluster][preferred]) / float64(totals[cluster])
+		if share < uniform+0.4 {
+			t.Errorf("%s has %q on %.3f of its items, want well above uniform %.3f", cluster, preferred, share, uniform)
+		}
+	}
+}
+
+// skipTestFields returns fields of every type, with and without
+// correlation and bias
+func skipTestFields() []metadataField {
+	fields := builtinMetadataSchema()
+	return append(fields,
+		metadataField{Name: "biased", Type: fieldEnum, Values: []string{"a", "b", "c"}, Bias: map[string]map[string]float64{"Group A": {"a": 0.5}}},
+		metadataField{Name: "correlated", Type: fieldEnum, Values: []string{"a", "b"}, Correlation: 1},
+		metadataField{Name: "labels", Type: fieldTags, Values: []string{"x", "y", "z"}, MinItems: 1, MaxItems: 3},
+	)
+}
+
+func TestSkipStaysInStepWithGenerate(t *testing.T) {
+	clusters := []*metadataCluster{nil, {Index: 0, Name: "Group A"}, {Index: 1, Name: "Group B"}}
+	for _, field := range skipTestFields() {
+		for _, cluster := range clusters {
+			for seed := int64(0); seed < 50; seed++ {
+				generated := rand.New(rand.NewSource(seed))
+				skipped := rand.New(rand.NewSource(seed))
+				field.Generate(generated, time.Time{}, cluster)
+				field.Skip(skipped, cluster)
+				if generated.Int63() != skipped.Int63() {
+					t.Fatalf("field %q (cluster %v, seed %d): Skip draws differently from Generate", field.Name, cluster, seed)
+				}
+			}
+		}
+	}
+}
+
+func TestExcludedFieldsDontShiftOthers(t *testing.T) {
+	schema := skipTestFields()
+	baseTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	include := includedFields(schema, []string{"status", "score", "correlated"})
+	cluster := &metadataCluster{Index: 0, Name: "Group A"}
+
+	for seed := int64(0); seed < 50; seed++ {
+		full := generateMetadata(rand.New(rand.NewSource(seed)), schema, baseTime, cluster, nil)
+		partial := generateMetadata(rand.New(rand.NewSource(seed)), schema, baseTime, cluster, include)
+		if len(partial) != 3 {
+			t.Fatalf("partial metadata has %d fields, want 3", len(partial))
+		}
+		for name, value := range partial {
+			if full[name] != value {
+				t.Errorf("seed %d: %s = %v with fields excluded, %v without", seed, name, value, full[name])
+			}
+		}
+	}
+}
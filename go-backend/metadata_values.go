@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// Where enumerated metadata values came from
+const (
+	valuesSourceSchema  = "schema"  // The generator's value pool
+	valuesSourceDataset = "dataset" // A scan of the dataset
+)
+
+// MetadataValue is one distinct value of a metadata field. Count is the
+// number of items holding it, or for tags the number of items tagged with
+// it; it is null for values listed from the schema.
+type MetadataValue struct {
+	Value interface{} `json:"value"`
+	Count *int        `json:"count"`
+}
+
+// MetadataValuesResponse is the response structure for the distinct values
+// of a metadata field. Values are sorted booleans first, then numbers,
+// then strings.
+type MetadataValuesResponse struct {
+	Field  string          `json:"field"`
+	Source string          `json:"source"`
+	Values []MetadataValue `json:"values"`
+	Total  int             `json:"total"`
+}
+
+// metadataValueKey returns a key that is equal for equal values and sorts
+// values by kind: booleans, numbers, strings, then anything else
+func metadataValueKey(value interface{}) (int, string) {
+	if number, ok := toFloat(value); ok {
+		return 1, strconv.FormatFloat(number, 'g', -1, 64)
+	}
+	switch v := value.(type) {
+	case bool:
+		return 0, strconv.FormatBool(v)
+	case string:
+		return 2, v
+	default:
+		return 3, fmt.Sprint(v)
+	}
+}
+
+// sortMetadataValues orders values by kind, then numerically or
+// lexicographically within a kind
+func sortMetadataValues(values []MetadataValue) {
+	sort.SliceStable(values, func(a, b int) bool {
+		kindA, keyA := metadataValueKey(values[a].Value)
+		kindB, keyB := metadataValueKey(values[b].Value)
+		if kindA != kindB {
+			return kindA < kindB
+		}
+		if kindA == 1 {
+			numberA, _ := toFloat(values[a].Value)
+			numberB, _ := toFloat(values[b].Value)
+			return numberA < numberB
+		}
+		return keyA < keyB
+	})
+}
+
+// schemaValues returns the pool a generated field draws from, or false for
+// fields whose values can only be found by scanning
+func schemaValues(field metadataField) ([]MetadataValue, bool) {
+	var pool []interface{}
+	switch field.Type {
+	case fieldEnum, fieldTags:
+		for _, value := range field.Values {
+			pool = append(pool, value)
+		}
+	case fieldBool:
+		pool = []interface{}{false, true}
+	default:
+		return nil, false
+	}
+
+	values := make([]MetadataValue, len(pool))
+	for i, value := range pool {
+		values[i] = MetadataValue{Value: value}
+	}
+	return values, true
+}
+
+// countMetadataValues counts the distinct values of field over the items
+// yielded by next. Tag lists count each of their tags. It reports false if
+// no item has the field, and gives up with the context's error if ctx ends
+// first.
+func countMetadataValues(ctx context.Context, next func() (VectorItem, bool), field string) ([]MetadataValue, bool, error) {
+	type entry struct {
+		value interface{}
+		count int
+	}
+	entries := make(map[string]*entry)
+	found := false
+	add := func(value interface{}) {
+		kind, key := metadataValueKey(value)
+		key = strconv.Itoa(kind) + ":" + key
+		if e, ok := entries[key]; ok {
+			e.count++
+			return
+		}
+		entries[key] = &entry{value: value, count: 1}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		item, ok := next()
+		if !ok {
+			break
+		}
+		value, ok := item.Metadata[field]
+		if !ok {
+			continue
+		}
+		found = true
+		switch v := value.(type) {
+		case []string:
+			for _, tag := range v {
+				add(tag)
+			}
+		case []interface{}:
+			for _, tag := range v {
+				add(tag)
+			}
+		default:
+			add(v)
+		}
+	}
+
+	values := make([]MetadataValue, 0, len(entries))
+	for _, e := range entries {
+		count := e.count
+		values = append(values, MetadataValue{Value: e.value, Count: &count})
+	}
+	return values, found, nil
+}
+
+// handleMetadataValues lists the distinct values of a metadata field, for
+// filter dropdowns. Generated enum, tags and bool fields are answered
+// from the schema unless limit or size asks for counts over a dataset;
+// other fields and loaded datasets are scanned.
+func handleMetadataValues(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := r.PathValue("field")
+	response := MetadataValuesResponse{Field: name, Source: valuesSourceDataset}
+
+	if loadedItems() == nil {
+		var field *metadataField
+		for i := range metadataSchema {
+			if metadataSchema[i].Name == name {
+				field = &metadataSchema[i]
+			}
+		}
+		if field == nil && !(name == "outlier" && params.TagOutliers) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("metadata field %q not found", name))
+			return
+		}
+
+		// Only count values when the caller asks for a dataset size
+		counted := r.URL.Query().Get("limit") != "" || r.URL.Query().Get("size") != ""
+		if field != nil && !counted {
+			if values, ok := schemaValues(*field); ok {
+				response.Source = valuesSourceSchema
+				response.Values = values
+			}
+		}
+	}
+
+	// Generate data
+	if response.Values == nil {
+		values, found, err := countMetadataValues(r.Context(), datasetIterator(params), name)
+		if err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		if !found && loadedItems() != nil {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("metadata field %q not found", name))
+			return
+		}
+		response.Values = values
+	}
+	sortMetadataValues(response.Values)
+	response.Total = len(response.Values)
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(response)
+}
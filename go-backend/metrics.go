@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Latency buckets in seconds, matching the Prometheus client defaults
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// vectorsGenerated counts the synthetic items produced by every generator
+var vectorsGenerated atomic.Uint64
+
+// requestKey identifies a request counter series
+type requestKey struct {
+	method string
+	route  string
+	status int
+}
+
+// histogram is a cumulative Prometheus histogram over latencyBuckets
+type histogram struct {
+	counts []uint64 // Per bucket, not yet cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) Observe(v float64) {
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// metricsRegistry collects request metrics and renders them in the
+// Prometheus text exposition format. It is safe for concurrent use.
+type metricsRegistry struct {
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[string]*histogram // By route
+}
+
+// metrics is the registry served at /metrics
+var metrics = newMetricsRegistry()
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[string]*histogram),
+	}
+}
+
+// ObserveRequest records one completed request
+func (m *metricsRegistry) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{method: method, route: route, status: status}]++
+	h, ok := m.durations[route]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.durations[route] = h
+	}
+	h.Observe(duration.Seconds())
+}
+
+// WriteTo writes every metric in the text exposition format
+func (m *metricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	m.mu.Lock()
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, c := keys[i], keys[j]
+		if a.route != c.route {
+			return a.route < c.route
+		}
+		if a.method != c.method {
+			return a.method < c.method
+		}
+		return a.status < c.status
+	})
+	b.WriteString("# HELP http_requests_total Total HTTP requests by method, route and status.\n")
+	b.WriteString("# TYPE http_requests_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "http_requests_total{method=%s,route=%s,status=\"%d\"} %d\n",
+			quoteLabel(key.method), quoteLabel(key.route), key.status, m.requests[key])
+	}
+
+	routes := make([]string, 0, len(m.durations))
+	for route := range m.durations {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	b.WriteString("# HELP http_request_duration_seconds HTTP request latency by route.\n")
+	b.WriteString("# TYPE http_request_duration_seconds histogram\n")
+	for _, route := range routes {
+		h := m.durations[route]
+		label := quoteLabel(route)
+		cumulative := uint64(0)
+		for i, bound := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "http_request_duration_seconds_bucket{route=%s,le=\"%s\"} %d\n",
+				label, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "http_request_duration_seconds_bucket{route=%s,le=\"+Inf\"} %d\n", label, h.count)
+		fmt.Fprintf(&b, "http_request_duration_seconds_sum{route=%s} %s\n", label, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "http_request_duration_seconds_count{route=%s} %d\n", label, h.count)
+	}
+	m.mu.Unlock()
+
+	b.WriteString("# HELP vectors_generated_total Synthetic vectors generated.\n")
+	b.WriteString("# TYPE vectors_generated_total counter\n")
+	fmt.Fprintf(&b, "vectors_generated_total %d\n", vectorsGenerated.Load())
+
+	if vectorCache != nil {
+		b.WriteString("# HELP response_cache_entries Responses currently held in the cache.\n")
+		b.WriteString("# TYPE response_cache_entries gauge\n")
+		fmt.Fprintf(&b, "response_cache_entries %d\n", vectorCache.Len())
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// quoteLabel quotes a label value, escaping as the exposition format
+// requires
+func quoteLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+// metricsMiddleware records the count and latency of every request.
+// Requests are labelled with the pattern of the route that served them
+// rather than the raw path, so IDs in paths don't create new series.
+func metricsMiddleware(registry *metricsRegistry, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		registry.ObserveRequest(r.Method, route, status, time.Since(start))
+	})
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.WriteTo(w)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// statusRecorder captures the status code and body size written by a
+// handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Flush keeps streaming responses working through the recorder
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// newRequestLogger returns a logger writing to stderr in the given format,
+// either "text" or "json"
+func newRequestLogger(format string) (*slog.Logger, error) {
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, nil)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+}
+
+// loggingMiddleware logs one line per request with its status, response
+// size and duration
+func loggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"query", r.URL.RawQuery,
+			"status", status,
+			"bytes", recorder.bytes,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// originAllowlist is a set of allowed origins, where "*" allows every
+// origin
+type originAllowlist struct {
+	all     bool
+	origins map[string]bool
+}
+
+func newOriginAllowlist(origins []string) originAllowlist {
+	allowed := originAllowlist{origins: make(map[string]bool, len(origins))}
+	for _, origin := range origins {
+		if origin == "*" {
+			allowed.all = true
+		}
+		allowed.origins[origin] = true
+	}
+	return allowed
+}
+
+// Allows reports whether origin is on the list
+func (a originAllowlist) Allows(origin string) bool {
+	return a.all || a.origins[origin]
+}
+
+// corsMiddleware sets the CORS headers for allowed origins and answers
+// preflight requests. An allowlist containing "*" allows every origin;
+// otherwise the request's Origin is echoed back only when it is listed.
+func corsMiddleware(allowedOrigins []string, next http.Handler) http.Handler {
+	allowed := newOriginAllowlist(allowedOrigins)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		switch {
+		case allowed.all:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case allowed.Allows(origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if !allowed.all {
+			// The response depends on the Origin, so caches must key on it
+			w.Header().Add("Vary", "Origin")
+		}
+		if w.Header().Get("Access-Control-Allow-Origin") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match, Authorization")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, Location")
+		}
+
+		// Handle preflight request
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// originMiddleware refuses requests whose Origin isn't on the allowlist
+// corsMiddleware uses. Browsers don't apply CORS to WebSocket upgrades, so
+// without it any site could open a socket. Requests without an Origin come
+// from clients other than browsers and pass.
+func originMiddleware(allowedOrigins []string, next http.Handler) http.Handler {
+	allowed := newOriginAllowlist(allowedOrigins)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && !allowed.Allows(origin) {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("origin %q is not allowed", origin))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseOrigins splits a comma-separated origin allowlist
+func parseOrigins(value string) []string {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
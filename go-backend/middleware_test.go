@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		allowed []string
+		origin  string
+		want    int
+	}{
+		{[]string{"https://app.example"}, "https://app.example", http.StatusOK},
+		{[]string{"https://app.example"}, "https://evil.example", http.StatusForbidden},
+		{[]string{"https://app.example"}, "", http.StatusOK},
+		{[]string{"*"}, "https://evil.example", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/ws/vectors", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		w := httptest.NewRecorder()
+		originMiddleware(tt.allowed, ok).ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("allowlist %v, Origin %q: status %d, want %d", tt.allowed, tt.origin, w.Code, tt.want)
+		}
+	}
+}
+
+func TestCORSAllowlist(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for origin, want := range map[string]string{
+		"https://app.example":  "https://app.example",
+		"https://evil.example": "",
+	} {
+		r := httptest.NewRequest("GET", "/api/vectors", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		corsMiddleware([]string{"https://app.example"}, ok).ServeHTTP(w, r)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("Origin %q: Access-Control-Allow-Origin %q, want %q", origin, got, want)
+		}
+	}
+}
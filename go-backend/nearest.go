@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// nearestAnnotator names the generated cluster center closest to each
+// vector under a metric. Comparing it with an item's assigned clusters
+// shows whether the generator's jitter moved the point into another
+// cluster.
+type nearestAnnotator struct {
+	names   []string
+	centers [][]float64
+	metric  similarityMetric
+}
+
+// parseNearest reads the annotate_nearest and metric query parameters. It
+// returns nil when annotation wasn't asked for.
+func parseNearest(query url.Values, params vectorParams) (*nearestAnnotator, error) {
+	annotateStr := query.Get("annotate_nearest")
+	if annotateStr == "" {
+		return nil, nil
+	}
+	annotate, err := strconv.ParseBool(annotateStr)
+	if err != nil {
+		return nil, fmt.Errorf("annotate_nearest must be true or false")
+	}
+	if !annotate {
+		return nil, nil
+	}
+	if loadedItems() != nil {
+		return nil, fmt.Errorf("annotate_nearest needs generated data, loaded datasets have no cluster centers")
+	}
+
+	// Points scatter around their centers by L2 offsets, so euclidean
+	// matches the generator best
+	metric, err := parseMetric(query, metricEuclidean)
+	if err != nil {
+		return nil, err
+	}
+
+	// The same centers /api/clusters reports for these parameters
+	centers := clusterCenters(params.Rand(), params.generatorConfig)
+	if params.Normalize {
+		for i := range centers {
+			centers[i] = normalizeVector(centers[i])
+		}
+	}
+
+	return &nearestAnnotator{
+		names:   clusterNames(params.Clusters),
+		centers: centers,
+		metric:  metric,
+	}, nil
+}
+
+// Nearest returns the name of the center closest to vector
+func (a *nearestAnnotator) Nearest(vector []float64) string {
+	best := 0
+	bestScore := a.metric.Score(vector, a.centers[0])
+	for i := 1; i < len(a.centers); i++ {
+		if score := a.metric.Score(vector, a.centers[i]); a.metric.Closer(score, bestScore) {
+			best, bestScore = i, score
+		}
+	}
+	return a.names[best]
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Bounds on the datasets kept with their norms: how many at once, and
+// roughly how many bytes of items and norms they may hold together. A
+// dataset bigger than the byte bound is scored without being cached.
+const (
+	normCacheCapacity = 4
+	normCacheMaxBytes = 512 << 20
+)
+
+// normItemOverhead estimates the bytes an item takes besides its vectors:
+// the struct itself and its metadata
+const normItemOverhead = 512
+
+// normedDataset is a dataset with the L2 norm of each item's vector, so
+// cosine scores need only a dot product per pair. It is shared between
+// requests and must not be modified.
+type normedDataset struct {
+	items []VectorItem
+	norms []float64
+}
+
+// vectorNorms returns the L2 norm of each item's vector
+func vectorNorms(data []VectorItem) []float64 {
+	norms := make([]float64, len(data))
+	for i, item := range data {
+		norms[i] = math.Sqrt(dotProduct(item.Vector, item.Vector))
+	}
+	return norms
+}
+
+// Bytes estimates the memory the dataset holds
+func (d *normedDataset) Bytes() int {
+	size := 8 * len(d.norms)
+	for _, item := range d.items {
+		size += normItemOverhead + 8*len(item.Vector)
+		for _, vector := range item.Vectors {
+			size += 8 * len(vector)
+		}
+	}
+	return size
+}
+
+// cosineWithNorms is cosineSimilarity for vectors whose norms are known
+func cosineWithNorms(a, b []float64, normA, normB float64) float64 {
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dotProduct(a, b) / (normA * normB)
+}
+
+// normCache is a small LRU of datasets with their norms, keyed by
+// everything that determines the dataset and bounded both by count and by
+// bytes. It is safe for concurrent use.
+type normCache struct {
+	mu       sync.Mutex
+	capacity int
+	maxBytes int
+	bytes    int
+	entries  map[string]*list.Element
+	order    *list.List // Most recently used at the front
+}
+
+type normCacheEntry struct {
+	key     string
+	dataset *normedDataset
+	bytes   int
+}
+
+// normDatasets holds the datasets scored by the similarity endpoints
+var normDatasets = newNormCache(normCacheCapacity, normCacheMaxBytes)
+
+func newNormCache(capacity, maxBytes int) *normCache {
+	return &normCache{
+		capacity: capacity,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the dataset stored under key
+func (c *normCache) Get(key string) (*normedDataset, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*normCacheEntry).dataset, true
+}
+
+// Set stores dataset under key, evicting the least recently used entries
+// until the cache is back within its bounds. A dataset over the byte bound
+// on its own is not stored.
+func (c *normCache) Set(key string, dataset *normedDataset) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	size := dataset.Bytes()
+	if size > c.maxBytes {
+		return
+	}
+	c.entries[key] = c.order.PushFront(&normCacheEntry{key: key, dataset: dataset, bytes: size})
+	c.bytes += size
+	for c.order.Len() > c.capacity || c.bytes > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry; the caller holds the lock
+func (c *normCache) remove(element *list.Element) {
+	entry := element.Value.(*normCacheEntry)
+	c.order.Remove(element)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.bytes
+}
+
+// Clear drops every dataset, for when the data behind them has changed,
+// and returns how many there were
+func (c *normCache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cleared := c.order.Len()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+	return cleared
+}
+
+// normCacheKey identifies the dataset for a request. As for annCacheKey
+// the seed only matters for generated data.
+func normCacheKey(params vectorParams) string {
+	generation, _ := json.Marshal(generationParams(params))
+	seed := params.Seed
+	if loadedItems() != nil {
+		seed = 0
+	}
+	return fmt.Sprintf("%d|%s", seed, generation)
+}
+
+// similarityDataset returns the request's dataset with its norms,
+// computing them on first use. Unseeded synthetic data differs on every
+// request, so it is generated each time and not cached.
+func similarityDataset(ctx context.Context, params vectorParams) (*normedDataset, error) {
+	cacheable := params.Seeded || loadedItems() != nil
+	key := normCacheKey(params)
+	if cacheable {
+		if dataset, ok := normDatasets.Get(key); ok {
+			return dataset, nil
+		}
+	}
+
+	data, err := datasetItems(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	dataset := &normedDataset{items: data, norms: vectorNorms(data)}
+
+	if cacheable {
+		normDatasets.Set(key, dataset)
+	}
+	return dataset, nil
+}
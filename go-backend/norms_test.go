@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// testNormedDataset returns a dataset of n items with vectors of the given
+// dimensions
+func testNormedDataset(n, dimensions int) *normedDataset {
+	items := make([]VectorItem, n)
+	for i := range items {
+		items[i] = VectorItem{ID: strconv.Itoa(i), Vector: make([]float64, dimensions)}
+	}
+	return &normedDataset{items: items, norms: vectorNorms(items)}
+}
+
+func TestNormCacheByteBound(t *testing.T) {
+	dataset := testNormedDataset(10, 100)
+	size := dataset.Bytes()
+	if want := 10*(normItemOverhead+800) + 80; size != want {
+		t.Fatalf("dataset is %d bytes, want %d", size, want)
+	}
+
+	// Room for two datasets by bytes though four by count
+	cache := newNormCache(4, 2*size+size/2)
+	for _, key := range []string{"a", "b", "c"} {
+		cache.Set(key, testNormedDataset(10, 100))
+	}
+	if _, ok := cache.Get("a"); ok {
+		t.Error("least recently used dataset survived going over the byte bound")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("dataset %q was evicted", key)
+		}
+	}
+	if cache.bytes != 2*size {
+		t.Errorf("cache counts %d bytes, want %d", cache.bytes, 2*size)
+	}
+
+	// Replacing an entry counts only the new dataset
+	cache.Set("c", testNormedDataset(5, 100))
+	if want := size + testNormedDataset(5, 100).Bytes(); cache.bytes != want {
+		t.Errorf("cache counts %d bytes after a replacement, want %d", cache.bytes, want)
+	}
+
+	cache.Clear()
+	if cache.bytes != 0 {
+		t.Errorf("cache counts %d bytes after Clear", cache.bytes)
+	}
+}
+
+func TestNormCacheSkipsOversizedDataset(t *testing.T) {
+	small := testNormedDataset(10, 100)
+	cache := newNormCache(4, 2*small.Bytes())
+	cache.Set("small", small)
+	cache.Set("big", testNormedDataset(100, 100))
+	if _, ok := cache.Get("big"); ok {
+		t.Error("dataset over the byte bound was cached")
+	}
+	if _, ok := cache.Get("small"); !ok {
+		t.Error("an oversized dataset evicted the others")
+	}
+}
+
+// benchmarkSimilarityMatrix scores a 1000-item cosine similarity matrix,
+// with the norms precomputed or not
+func benchmarkSimilarityMatrix(b *testing.B, withNorms bool) {
+	config := testGeneratorConfig()
+	config.Dimensions = 128
+	generator := newVectorGenerator(rand.New(rand.NewSource(config.Seed)), config)
+	data := make([]VectorItem, 1000)
+	for i := range data {
+		data[i] = generator.Next()
+	}
+	metric, _ := metricByName(metricCosine)
+
+	var norms []float64
+	if withNorms {
+		norms = vectorNorms(data)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		similarityMatrix(data, norms, metric)
+	}
+}
+
+func BenchmarkSimilarityMatrixWithoutNorms(b *testing.B) {
+	benchmarkSimilarityMatrix(b, false)
+}
+
+func BenchmarkSimilarityMatrixWithNorms(b *testing.B) {
+	benchmarkSimilarityMatrix(b, true)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// npyMagic starts every .npy file, followed by the format version
+const npyMagic = "\x93NUMPY"
+
+// npyHeader returns the version 1.0 header for a C-order little-endian
+// float64 matrix, or float32 when asked. The header dict is padded with
+// spaces and a newline so the data starts on a 64-byte boundary, as the
+// format specification requires.
+func npyHeader(rows, cols int, asFloat32 bool) []byte {
+	descr := "<f8"
+	if asFloat32 {
+		descr = "<f4"
+	}
+	dict := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%d, %d), }", descr, rows, cols)
+
+	// Magic, two version bytes and the two-byte header length precede the dict
+	prefix := len(npyMagic) + 4
+	padding := 64 - (prefix+len(dict)+1)%64
+	if padding == 64 {
+		padding = 0
+	}
+	dict += strings.Repeat(" ", padding) + "\n"
+
+	header := make([]byte, 0, prefix+len(dict))
+	header = append(header, npyMagic...)
+	header = append(header, 1, 0)
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(dict)))
+	return append(header, dict...)
+}
+
+// writeNPY writes vectors as a rows × cols float64 .npy array, or float32
+// when asked. Every vector must have cols elements.
+func writeNPY(w io.Writer, vectors [][]float64, cols int, asFloat32 bool) error {
+	buffered := bufio.NewWriter(w)
+	if _, err := buffered.Write(npyHeader(len(vectors), cols, asFloat32)); err != nil {
+		return err
+	}
+
+	var cell []byte
+	for _, v := range vectors {
+		for _, x := range v {
+			if asFloat32 {
+				cell = binary.LittleEndian.AppendUint32(cell[:0], math.Float32bits(float32(x)))
+			} else {
+				cell = binary.LittleEndian.AppendUint64(cell[:0], math.Float64bits(x))
+			}
+			if _, err := buffered.Write(cell); err != nil {
+				return err
+			}
+		}
+	}
+	return buffered.Flush()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// npyDictPattern matches the header dict npyHeader writes, as the format
+// specification describes it
+var npyDictPattern = regexp.MustCompile(`^\{'descr': '(<f[48])', 'fortran_order': False, 'shape': \((\d+), (\d+)\), \} *\n$`)
+
+// readNPYHeader parses the header of a version 1.0 .npy file as the
+// specification describes it, failing the test on anything that doesn't
+// follow it, and returns where the data starts
+func readNPYHeader(t *testing.T, file []byte) (descr string, rows, cols, dataStart int) {
+	t.Helper()
+	if !bytes.HasPrefix(file, []byte(npyMagic)) {
+		t.Fatalf("file starts %q, want the magic string", file[:min(len(file), 6)])
+	}
+	if major, minor := file[6], file[7]; major != 1 || minor != 0 {
+		t.Fatalf("version %d.%d, want 1.0", major, minor)
+	}
+	headerLen := int(binary.LittleEndian.Uint16(file[8:10]))
+	dataStart = 10 + headerLen
+	if dataStart%64 != 0 {
+		t.Errorf("data starts at byte %d, not on a 64-byte boundary", dataStart)
+	}
+
+	match := npyDictPattern.FindSubmatch(file[10:dataStart])
+	if match == nil {
+		t.Fatalf("header dict %q doesn't follow the format", file[10:dataStart])
+	}
+	descr = string(match[1])
+	rows, _ = strconv.Atoi(string(match[2]))
+	cols, _ = strconv.Atoi(string(match[3]))
+	return descr, rows, cols, dataStart
+}
+
+// readNPY parses a version 1.0 .npy file and reads its values back
+func readNPY(t *testing.T, file []byte) (descr string, rows, cols int, values []float64) {
+	t.Helper()
+	descr, rows, cols, dataStart := readNPYHeader(t, file)
+	data := file[dataStart:]
+	size := 8
+	if descr == "<f4" {
+		size = 4
+	}
+	if len(data) != rows*cols*size {
+		t.Fatalf("data is %d bytes, want %d for shape (%d, %d) of %s", len(data), rows*cols*size, rows, cols, descr)
+	}
+	for i := 0; i < len(data); i += size {
+		if size == 4 {
+			values = append(values, float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i:]))))
+		} else {
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(data[i:])))
+		}
+	}
+	return descr, rows, cols, values
+}
+
+func TestNPYRoundTrip(t *testing.T) {
+	vectors := [][]float64{
+		{0, 1, -1},
+		{math.Pi, -math.E, 1e-300},
+		{math.MaxFloat64, math.SmallestNonzeroFloat64, -0.5},
+		{1.5, 2.25, 3.125},
+	}
+	var buf bytes.Buffer
+	if err := writeNPY(&buf, vectors, 3, false); err != nil {
+		t.Fatal(err)
+	}
+
+	descr, rows, cols, values := readNPY(t, buf.Bytes())
+	if descr != "<f8" || rows != 4 || cols != 3 {
+		t.Fatalf("got %s (%d, %d), want <f8 (4, 3)", descr, rows, cols)
+	}
+	for i, v := range vectors {
+		for j, x := range v {
+			if got := values[i*cols+j]; got != x {
+				t.Errorf("value [%d, %d] = %v, want %v", i, j, got, x)
+			}
+		}
+	}
+}
+
+func TestNPYRoundTripFloat32(t *testing.T) {
+	vectors := [][]float64{{0.1, -0.2}, {1e10, 3}}
+	var buf bytes.Buffer
+	if err := writeNPY(&buf, vectors, 2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	descr, rows, cols, values := readNPY(t, buf.Bytes())
+	if descr != "<f4" || rows != 2 || cols != 2 {
+		t.Fatalf("got %s (%d, %d), want <f4 (2, 2)", descr, rows, cols)
+	}
+	for i, v := range vectors {
+		for j, x := range v {
+			if got := values[i*cols+j]; got != float64(float32(x)) {
+				t.Errorf("value [%d, %d] = %v, want %v", i, j, got, float32(x))
+			}
+		}
+	}
+}
+
+func TestNPYHeaderAlignment(t *testing.T) {
+	// Shapes whose dicts have different lengths all pad to the boundary
+	for _, shape := range [][2]int{{0, 0}, {1, 1}, {9, 99}, {12345, 4096}, {100000, 1}} {
+		for _, asFloat32 := range []bool{false, true} {
+			header := npyHeader(shape[0], shape[1], asFloat32)
+			if len(header)%64 != 0 {
+				t.Errorf("header for %v is %d bytes, not a multiple of 64", shape, len(header))
+			}
+			_, rows, cols, _ := readNPYHeader(t, header)
+			if rows != shape[0] || cols != shape[1] {
+				t.Errorf("header for %v reads as (%d, %d)", shape, rows, cols)
+			}
+		}
+	}
+}
+
+func TestNPYEmptyMatrix(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeNPY(&buf, nil, 5, false); err != nil {
+		t.Fatal(err)
+	}
+	descr, rows, cols, values := readNPY(t, buf.Bytes())
+	if descr != "<f8" || rows != 0 || cols != 5 || len(values) != 0 {
+		t.Errorf("got %s (%d, %d) with %d values, want an empty <f8 (0, 5)", descr, rows, cols, len(values))
+	}
+}
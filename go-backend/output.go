@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// outputOptions control how items are shaped before they are encoded
+type outputOptions struct {
+	// Fields lists the metadata keys to keep; nil keeps them all
+	Fields []string
+	// OmitVectors drops the primary and named vectors from each item
+	OmitVectors bool
+	// VectorDims keeps only the first VectorDims components of the primary
+	// vector; 0 keeps them all
+	VectorDims int
+	// Nearest, when set, annotates each item with its nearest cluster
+	// center before the vectors are dropped
+	Nearest *nearestAnnotator
+	// Quantize names the scheme used to add a quantized copy of the
+	// primary vector; empty adds none. The copy survives OmitVectors.
+	Quantize string
+	// Sparse returns the primary vector as a SparseVector instead
+	Sparse bool
+	// Scale, when set, rescales the primary vector after Nearest has seen
+	// it and before it is cut, quantized or made sparse
+	Scale *minMaxScaler
+	// Precision, when set, rounds vector components and float metadata to
+	// that many decimals; nil keeps full precision
+	Precision *int
+	// Float32 rounds vector components to float32 precision, as dtype=float32
+	// asks
+	Float32 bool
+}
+
+// The most decimals precision may ask for; float64 carries about 15
+// significant digits
+const maxPrecision = 15
+
+// parseOutputOptions reads the response shaping parameters from the query
+// string. dimensions is the length of the vectors being returned.
+func parseOutputOptions(query url.Values, dimensions int) (outputOptions, error) {
+	var opts outputOptions
+
+	if fieldsStr := query.Get("fields"); fieldsStr != "" {
+		for _, field := range strings.Split(fieldsStr, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				opts.Fields = append(opts.Fields, field)
+			}
+		}
+	}
+
+	if includeStr := query.Get("include_vectors"); includeStr != "" {
+		include, err := strconv.ParseBool(includeStr)
+		if err != nil {
+			return opts, fmt.Errorf("include_vectors must be true or false")
+		}
+		opts.OmitVectors = !include
+	}
+
+	if vectorDimsStr := query.Get("vector_dims"); vectorDimsStr != "" {
+		vectorDims, err := strconv.Atoi(vectorDimsStr)
+		if err != nil || vectorDims <= 0 || vectorDims > dimensions {
+			return opts, fmt.Errorf("vector_dims must be an integer between 1 and %d", dimensions)
+		}
+		opts.VectorDims = vectorDims
+	}
+
+	if precisionStr := query.Get("precision"); precisionStr != "" {
+		precision, err := strconv.Atoi(precisionStr)
+		if err != nil || precision < 0 || precision > maxPrecision {
+			return opts, fmt.Errorf("precision must be an integer between 0 and %d", maxPrecision)
+		}
+		opts.Precision = &precision
+	}
+
+	asFloat32, err := parseDType(query)
+	if err != nil {
+		return opts, err
+	}
+	opts.Float32 = asFloat32
+
+	opts.Sparse = query.Get("format") == "sparse"
+
+	if quantize := query.Get("quantize"); quantize != "" {
+		if quantize != quantizeInt8 {
+			return opts, fmt.Errorf("quantize must be %q", quantizeInt8)
+		}
+		opts.Quantize = quantize
+	}
+
+	return opts, nil
+}
+
+// Apply returns item shaped for output. Metadata is copied rather than
+// edited in place since items may share maps with the loaded dataset.
+func (opts outputOptions) Apply(item VectorItem) VectorItem {
+	if opts.Nearest != nil {
+		item.NearestCluster = opts.Nearest.Nearest(item.Vector)
+	}
+	if opts.Scale != nil && item.Vector != nil {
+		item.Vector = opts.Scale.Scale(item.Vector)
+	}
+	if opts.VectorDims > 0 && len(item.Vector) > opts.VectorDims {
+		// Generation used every dimension; only the output is cut
+		item.Vector = item.Vector[:opts.VectorDims]
+	}
+	if opts.Quantize == quantizeInt8 && item.Vector != nil {
+		quantized, scale := quantizeVectorInt8(item.Vector)
+		item.QuantizedVector = quantized
+		item.QuantizationScale = &scale
+	}
+	if opts.Fields != nil {
+		metadata := make(map[string]interface{}, len(opts.Fields))
+		for _, field := range opts.Fields {
+			if value, ok := item.Metadata[field]; ok {
+				metadata[field] = value
+			}
+		}
+		item.Metadata = metadata
+	}
+	if opts.Precision != nil {
+		item = roundItem(item, *opts.Precision)
+	}
+	if opts.Float32 {
+		item.Vector = float32Vector(item.Vector)
+		if item.Vectors != nil {
+			vectors := make(map[string][]float64, len(item.Vectors))
+			for name, vector := range item.Vectors {
+				vectors[name] = float32Vector(vector)
+			}
+			item.Vectors = vectors
+		}
+	}
+	if opts.Sparse && item.Vector != nil {
+		sparse := toSparse(item.Vector)
+		item.SparseVector = &sparse
+		item.Vector = nil
+	}
+	if opts.OmitVectors {
+		item.Vector = nil
+		item.Vectors = nil
+		item.SparseVector = nil
+	}
+	return item
+}
+
+// ApplyAll shapes every item in data, returning a new slice
+func (opts outputOptions) ApplyAll(data []VectorItem) []VectorItem {
+	shaped := make([]VectorItem, len(data))
+	for i, item := range data {
+		shaped[i] = opts.Apply(item)
+	}
+	return shaped
+}
+
+// roundValue rounds x to the given number of decimals, scaling by a power
+// of ten like getRandomNumber but rounding to nearest
+func roundValue(x float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	rounded := math.Round(x*factor) / factor
+	if math.IsInf(rounded, 0) || math.IsNaN(rounded) {
+		// x*factor overflowed; x has no digits left to drop
+		return x
+	}
+	if rounded == 0 {
+		// Small negatives round to -0, which encodes as "-0"
+		return 0
+	}
+	return rounded
+}
+
+// roundVector returns a rounded copy of v
+func roundVector(v []float64, decimals int) []float64 {
+	if v == nil {
+		return nil
+	}
+	rounded := make([]float64, len(v))
+	for i, x := range v {
+		rounded[i] = roundValue(x, decimals)
+	}
+	return rounded
+}
+
+// roundItem rounds item's vectors and float metadata, copying rather than
+// editing anything the item may share with the dataset
+func roundItem(item VectorItem, decimals int) VectorItem {
+	item.Vector = roundVector(item.Vector, decimals)
+	if item.Vectors != nil {
+		vectors := make(map[string][]float64, len(item.Vectors))
+		for name, vector := range item.Vectors {
+			vectors[name] = roundVector(vector, decimals)
+		}
+		item.Vectors = vectors
+	}
+	metadata := make(map[string]interface{}, len(item.Metadata))
+	for key, value := range item.Metadata {
+		if f, ok := value.(float64); ok {
+			value = roundValue(f, decimals)
+		}
+		metadata[key] = value
+	}
+	item.Metadata = metadata
+	return item
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRow is one item as the export writes it, keyed by column name
+type parquetRow = map[string]interface{}
+
+// writeParquet writes items as a Parquet file with id, key, vector and
+// clusters columns plus one optional column per metadata key, in column
+// name order. Vectors are DOUBLE, or FLOAT when asFloat32 is set.
+func writeParquet(w io.Writer, items []VectorItem, layout string, asFloat32 bool) error {
+	schema, rows := parquetExport(items, layout, asFloat32)
+	writer := parquet.NewGenericWriter[parquetRow](w, schema)
+	if _, err := writer.Write(rows); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// parquetExport returns the schema and rows of the export
+func parquetExport(items []VectorItem, layout string, asFloat32 bool) (*parquet.Schema, []parquetRow) {
+	component := parquet.Leaf(parquet.DoubleType)
+	if asFloat32 {
+		component = parquet.Leaf(parquet.FloatType)
+	}
+	group := parquet.Group{
+		"id":       parquet.String(),
+		"key":      parquet.String(),
+		"clusters": parquet.List(parquet.String()),
+	}
+	if layout != layoutWide {
+		group["vector"] = parquet.List(component)
+	} else if len(items) > 0 {
+		for d := range items[0].Vector {
+			group[fmt.Sprintf("vector_%d", d)] = component
+		}
+	}
+
+	rows := make([]parquetRow, len(items))
+	for i, item := range items {
+		row := parquetRow{"id": item.ID, "key": item.Key, "clusters": append([]string{}, item.Clusters...)}
+		switch {
+		case layout == layoutWide:
+			for d, x := range item.Vector {
+				if asFloat32 {
+					row[fmt.Sprintf("vector_%d", d)] = float32(x)
+				} else {
+					row[fmt.Sprintf("vector_%d", d)] = x
+				}
+			}
+		case asFloat32:
+			row["vector"] = narrowFloat32(item.Vector)
+		default:
+			row["vector"] = append([]float64{}, item.Vector...)
+		}
+		rows[i] = row
+	}
+
+	parquetMetadataColumns(group, rows, items)
+	return parquet.NewSchema("vector_item", group), rows
+}
+
+// narrowFloat32 converts v to float32
+func narrowFloat32(v []float64) []float32 {
+	narrowed := make([]float32, len(v))
+	for i, x := range v {
+		narrowed[i] = float32(x)
+	}
+	return narrowed
+}
+
+// parquetMetadataColumns flattens item metadata into one optional column
+// per key, adding it to group and each item's value to its row. Each
+// column takes the Parquet type that fits all of its values: INT64,
+// DOUBLE, BOOLEAN, UTF8 strings or lists of strings, with anything else
+// encoded as a JSON string. Keys that clash with the fixed columns are
+// prefixed with metadata_.
+func parquetMetadataColumns(group parquet.Group, rows []parquetRow, items []VectorItem) {
+	kinds := make(map[string]string)
+	for _, item := range items {
+		for key, value := range item.Metadata {
+			kind := "json"
+			switch value.(type) {
+			case int, int64:
+				kind = "int"
+			case float64:
+				kind = "float"
+			case bool:
+				kind = "bool"
+			case string:
+				kind = "string"
+			case []string:
+				kind = "strings"
+			}
+			previous, seen := kinds[key]
+			switch {
+			case !seen:
+				kinds[key] = kind
+			case previous == kind:
+			case (previous == "int" && kind == "float") || (previous == "float" && kind == "int"):
+				kinds[key] = "float"
+			default:
+				kinds[key] = "json"
+			}
+		}
+	}
+
+	reserved := make(map[string]bool, len(group))
+	for name := range group {
+		reserved[name] = true
+	}
+	names := make(map[string]string, len(kinds))
+	for key, kind := range kinds {
+		name := key
+		if reserved[name] {
+			name = "metadata_" + key
+		}
+		names[key] = name
+
+		switch kind {
+		case "int":
+			group[name] = parquet.Optional(parquet.Int(64))
+		case "float":
+			group[name] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+		case "bool":
+			group[name] = parquet.Optional(parquet.Leaf(parquet.BooleanType))
+		case "strings":
+			group[name] = parquet.Optional(parquet.List(parquet.String()))
+		default:
+			group[name] = parquet.Optional(parquet.String())
+		}
+	}
+
+	// Missing and null values are left out of the row, which makes them
+	// null. Scalars go in by pointer, since parquet-go writes a zero value
+	// in an optional column as null.
+	for i, item := range items {
+		for key, value := range item.Metadata {
+			if value == nil {
+				continue
+			}
+			switch kinds[key] {
+			case "int":
+				v, ok := value.(int64)
+				if !ok {
+					v = int64(value.(int))
+				}
+				value = &v
+			case "float":
+				v, _ := toFloat(value)
+				value = &v
+			case "bool":
+				v := value.(bool)
+				value = &v
+			case "string":
+				v := value.(string)
+				value = &v
+			case "json":
+				encoded, err := json.Marshal(value)
+				if err != nil {
+					encoded = []byte(fmt.Sprint(value))
+				}
+				v := string(encoded)
+				value = &v
+			}
+			rows[i][names[key]] = value
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetColumn is one column of a file read back: its value in each row,
+// with nil for null and lists as []interface{}
+type parquetColumn struct {
+	Type string
+	Rows []interface{}
+}
+
+// readParquet opens an exported file and reads every column back by name
+func readParquet(t *testing.T, file []byte) (int, map[string]parquetColumn) {
+	t.Helper()
+	f, err := parquet.OpenFile(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatalf("opening the file: %v", err)
+	}
+
+	leaves := f.Schema().Columns()
+	columns := make(map[string]parquetColumn, len(leaves))
+	for _, path := range leaves {
+		leaf, _ := f.Schema().Lookup(path...)
+		columns[path[0]] = parquetColumn{Type: leaf.Node.Type().String()}
+	}
+
+	rows := make([]parquet.Row, f.NumRows())
+	for _, group := range f.RowGroups() {
+		reader := group.Rows()
+		for read := 0; read < int(group.NumRows()); {
+			n, err := reader.ReadRows(rows[read:])
+			read += n
+			if err != nil && read < int(group.NumRows()) {
+				t.Fatalf("reading rows: %v", err)
+			}
+		}
+		reader.Close()
+	}
+
+	for _, row := range rows {
+		values := make(map[string]interface{}, len(leaves))
+		for _, value := range row {
+			path := leaves[value.Column()]
+			leaf, _ := f.Schema().Lookup(path...)
+			name := path[0]
+			if leaf.MaxRepetitionLevel == 0 {
+				if value.DefinitionLevel() == leaf.MaxDefinitionLevel {
+					values[name] = parquetGoValue(value)
+				}
+				continue
+			}
+			// A list is null below the definition level of its list group,
+			// and empty below that of its elements
+			list, _ := values[name].([]interface{})
+			switch {
+			case value.DefinitionLevel() == leaf.MaxDefinitionLevel:
+				values[name] = append(list, parquetGoValue(value))
+			case value.DefinitionLevel() == leaf.MaxDefinitionLevel-1:
+				values[name] = []interface{}{}
+			}
+		}
+		for name, column := range columns {
+			column.Rows = append(column.Rows, values[name])
+			columns[name] = column
+		}
+	}
+	return len(rows), columns
+}
+
+// parquetGoValue converts a leaf value to the Go type the export wrote
+func parquetGoValue(value parquet.Value) interface{} {
+	switch value.Kind() {
+	case parquet.Boolean:
+		return value.Boolean()
+	case parquet.Int64:
+		return value.Int64()
+	case parquet.Float:
+		return value.Float()
+	case parquet.Double:
+		return value.Double()
+	default:
+		return value.String()
+	}
+}
+
+// roundTripParquet writes items and reads the file back
+func roundTripParquet(t *testing.T, items []VectorItem, layout string, asFloat32 bool) (int, map[string]parquetColumn) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, items, layout, asFloat32); err != nil {
+		t.Fatal(err)
+	}
+	return readParquet(t, buf.Bytes())
+}
+
+func TestParquetColumnTypes(t *testing.T) {
+	items := []VectorItem{
+		{ID: "a", Key: "ka", Vector: []float64{1, 2}, Clusters: []string{"c0"}, Metadata: map[string]interface{}{
+			"count": math.MaxInt64, "score": 1.5, "mixed": 1, "flag": true, "name": "x",
+			"tags": []string{"x"}, "nested": map[string]interface{}{"a": 1}, "id": "clash",
+		}},
+		{ID: "b", Key: "kb", Vector: []float64{}, Metadata: map[string]interface{}{
+			"count": 0, "mixed": 2.5, "flag": false, "name": "", "tags": []string{},
+		}},
+		{ID: "c", Key: "kc", Vector: []float64{3}, Clusters: []string{"c1", "c2"}, Metadata: map[string]interface{}{}},
+	}
+	// Zero values stay distinct from missing ones
+	rows, columns := roundTripParquet(t, items, layoutList, false)
+	if rows != 3 {
+		t.Fatalf("rows = %d, want 3", rows)
+	}
+
+	want := map[string]struct {
+		Type string
+		Rows []interface{}
+	}{
+		"id":          {"STRING", []interface{}{"a", "b", "c"}},
+		"key":         {"STRING", []interface{}{"ka", "kb", "kc"}},
+		"vector":      {"DOUBLE", []interface{}{[]interface{}{1.0, 2.0}, []interface{}{}, []interface{}{3.0}}},
+		"clusters":    {"STRING", []interface{}{[]interface{}{"c0"}, []interface{}{}, []interface{}{"c1", "c2"}}},
+		"count":       {"INT(64,true)", []interface{}{int64(math.MaxInt64), int64(0), nil}},
+		"score":       {"DOUBLE", []interface{}{1.5, nil, nil}},
+		"mixed":       {"DOUBLE", []interface{}{1.0, 2.5, nil}},
+		"flag":        {"BOOLEAN", []interface{}{true, false, nil}},
+		"name":        {"STRING", []interface{}{"x", "", nil}},
+		"tags":        {"STRING", []interface{}{[]interface{}{"x"}, []interface{}{}, nil}},
+		"nested":      {"STRING", []interface{}{`{"a":1}`, nil, nil}},
+		"metadata_id": {"STRING", []interface{}{"clash", nil, nil}},
+	}
+	if len(columns) != len(want) {
+		t.Errorf("got %d columns, want %d", len(columns), len(want))
+	}
+	for name, w := range want {
+		column, ok := columns[name]
+		if !ok {
+			t.Errorf("no %s column", name)
+			continue
+		}
+		if column.Type != w.Type {
+			t.Errorf("%s has type %s, want %s", name, column.Type, w.Type)
+		}
+		if !reflect.DeepEqual(column.Rows, w.Rows) {
+			t.Errorf("%s = %#v, want %#v", name, column.Rows, w.Rows)
+		}
+	}
+}
+
+func TestParquetEmptyFile(t *testing.T) {
+	rows, columns := roundTripParquet(t, nil, layoutList, false)
+	if rows != 0 {
+		t.Errorf("rows = %d, want 0", rows)
+	}
+	for _, name := range []string{"id", "key", "vector", "clusters"} {
+		if _, ok := columns[name]; !ok {
+			t.Errorf("empty file has no %s column", name)
+		}
+	}
+}
+
+func TestParquetExportRoundTrip(t *testing.T) {
+	config := testGeneratorConfig()
+	config.MaxClusters = 3
+	items, err := generateVectorData(context.Background(), rand.New(rand.NewSource(config.Seed)), 300, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, layout := range []string{layoutList, layoutWide} {
+		for _, asFloat32 := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/float32=%v", layout, asFloat32), func(t *testing.T) {
+				rows, columns := roundTripParquet(t, items, layout, asFloat32)
+				if rows != len(items) {
+					t.Fatalf("rows = %d, want %d", rows, len(items))
+				}
+
+				component := func(x float64) interface{} {
+					if asFloat32 {
+						return float32(x)
+					}
+					return x
+				}
+				for i, item := range items {
+					if got := columns["id"].Rows[i]; got != item.ID {
+						t.Fatalf("row %d id = %v, want %q", i, got, item.ID)
+					}
+					wantClusters := []interface{}{}
+					for _, c := range item.Clusters {
+						wantClusters = append(wantClusters, c)
+					}
+					if got := columns["clusters"].Rows[i]; !reflect.DeepEqual(got, wantClusters) {
+						t.Errorf("row %d clusters = %v, want %v", i, got, wantClusters)
+					}
+					if want, ok := item.Metadata["status"]; ok && columns["status"].Rows[i] != want {
+						t.Errorf("row %d status = %v, want %v", i, columns["status"].Rows[i], want)
+					}
+
+					for d, x := range item.Vector {
+						var got interface{}
+						if layout == layoutWide {
+							got = columns[fmt.Sprintf("vector_%d", d)].Rows[i]
+						} else {
+							got = columns["vector"].Rows[i].([]interface{})[d]
+						}
+						if got != component(x) {
+							t.Fatalf("row %d component %d = %v, want %v", i, d, got, component(x))
+						}
+					}
+				}
+			})
+		}
+	}
+}
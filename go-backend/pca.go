@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Jacobi stops once the off-diagonal mass falls below this tolerance
+const (
+	jacobiMaxSweeps = 100
+	jacobiTolerance = 1e-12
+)
+
+var errNoConvergence = errors.New("eigen-decomposition did not converge")
+
+var errDegenerateData = errors.New("the vectors are all identical, so they have no principal components")
+
+// Projection fallbacks, chosen with fallback= when PCA can't be fitted
+const fallbackRaw = "raw" // The first raw dimensions
+
+// PCAResponse is the response structure for PCA projections. Fallback is
+// set when PCA couldn't be fitted and the projection holds raw dimensions
+// instead, in which case ExplainedVariance is null.
+type PCAResponse struct {
+	Data              []VectorItem `json:"data"`
+	Total             int          `json:"total"`
+	ExplainedVariance []float64    `json:"explained_variance"`
+	Fallback          string       `json:"fallback,omitempty"`
+}
+
+// pcaModel holds the principal components of a dataset
+type pcaModel struct {
+	Mean        []float64
+	Components  [][]float64 // Unit eigenvectors, largest eigenvalue first
+	Eigenvalues []float64
+	// ExplainedVariance is each component's share of the total variance
+	ExplainedVariance []float64
+	TotalVariance     float64
+}
+
+// PCAVarianceResponse is the response structure for the explained-variance
+// spectrum. Items is the number of vectors the components were fitted to.
+type PCAVarianceResponse struct {
+	Components    []ComponentVariance `json:"components"`
+	TotalVariance float64             `json:"total_variance"`
+	Dimensions    int                 `json:"dimensions"`
+	Items         int                 `json:"items"`
+}
+
+// ComponentVariance describes one principal component. Component counts
+// from 1, largest eigenvalue first.
+type ComponentVariance struct {
+	Component               int     `json:"component"`
+	Eigenvalue              float64 `json:"eigenvalue"`
+	ExplainedVarianceRatio  float64 `json:"explained_variance_ratio"`
+	CumulativeVarianceRatio float64 `json:"cumulative_explained_variance_ratio"`
+}
+
+// fitPCA computes the top n principal components of vectors, which must all
+// share the same dimension. It gives up with the context's error if ctx
+// ends first.
+func fitPCA(ctx context.Context, vectors [][]float64, n int) (pcaModel, error) {
+	if identicalVectors(vectors) {
+		return pcaModel{}, errDegenerateData
+	}
+
+	dims := len(vectors[0])
+	mean := meanVector(vectors)
+	cov, err := covarianceMatrix(ctx, vectors, mean)
+	if err != nil {
+		return pcaModel{}, err
+	}
+
+	values, eigenvectors, err := jacobiEigen(ctx, cov)
+	if err != nil {
+		return pcaModel{}, err
+	}
+
+	// Order components by decreasing eigenvalue
+	order := make([]int, dims)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return values[order[a]] > values[order[b]]
+	})
+
+	totalVariance := 0.0
+	for _, v := range values {
+		totalVariance += math.Max(v, 0)
+	}
+
+	model := pcaModel{
+		Mean:              mean,
+		Components:        make([][]float64, n),
+		Eigenvalues:       make([]float64, n),
+		ExplainedVariance: make([]float64, n),
+		TotalVariance:     totalVariance,
+	}
+	for i := 0; i < n; i++ {
+		col := order[i]
+		component := make([]float64, dims)
+		for j := 0; j < dims; j++ {
+			component[j] = eigenvectors[j][col]
+		}
+		orientComponent(component)
+
+		model.Components[i] = component
+		model.Eigenvalues[i] = math.Max(values[col], 0)
+		if totalVariance > 0 {
+			model.ExplainedVariance[i] = model.Eigenvalues[i] / totalVariance
+		}
+	}
+
+	return model, nil
+}
+
+// identicalVectors reports whether every vector equals the first. Their
+// covariance is zero in theory but rounding leaves noise in the computed
+// one, so this is checked on the vectors themselves.
+func identicalVectors(vectors [][]float64) bool {
+	for _, v := range vectors[1:] {
+		for j, x := range v {
+			if x != vectors[0][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// unfittable reports whether err means PCA can't be fitted to the data, as
+// opposed to the request failing
+func unfittable(err error) bool {
+	return errors.Is(err, errDegenerateData) || errors.Is(err, errNoConvergence)
+}
+
+// writePCAError answers a request whose PCA fit failed: 422 when the data
+// can't be fitted, otherwise as for any failed work
+func writePCAError(w http.ResponseWriter, err error) {
+	if unfittable(err) {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("PCA is not possible on this data: %v", err))
+		return
+	}
+	writeWorkError(w, err)
+}
+
+// parsePCAFallback reads the fallback parameter
+func parsePCAFallback(query url.Values) (string, error) {
+	switch fallback := query.Get("fallback"); fallback {
+	case "", fallbackRaw:
+		return fallback, nil
+	default:
+		return "", fmt.Errorf("fallback must be %q", fallbackRaw)
+	}
+}
+
+// rawProjection returns the first n components of v, in place of a PCA
+// projection
+func rawProjection(v []float64, n int) []float64 {
+	return append([]float64(nil), v[:n]...)
+}
+
+// Project maps v onto the model's components
+func (m pcaModel) Project(v []float64) []float64 {
+	projection := make([]float64, len(m.Components))
+	for i, component := range m.Components {
+		sum := 0.0
+		for j, c := range component {
+			sum += (v[j] - m.Mean[j]) * c
+		}
+		projection[i] = sum
+	}
+	return projection
+}
+
+// orientComponent flips the sign of an eigenvector so its largest-magnitude
+// entry is positive. Eigenvectors are only defined up to sign, and fixing it
+// keeps projections stable between runs.
+func orientComponent(component []float64) {
+	largest := 0
+	for i, c := range component {
+		if math.Abs(c) > math.Abs(component[largest]) {
+			largest = i
+		}
+	}
+	if component[largest] < 0 {
+		for i := range component {
+			component[i] = -component[i]
+		}
+	}
+}
+
+func meanVector(vectors [][]float64) []float64 {
+	mean := make([]float64, len(vectors[0]))
+	for _, v := range vectors {
+		for j, x := range v {
+			mean[j] += x
+		}
+	}
+	for j := range mean {
+		mean[j] /= float64(len(vectors))
+	}
+	return mean
+}
+
+// covarianceMatrix returns the sample covariance matrix of vectors. Each
+// row costs dims² operations, so ctx is checked once per row.
+func covarianceMatrix(ctx context.Context, vectors [][]float64, mean []float64) ([][]float64, error) {
+	dims := len(mean)
+	cov := make([][]float64, dims)
+	for i := range cov {
+		cov[i] = make([]float64, dims)
+	}
+
+	centered := make([]float64, dims)
+	for _, v := range vectors {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for j := range v {
+			centered[j] = v[j] - mean[j]
+		}
+		for i := 0; i < dims; i++ {
+			for j := i; j < dims; j++ {
+				cov[i][j] += centered[i] * centered[j]
+			}
+		}
+	}
+
+	denom := float64(len(vectors) - 1)
+	if denom < 1 {
+		denom = 1
+	}
+	for i := 0; i < dims; i++ {
+		for j := i; j < dims; j++ {
+			cov[i][j] /= denom
+			cov[j][i] = cov[i][j]
+		}
+	}
+	return cov, nil
+}
+
+// jacobiEigen diagonalizes the symmetric matrix a with cyclic Jacobi
+// rotations. It returns the eigenvalues and a matrix whose columns are the
+// corresponding eigenvectors. The input matrix is overwritten. A sweep
+// over a large matrix is slow, so ctx is checked once per row.
+func jacobiEigen(ctx context.Context, a [][]float64) ([]float64, [][]float64, error) {
+	n := len(a)
+	v := make([][]float64, n)
+	for i := range v {
+		v[i] = make([]float64, n)
+		v[i][i] = 1
+	}
+
+	for sweep := 0; sweep < jacobiMaxSweeps; sweep++ {
+		off := 0.0
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				off += a[p][q] * a[p][q]
+			}
+		}
+		if off < jacobiTolerance {
+			values := make([]float64, n)
+			for i := range values {
+				values[i] = a[i][i]
+			}
+			return values, v, nil
+		}
+
+		for p := 0; p < n; p++ {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+			for q := p + 1; q < n; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+
+				// Rotation angle that zeroes a[p][q]
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+
+				for k := 0; k < n; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < n; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+
+	return nil, nil, errNoConvergence
+}
+
+func handlePCA(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	components := 2 // Default
+	if componentsStr := r.URL.Query().Get("components"); componentsStr != "" {
+		parsedComponents, err := strconv.Atoi(componentsStr)
+		if err != nil || parsedComponents <= 0 {
+			writeError(w, http.StatusBadRequest, "components must be a positive integer")
+			return
+		}
+		components = parsedComponents
+	}
+
+	fallback, err := parsePCAFallback(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	if dimensions := len(vectors[0]); components > dimensions {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("components must not exceed dimensions (%d)", dimensions))
+		return
+	}
+
+	response := PCAResponse{
+		Data:  data,
+		Total: len(data),
+	}
+	model, err := fitPCA(r.Context(), vectors, components)
+	switch {
+	case err == nil:
+		for i := range data {
+			data[i].Projection = model.Project(data[i].Vector)
+		}
+		response.ExplainedVariance = model.ExplainedVariance
+	case fallback != "" && unfittable(err):
+		for i := range data {
+			data[i].Projection = rawProjection(data[i].Vector, components)
+		}
+		response.Fallback = fallback
+	default:
+		writePCAError(w, err)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+
+	newJSONEncoder(w, r).Encode(response)
+}
+
+func handlePCAVariance(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	components := 0 // Default: up to 10, see below
+	if componentsStr := r.URL.Query().Get("components"); componentsStr != "" {
+		parsedComponents, err := strconv.Atoi(componentsStr)
+		if err != nil || parsedComponents <= 0 {
+			writeError(w, http.StatusBadRequest, "components must be a positive integer")
+			return
+		}
+		components = parsedComponents
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	dimensions := len(vectors[0])
+	if components == 0 {
+		components = min(10, dimensions)
+	}
+	if components > dimensions {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("components must not exceed dimensions (%d)", dimensions))
+		return
+	}
+
+	model, err := fitPCA(r.Context(), vectors, components)
+	if err != nil {
+		writePCAError(w, err)
+		return
+	}
+
+	spectrum := make([]ComponentVariance, components)
+	cumulative := 0.0
+	for i := range spectrum {
+		cumulative += model.ExplainedVariance[i]
+		spectrum[i] = ComponentVariance{
+			Component:               i + 1,
+			Eigenvalue:              model.Eigenvalues[i],
+			ExplainedVarianceRatio:  model.ExplainedVariance[i],
+			CumulativeVarianceRatio: math.Min(cumulative, 1),
+		}
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := PCAVarianceResponse{
+		Components:    spectrum,
+		TotalVariance: model.TotalVariance,
+		Dimensions:    dimensions,
+		Items:         len(data),
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
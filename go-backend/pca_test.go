@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// withLoadedDataset serves items as if loaded with -data until the test
+// ends
+func withLoadedDataset(t *testing.T, items []VectorItem) {
+	t.Helper()
+	loadedMu.Lock()
+	previous := loadedDataset
+	loadedDataset = items
+	loadedMu.Unlock()
+	t.Cleanup(func() {
+		loadedMu.Lock()
+		loadedDataset = previous
+		loadedMu.Unlock()
+	})
+}
+
+// constantItems returns n items whose vectors all equal v
+func constantItems(n int, v []float64) []VectorItem {
+	items := make([]VectorItem, n)
+	for i := range items {
+		items[i] = VectorItem{ID: strconv.Itoa(i), Vector: append([]float64(nil), v...), Metadata: map[string]interface{}{}}
+	}
+	return items
+}
+
+func TestPCAUnfittableDataIs422(t *testing.T) {
+	for name, v := range map[string][]float64{
+		"all zero":  {0, 0, 0, 0},
+		"identical": {1, -2, 3, 0.5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			withLoadedDataset(t, constantItems(20, v))
+			for target, handler := range map[string]http.HandlerFunc{
+				"/api/vectors/pca?limit=20":          handlePCA,
+				"/api/vectors/pca/variance?limit=20": handlePCAVariance,
+			} {
+				w := httptest.NewRecorder()
+				handler(w, httptest.NewRequest("GET", target, nil))
+				if w.Code != http.StatusUnprocessableEntity {
+					t.Errorf("%s: status %d, want 422: %s", target, w.Code, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestPCARawFallbackOnZeroData(t *testing.T) {
+	withLoadedDataset(t, constantItems(20, []float64{0, 0, 0, 0}))
+	w := httptest.NewRecorder()
+	handlePCA(w, httptest.NewRequest("GET", "/api/vectors/pca?limit=20&components=3&fallback=raw", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	var response PCAResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Fallback != fallbackRaw {
+		t.Errorf("fallback = %q, want %q", response.Fallback, fallbackRaw)
+	}
+	if response.ExplainedVariance != nil {
+		t.Errorf("explained_variance = %v, want null", response.ExplainedVariance)
+	}
+	if response.Total != 20 || len(response.Data) != 20 {
+		t.Fatalf("total %d with %d items, want 20", response.Total, len(response.Data))
+	}
+	for _, item := range response.Data {
+		if len(item.Projection) != 3 {
+			t.Fatalf("item %s projection %v, want the first 3 raw dimensions", item.ID, item.Projection)
+		}
+		for _, x := range item.Projection {
+			if x != 0 {
+				t.Errorf("item %s projection %v, want zeros", item.ID, item.Projection)
+			}
+		}
+	}
+}
+
+func TestCovarianceStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vectors := [][]float64{{1, 2}, {3, 4}}
+	if _, err := covarianceMatrix(ctx, vectors, meanVector(vectors)); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
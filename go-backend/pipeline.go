@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Reduction left to the pipeline's default when reduce_dims is omitted
+const defaultPipelineDims = 50
+
+// PipelineResponse is the response structure for the reduce-then-cluster
+// pipeline. Each item carries its reduced vector as projection, unless
+// reduce is none, and its cluster_id from the clustering run on those.
+type PipelineResponse struct {
+	Data       []VectorItem `json:"data"`
+	Total      int          `json:"total"`
+	Reduce     string       `json:"reduce"`
+	ReduceDims int          `json:"reduce_dims"`
+	Cluster    string       `json:"cluster"`
+	Clusters   int          `json:"clusters"`
+}
+
+// reduction is a configured reduction stage. Check, when set, reports why
+// n vectors can't be reduced; Run maps the vectors to Dims dimensions.
+type reduction struct {
+	Dims  int
+	Check func(n int) error
+	Run   func(ctx context.Context, vectors [][]float64, rng *rand.Rand) ([][]float64, error)
+}
+
+// clustering is a configured clustering stage. Check, when set, reports
+// why n vectors can't be clustered; Run labels each vector with a cluster,
+// or a negative ID for noise.
+type clustering struct {
+	Check func(n int) error
+	Run   func(ctx context.Context, vectors [][]float64, rng *rand.Rand) ([]int, error)
+}
+
+// pipelineReducers configures each reduction stage from the query string.
+// dims is reduce_dims, or 0 when it was omitted, and dimensions is the
+// length of the input vectors.
+var pipelineReducers = map[string]func(query url.Values, dims, dimensions int) (reduction, error){
+	"none": func(query url.Values, dims, dimensions int) (reduction, error) {
+		if dims != 0 && dims != dimensions {
+			return reduction{}, fmt.Errorf("reduce_dims must be omitted or %d with reduce=none", dimensions)
+		}
+		return reduction{Dims: dimensions}, nil
+	},
+	"pca": func(query url.Values, dims, dimensions int) (reduction, error) {
+		if dims == 0 {
+			dims = min(defaultPipelineDims, dimensions)
+		}
+		if dims > dimensions {
+			return reduction{}, fmt.Errorf("reduce_dims must not exceed dimensions (%d)", dimensions)
+		}
+		return reduction{
+			Dims: dims,
+			Run: func(ctx context.Context, vectors [][]float64, rng *rand.Rand) ([][]float64, error) {
+				model, err := fitPCA(ctx, vectors, dims)
+				if err != nil {
+					return nil, err
+				}
+				embedding := make([][]float64, len(vectors))
+				for i, vector := range vectors {
+					embedding[i] = model.Project(vector)
+				}
+				return embedding, nil
+			},
+		}, nil
+	},
+	"umap": func(query url.Values, dims, dimensions int) (reduction, error) {
+		if dims == 0 {
+			dims = min(defaultPipelineDims, dimensions)
+		}
+		if dims > dimensions {
+			return reduction{}, fmt.Errorf("reduce_dims must not exceed dimensions (%d)", dimensions)
+		}
+		nNeighbors, minDist, err := parseUMAPOptions(query)
+		if err != nil {
+			return reduction{}, err
+		}
+		return reduction{
+			Dims:  dims,
+			Check: func(n int) error { return checkUMAPInput(n, nNeighbors) },
+			Run: func(ctx context.Context, vectors [][]float64, rng *rand.Rand) ([][]float64, error) {
+				return runUMAP(ctx, vectors, nNeighbors, minDist, dims, rng)
+			},
+		}, nil
+	},
+	"tsne": func(query url.Values, dims, dimensions int) (reduction, error) {
+		if dims != 0 && dims != 2 {
+			return reduction{}, fmt.Errorf("t-SNE only supports reduce_dims=2")
+		}
+		perplexity, iterations, err := parseTSNEOptions(query)
+		if err != nil {
+			return reduction{}, err
+		}
+		return reduction{
+			Dims:  2,
+			Check: func(n int) error { return checkTSNEInput(n, perplexity) },
+			Run: func(ctx context.Context, vectors [][]float64, rng *rand.Rand) ([][]float64, error) {
+				return runTSNE(ctx, vectors, perplexity, iterations, rng)
+			},
+		}, nil
+	},
+}
+
+// pipelineClusterers configures each clustering stage from the query
+// string
+var pipelineClusterers = map[string]func(query url.Values) (clustering, error){
+	"kmeans": func(query url.Values) (clustering, error) {
+		k := 5 // Default
+		if kStr := query.Get("k"); kStr != "" {
+			parsedK, err := strconv.Atoi(kStr)
+			if err != nil || parsedK <= 0 || parsedK > maxKMeansK {
+				return clustering{}, fmt.Errorf("k must be an integer between 1 and %d", maxKMeansK)
+			}
+			k = parsedK
+		}
+
+		maxIterations := defaultKMeansMaxIterations
+		if maxIterStr := query.Get("max_iter"); maxIterStr != "" {
+			parsedMaxIter, err := strconv.Atoi(maxIterStr)
+			if err != nil || parsedMaxIter <= 0 {
+				return clustering{}, fmt.Errorf("max_iter must be a positive integer")
+			}
+			maxIterations = parsedMaxIter
+		}
+
+		return clustering{
+			Check: func(n int) error {
+				if k > n {
+					return fmt.Errorf("k must not exceed the number of vectors (%d)", n)
+				}
+				return nil
+			},
+			Run: func(ctx context.Context, vectors [][]float64, rng *rand.Rand) ([]int, error) {
+				result, err := kMeans(ctx, vectors, k, maxIterations, rng)
+				return result.Assignments, err
+			},
+		}, nil
+	},
+	"dbscan": func(query url.Values) (clustering, error) {
+		metric, err := parseMetric(query, metricEuclidean)
+		if err != nil {
+			return clustering{}, err
+		}
+		distance, ok := metricDistance(metric)
+		if !ok {
+			return clustering{}, fmt.Errorf("dbscan needs a distance; use metric cosine or euclidean")
+		}
+
+		minPts := 5 // Default
+		if minPtsStr := query.Get("min_pts"); minPtsStr != "" {
+			parsedMinPts, err := strconv.Atoi(minPtsStr)
+			if err != nil || parsedMinPts <= 0 {
+				return clustering{}, fmt.Errorf("min_pts must be a positive integer")
+			}
+			minPts = parsedMinPts
+		}
+
+		// The scale of the reduced space is unknown, so there is no default
+		eps, err := strconv.ParseFloat(query.Get("eps"), 64)
+		if err != nil || !(eps > 0) {
+			return clustering{}, fmt.Errorf("eps must be a positive number")
+		}
+
+		return clustering{
+			Check: func(n int) error {
+				if n > maxDBSCANItems {
+					return fmt.Errorf("DBSCAN is limited to %d vectors", maxDBSCANItems)
+				}
+				return nil
+			},
+			Run: func(ctx context.Context, vectors [][]float64, rng *rand.Rand) ([]int, error) {
+				labels, _ := dbscan(vectors, eps, minPts, distance)
+				return labels, nil
+			},
+		}, nil
+	},
+}
+
+// quotedNames lists stage names for error messages
+func quotedNames(names []string) string {
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = strconv.Quote(name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// handlePipeline reduces the dataset's vectors, then clusters the reduced
+// vectors, in one call. The reduce and cluster parameters pick the stages
+// from pipelineReducers and pipelineClusterers, and every other parameter
+// configures them as it does on the stage's own endpoint.
+func handlePipeline(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+
+	reduceName := query.Get("reduce")
+	if reduceName == "" {
+		reduceName = "pca" // Default
+	}
+	newReducer, ok := pipelineReducers[reduceName]
+	if !ok {
+		var names []string
+		for name := range pipelineReducers {
+			names = append(names, name)
+		}
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("reduce must be one of %s", quotedNames(names)))
+		return
+	}
+
+	clusterName := query.Get("cluster")
+	if clusterName == "" {
+		clusterName = "kmeans" // Default
+	}
+	newClusterer, ok := pipelineClusterers[clusterName]
+	if !ok {
+		var names []string
+		for name := range pipelineClusterers {
+			names = append(names, name)
+		}
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("cluster must be one of %s", quotedNames(names)))
+		return
+	}
+
+	dims := 0
+	if dimsStr := query.Get("reduce_dims"); dimsStr != "" {
+		parsedDims, err := strconv.Atoi(dimsStr)
+		if err != nil || parsedDims <= 0 {
+			writeError(w, http.StatusBadRequest, "reduce_dims must be a positive integer")
+			return
+		}
+		dims = parsedDims
+	}
+
+	reduce, err := newReducer(query, dims, params.vectorDimensions())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cluster, err := newClusterer(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Refuse requests a stage can't take before generating anything
+	checks := []func(int) error{reduce.Check, cluster.Check}
+	if err := checkPipelineStages(checks, datasetSize(params)); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	if err := checkPipelineStages(checks, len(data)); err != nil {
+		// The loaded dataset changed in the meantime
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	// Both stages draw from the generation seed so results are reproducible
+	rng := params.Rand()
+	if reduce.Run != nil {
+		vectors, err = reduce.Run(r.Context(), vectors, rng)
+		if err != nil {
+			writePCAError(w, err)
+			return
+		}
+		for i := range data {
+			data[i].Projection = vectors[i]
+		}
+	}
+	labels, err := cluster.Run(r.Context(), vectors, rng)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+
+	found := make(map[int]bool)
+	for i := range data {
+		clusterID := labels[i]
+		data[i].ClusterID = &clusterID
+		if clusterID >= 0 {
+			found[clusterID] = true
+		}
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := PipelineResponse{
+		Data:       data,
+		Total:      len(data),
+		Reduce:     reduceName,
+		ReduceDims: reduce.Dims,
+		Cluster:    clusterName,
+		Clusters:   len(found),
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
+
+// checkPipelineStages runs each stage's Check against n vectors, returning
+// the first failure
+func checkPipelineStages(checks []func(int) error, n int) error {
+	for _, check := range checks {
+		if check == nil {
+			continue
+		}
+		if err := check(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
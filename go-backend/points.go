@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Dimension reduction methods for /api/points
+const (
+	methodPCA  = "pca"
+	methodTSNE = "tsne"
+	methodUMAP = "umap"
+)
+
+// Point is one item projected for plotting. Z is only set for 3D
+// projections and Cluster is the item's primary cluster.
+type Point struct {
+	ID      string   `json:"id"`
+	X       float64  `json:"x"`
+	Y       float64  `json:"y"`
+	Z       *float64 `json:"z,omitempty"`
+	Cluster string   `json:"cluster,omitempty"`
+}
+
+// PointsResponse is the response structure for plot coordinates.
+// Fallback is set when PCA couldn't be fitted and the points are raw
+// dimensions instead.
+type PointsResponse struct {
+	Points   []Point `json:"points"`
+	Total    int     `json:"total"`
+	Method   string  `json:"method"`
+	Dims     int     `json:"dims"`
+	Fallback string  `json:"fallback,omitempty"`
+}
+
+// handlePoints projects the dataset with PCA, t-SNE or UMAP and returns only
+// what a scatter plot needs, without vectors or metadata
+func handlePoints(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dims := 2 // Default
+	if dimsStr := r.URL.Query().Get("dims"); dimsStr != "" {
+		parsedDims, err := strconv.Atoi(dimsStr)
+		if err != nil || (parsedDims != 2 && parsedDims != 3) {
+			writeError(w, http.StatusBadRequest, "dims must be 2 or 3")
+			return
+		}
+		dims = parsedDims
+	}
+
+	method := r.URL.Query().Get("method")
+	if method == "" {
+		method = methodPCA // Default
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	var embedding [][]float64
+	var fallback string
+	switch method {
+	case methodPCA:
+		if dimensions := len(vectors[0]); dims > dimensions {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("dims must not exceed dimensions (%d)", dimensions))
+			return
+		}
+		allowFallback, err := parsePCAFallback(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		model, err := fitPCA(r.Context(), vectors, dims)
+		project := model.Project
+		if err != nil {
+			if allowFallback == "" || !unfittable(err) {
+				writePCAError(w, err)
+				return
+			}
+			project = func(v []float64) []float64 { return rawProjection(v, dims) }
+			fallback = allowFallback
+		}
+		embedding = make([][]float64, len(vectors))
+		for i, vector := range vectors {
+			embedding[i] = project(vector)
+		}
+
+	case methodTSNE:
+		if dims != 2 {
+			writeError(w, http.StatusBadRequest, "t-SNE only supports dims=2")
+			return
+		}
+		perplexity, iterations, err := parseTSNEOptions(r.URL.Query())
+		if err == nil {
+			err = checkTSNEInput(len(vectors), perplexity)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		embedding, err = runTSNE(r.Context(), vectors, perplexity, iterations, params.Rand())
+		if err != nil {
+			writeWorkError(w, err)
+			return
+		}
+
+	case methodUMAP:
+		nNeighbors, minDist, err := parseUMAPOptions(r.URL.Query())
+		if err == nil {
+			err = checkUMAPInput(len(vectors), nNeighbors)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		embedding, err = runUMAP(r.Context(), vectors, nNeighbors, minDist, dims, params.Rand())
+		if err != nil {
+			writeWorkError(w, err)
+			return
+		}
+
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("method must be %q, %q or %q", methodPCA, methodTSNE, methodUMAP))
+		return
+	}
+
+	points := make([]Point, len(data))
+	for i, item := range data {
+		points[i] = Point{ID: item.ID, X: embedding[i][0], Y: embedding[i][1]}
+		if dims == 3 {
+			points[i].Z = &embedding[i][2]
+		}
+		if len(item.Clusters) > 0 {
+			points[i].Cluster = item.Clusters[0]
+		}
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := PointsResponse{
+		Points:   points,
+		Total:    len(points),
+		Method:   method,
+		Dims:     dims,
+		Fallback: fallback,
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Indentation for pretty=true responses
+const prettyIndent = "  "
+
+// prettyJSON reports whether the request asked for indented JSON with
+// pretty=true. Values that aren't booleans leave the output compact, as
+// invalid limits fall back to the default.
+func prettyJSON(r *http.Request) bool {
+	pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return pretty
+}
+
+// newJSONEncoder returns an encoder for the response to r, indenting its
+// output when the request asked for pretty=true
+func newJSONEncoder(w io.Writer, r *http.Request) *json.Encoder {
+	encoder := json.NewEncoder(w)
+	if prettyJSON(r) {
+		encoder.SetIndent("", prettyIndent)
+	}
+	return encoder
+}
+
+// indentJSON reindents an already encoded response body for pretty=true,
+// and returns it unchanged otherwise
+func indentJSON(r *http.Request, body []byte) []byte {
+	if !prettyJSON(r) {
+		return body
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", prettyIndent); err != nil {
+		return body
+	}
+	return indented.Bytes()
+}
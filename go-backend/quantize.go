@@ -0,0 +1,41 @@
+package main
+
+import "math"
+
+// Quantization schemes accepted by the quantize query parameter
+const quantizeInt8 = "int8"
+
+// int8Levels is the largest quantized magnitude. The scale is symmetric,
+// so -128 is never used.
+const int8Levels = 127
+
+// quantizeVectorInt8 quantizes v symmetrically: each component becomes
+// round(x / scale) with scale = max|x| / 127, so v's largest component
+// maps to ±127. A zero vector has scale 0 and quantizes to zeros.
+func quantizeVectorInt8(v []float64) ([]int8, float64) {
+	maxAbs := 0.0
+	for _, x := range v {
+		maxAbs = math.Max(maxAbs, math.Abs(x))
+	}
+
+	quantized := make([]int8, len(v))
+	if maxAbs == 0 {
+		return quantized, 0
+	}
+	scale := maxAbs / int8Levels
+	for i, x := range v {
+		q := math.Round(x / scale)
+		quantized[i] = int8(math.Max(-int8Levels, math.Min(int8Levels, q)))
+	}
+	return quantized, scale
+}
+
+// dequantizeVectorInt8 reverses quantizeVectorInt8. Each component is
+// within scale/2 of the original.
+func dequantizeVectorInt8(q []int8, scale float64) []float64 {
+	v := make([]float64, len(q))
+	for i, x := range q {
+		v[i] = float64(x) * scale
+	}
+	return v
+}
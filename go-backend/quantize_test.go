@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// checkInt8RoundTrip quantizes v and checks every dequantized component is
+// within scale/2 of the original and that the largest maps to ±127
+func checkInt8RoundTrip(t *testing.T, v []float64) {
+	t.Helper()
+	quantized, scale := quantizeVectorInt8(v)
+	if len(quantized) != len(v) {
+		t.Fatalf("quantized %d components, want %d", len(quantized), len(v))
+	}
+
+	maxAbs, extreme := 0.0, int8(0)
+	for i, x := range v {
+		if math.Abs(x) > maxAbs {
+			maxAbs, extreme = math.Abs(x), quantized[i]
+		}
+	}
+	if want := maxAbs / int8Levels; scale != want {
+		t.Errorf("scale = %v, want %v", scale, want)
+	}
+	if maxAbs > 0 && extreme != int8Levels && extreme != -int8Levels {
+		t.Errorf("largest component quantized to %d, want ±%d", extreme, int8Levels)
+	}
+
+	// A little slack covers the rounding of x / scale itself
+	bound := scale / 2 * (1 + 1e-12)
+	for i, x := range dequantizeVectorInt8(quantized, scale) {
+		if err := math.Abs(x - v[i]); err > bound {
+			t.Errorf("component %d: %v round-trips to %v, error %g over scale/2 = %g", i, v[i], x, err, scale/2)
+		}
+		if quantized[i] == -128 {
+			t.Errorf("component %d quantized to -128", i)
+		}
+	}
+}
+
+func TestInt8RoundTripErrorBound(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, magnitude := range []float64{1e-300, 1e-6, 1, 1e6, 1e300} {
+		for trial := 0; trial < 200; trial++ {
+			v := make([]float64, 1+rng.Intn(64))
+			for i := range v {
+				v[i] = rng.NormFloat64() * magnitude
+			}
+			checkInt8RoundTrip(t, v)
+		}
+	}
+}
+
+func TestInt8RoundTripEdgeCases(t *testing.T) {
+	for name, v := range map[string][]float64{
+		"single negative": {-3},
+		"halfway points":  {127, 0.5, -0.5, 1.5, -126.5},
+		"one nonzero":     {0, 0, 2, 0},
+		"sparse":          {0, 1e-9, 0, -1},
+		"empty":           {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			checkInt8RoundTrip(t, v)
+		})
+	}
+}
+
+func TestInt8ZeroVector(t *testing.T) {
+	quantized, scale := quantizeVectorInt8([]float64{0, 0, 0})
+	if scale != 0 {
+		t.Errorf("scale = %v, want 0", scale)
+	}
+	for i, x := range dequantizeVectorInt8(quantized, scale) {
+		if quantized[i] != 0 || x != 0 {
+			t.Errorf("component %d quantized to %d and back to %v, want 0", i, quantized[i], x)
+		}
+	}
+}
+
+func TestQuantizedResponseRoundTrip(t *testing.T) {
+	response := fetchVectors(t, "seed=7&dimensions=16&limit=100&quantize=int8&normalize=true")
+	for _, item := range response.Data {
+		if item.QuantizationScale == nil {
+			t.Fatalf("item %s has no quantization scale", item.ID)
+		}
+		scale := *item.QuantizationScale
+		for i, x := range dequantizeVectorInt8(item.QuantizedVector, scale) {
+			if err := math.Abs(x - item.Vector[i]); err > scale/2*(1+1e-12) {
+				t.Errorf("item %s component %d: error %g over scale/2 = %g", item.ID, i, err, scale/2)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// maxResponseBytes caps the estimated size of a buffered vector response,
+// set from a flag in main; 0 disables the guard
+var maxResponseBytes int64 = 1 << 30
+
+// Estimated serialized size of one vector component, and of everything
+// else about an item: its ID, key, clusters and metadata
+const (
+	estimatedComponentBytes = 8
+	estimatedItemBytes      = 512
+)
+
+// estimateResponseBytes estimates the size of a response holding items
+// items whose vectors are cut as output says
+func estimateResponseBytes(items int, params vectorParams, output outputOptions) int64 {
+	dims := params.vectorDimensions()
+	if output.VectorDims > 0 {
+		dims = min(dims, output.VectorDims)
+	}
+	if output.OmitVectors {
+		dims = 0
+	}
+	return int64(items) * (int64(dims)*estimatedComponentBytes + estimatedItemBytes)
+}
+
+// checkResponseSize returns an error explaining how to shrink the response
+// when its estimated size exceeds maxResponseBytes
+func checkResponseSize(items int, params vectorParams, output outputOptions) error {
+	if maxResponseBytes <= 0 {
+		return nil
+	}
+	if estimate := estimateResponseBytes(items, params, output); estimate > maxResponseBytes {
+		return fmt.Errorf("the response would be about %s, over the server's limit of %s; reduce limit or dimensions, or page through the data with offset", formatByteSize(estimate), formatByteSize(maxResponseBytes))
+	}
+	return nil
+}
+
+// writeResponseSizeError answers 413 when checkResponseSize fails and
+// reports whether it did
+func writeResponseSizeError(w http.ResponseWriter, items int, params vectorParams, output outputOptions) bool {
+	if err := checkResponseSize(items, params, output); err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return true
+	}
+	return false
+}
+
+// formatByteSize renders n bytes for people, such as 1.5 GiB
+func formatByteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	value, suffix := float64(n)/unit, "KiB"
+	for _, next := range []string{"MiB", "GiB", "TiB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
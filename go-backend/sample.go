@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// sampleSeedSalt separates the sampling source from the generation source
+// of the same seed
+const sampleSeedSalt = 0x5DEECE66D
+
+// parseSample reads the sample query parameter. Zero means no sampling.
+func parseSample(query url.Values) (int, error) {
+	sampleStr := query.Get("sample")
+	if sampleStr == "" {
+		return 0, nil
+	}
+	sample, err := strconv.Atoi(sampleStr)
+	if err != nil || sample <= 0 || sample > maxLimit {
+		return 0, fmt.Errorf("sample must be an integer between 1 and %d", maxLimit)
+	}
+	return sample, nil
+}
+
+// sampleRand returns the source for a request's sampling decisions. It is
+// separate from Rand so sampled items are the same items the seed
+// generates without sampling.
+func (p vectorParams) sampleRand() *rand.Rand {
+	return rand.New(rand.NewSource(p.Seed ^ sampleSeedSalt))
+}
+
+// reservoirSample draws a uniform random sample of up to n items from the
+// items yielded by next that pass filters, using Algorithm R so only n
+// items are held at a time. It returns the sample in dataset order and the
+// number of matching items seen.
+func reservoirSample(next func() (VectorItem, bool), filters []itemFilter, n int, rng *rand.Rand) ([]VectorItem, int) {
+	type entry struct {
+		index int
+		item  VectorItem
+	}
+	reservoir := make([]entry, 0, n)
+
+	seen := 0
+	for {
+		item, ok := next()
+		if !ok {
+			break
+		}
+		if !matchesFilters(item, filters) {
+			continue
+		}
+		if seen < n {
+			reservoir = append(reservoir, entry{index: seen, item: item})
+		} else if j := rng.Intn(seen + 1); j < n {
+			reservoir[j] = entry{index: seen, item: item}
+		}
+		seen++
+	}
+
+	sort.Slice(reservoir, func(a, b int) bool {
+		return reservoir[a].index < reservoir[b].index
+	})
+	sample := make([]VectorItem, len(reservoir))
+	for i, e := range reservoir {
+		sample[i] = e.item
+	}
+	return sample, seen
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Scaling methods accepted by the scale query parameter
+const scaleMinMax = "minmax"
+
+// ScalingParams describes how the returned vectors were rescaled. With
+// minmax, component j was mapped from [Min[j], Max[j]] to [0, 1], so the
+// original is Min[j] + x*(Max[j]-Min[j]); components with zero range were
+// set to 0.5.
+type ScalingParams struct {
+	Method string    `json:"method"`
+	Min    []float64 `json:"min"`
+	Max    []float64 `json:"max"`
+}
+
+// minMaxScaler rescales each dimension to [0, 1] using its range over a
+// dataset
+type minMaxScaler struct {
+	Min []float64
+	Max []float64
+}
+
+// parseScale reads the scale query parameter; empty means no scaling
+func parseScale(query url.Values) (string, error) {
+	scale := query.Get("scale")
+	if scale != "" && scale != scaleMinMax {
+		return "", fmt.Errorf("scale must be %q", scaleMinMax)
+	}
+	return scale, nil
+}
+
+// fitMinMax makes the first pass over the items yielded by next, recording
+// each dimension's range. It returns nil for an empty dataset.
+func fitMinMax(next func() (VectorItem, bool)) *minMaxScaler {
+	var s *minMaxScaler
+	for {
+		item, ok := next()
+		if !ok {
+			return s
+		}
+		if s == nil {
+			s = &minMaxScaler{
+				Min: append([]float64(nil), item.Vector...),
+				Max: append([]float64(nil), item.Vector...),
+			}
+			continue
+		}
+		for j, x := range item.Vector[:min(len(item.Vector), len(s.Min))] {
+			s.Min[j] = min(s.Min[j], x)
+			s.Max[j] = max(s.Max[j], x)
+		}
+	}
+}
+
+// Scale returns a copy of v with every component mapped to [0, 1]
+func (s *minMaxScaler) Scale(v []float64) []float64 {
+	scaled := make([]float64, len(v))
+	for j, x := range v {
+		if j >= len(s.Min) || s.Max[j] == s.Min[j] {
+			scaled[j] = 0.5
+			continue
+		}
+		scaled[j] = (x - s.Min[j]) / (s.Max[j] - s.Min[j])
+	}
+	return scaled
+}
+
+// Params returns the scaler's description for the response
+func (s *minMaxScaler) Params() *ScalingParams {
+	return &ScalingParams{Method: scaleMinMax, Min: s.Min, Max: s.Max}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// schemaParameter describes one query parameter of /api/vectors
+type schemaParameter struct {
+	Name        string
+	Type        string // OpenAPI primitive type
+	Description string
+	Default     interface{}
+	Enum        []string
+	Repeated    bool
+}
+
+// vectorQueryParameters lists the query parameters accepted by
+// /api/vectors, in the order the handler reads them
+func vectorQueryParameters() []schemaParameter {
+	params := []schemaParameter{
+		{Name: "limit", Type: "integer", Description: "Number of items per page", Default: 500},
+		{Name: "offset", Type: "integer", Description: "Index of the first item of the page", Default: 0},
+		{Name: "size", Type: "integer", Description: fmt.Sprintf("Number of items in the logical dataset that offset and limit page through; defaults to %d, or limit when that is more. Analysis endpoints default it to offset + limit", defaultDatasetSize)},
+		{Name: "dimensions", Type: "integer", Description: "Vector dimensions", Default: 100},
+		{Name: "clusters", Type: "integer", Description: "Number of clusters", Default: 10},
+		{Name: "min_clusters", Type: "integer", Description: "Fewest clusters an item belongs to", Default: 1},
+		{Name: "max_clusters", Type: "integer", Description: "Most clusters an item belongs to; defaults to 3, or clusters if fewer"},
+		{Name: "cluster_weights", Type: "string", Description: "Comma-separated relative weight of each cluster as an item's primary cluster, for imbalanced clusters; omit for equal sizes"},
+		{Name: "spread", Type: "number", Description: "Scatter of points around their cluster center", Default: 0.25},
+		{Name: "separation", Type: "number", Description: "Range of cluster centers per dimension; clusters overlap when separation is close to spread or below it", Default: 1},
+		{Name: "coord_min", Type: "number", Description: "Lowest coordinate of the generated space; centers, points and outliers are stretched and shifted to fit coord_min to coord_max instead of ±(separation + spread)"},
+		{Name: "coord_max", Type: "number", Description: "Highest coordinate of the generated space; see coord_min"},
+		{Name: "distribution", Type: "string", Description: "Point distribution around cluster centers", Default: distributionUniform, Enum: []string{distributionUniform, distributionGaussian}},
+		{Name: "id_format", Type: "string", Description: "Format of generated item IDs", Default: idFormatSequential, Enum: []string{idFormatSequential, idFormatUUID}},
+		{Name: "key_format", Type: "string", Description: "Format of generated item keys; hash keys depend only on the seed and item index", Default: keyFormatRandom, Enum: []string{keyFormatRandom, keyFormatHash}},
+		{Name: "correlated", Type: "boolean", Description: "Bias enum metadata by primary cluster as configured in the metadata schema", Default: false},
+		{Name: "outlier_fraction", Type: "number", Description: "Fraction of items drawn uniformly over the whole space instead of near a cluster center", Default: 0},
+		{Name: "tag_outliers", Type: "boolean", Description: "Add an outlier field to each item's metadata", Default: false},
+		{Name: "sparse", Type: "number", Description: "Fraction of each vector's components set to zero", Default: 0},
+		{Name: "metadata_fields", Type: "string", Description: "Comma-separated metadata fields to generate; empty for none and omitted for all. Other fields are skipped without changing the rest of the data"},
+		{Name: "intrinsic_dim", Type: "integer", Description: "Confine the data to a random subspace of this many dimensions, plus noise, so PCA recovers that many meaningful components"},
+		{Name: "intrinsic_noise", Type: "number", Description: "Standard deviation of the noise added in every dimension when intrinsic_dim is set", Default: defaultIntrinsicNoise},
+		{Name: "vector", Type: "string", Description: "Named vector of a loaded dataset to use in place of the primary one"},
+		{Name: "normalize", Type: "boolean", Description: "Scale every vector to unit length", Default: false},
+		{Name: "base_time", Type: "string", Description: "RFC 3339 time the created metadata counts back from, making seeded datasets fully reproducible; defaults to now, or the start of the UTC day for seeded requests"},
+		{Name: "seed", Type: "integer", Description: "Seed for reproducible data (defaults to the server's -default-seed, if set)"},
+		{Name: "pretty", Type: "boolean", Description: "Indent JSON responses for reading; streamed formats are unaffected", Default: false},
+		{Name: "sample", Type: "integer", Description: "Return a uniform random sample of this many items from the whole dataset"},
+		{Name: "filter", Type: "string", Description: "Metadata filter of the form key:value", Repeated: true},
+	}
+	for _, key := range numericMetadataFields {
+		params = append(params,
+			schemaParameter{Name: key + "_min", Type: "number", Description: "Smallest " + key + " to keep"},
+			schemaParameter{Name: key + "_max", Type: "number", Description: "Largest " + key + " to keep"},
+		)
+	}
+	for _, key := range dateMetadataFields {
+		params = append(params,
+			schemaParameter{Name: key + "_after", Type: "string", Description: "Keep items with " + key + " at or after this RFC 3339 time"},
+			schemaParameter{Name: key + "_before", Type: "string", Description: "Keep items with " + key + " before this RFC 3339 time"},
+		)
+	}
+	params = append(params,
+		schemaParameter{Name: "q", Type: "string", Description: "Keep items where a searched field contains this text, ignoring case"},
+		schemaParameter{Name: "search_fields", Type: "string", Description: "Comma-separated metadata keys searched by q", Default: "name"},
+	)
+	return append(params,
+		schemaParameter{Name: "sort", Type: "string", Description: "Sort by id, key or a metadata field before paging"},
+		schemaParameter{Name: "order", Type: "string", Description: "Sort direction", Default: "asc", Enum: []string{"asc", "desc"}},
+		schemaParameter{Name: "shuffle", Type: "boolean", Description: "Return items in an order shuffled by the seed; IDs and vectors are unchanged, and sort then orders the shuffled items", Default: false},
+		schemaParameter{Name: "fields", Type: "string", Description: "Comma-separated metadata keys to keep"},
+		schemaParameter{Name: "include_vectors", Type: "boolean", Description: "Include vectors in the response", Default: true},
+		schemaParameter{Name: "vector_dims", Type: "integer", Description: "Return only the first vector_dims components of each vector; generation still uses all of them"},
+		schemaParameter{Name: "precision", Type: "integer", Description: "Round vector components and float metadata to this many decimals; omit for full precision"},
+		schemaParameter{Name: "dtype", Type: "string", Description: "Vector precision; float32 keeps about 7 significant digits, plenty for plotting, and shortens each component, but distances recomputed from it differ slightly from the server's", Default: dtypeFloat64, Enum: []string{dtypeFloat64, dtypeFloat32}},
+		schemaParameter{Name: "scale", Type: "string", Description: "Rescale each dimension to [0, 1] over the whole dataset and return the ranges as scaling; not available for streamed formats", Enum: []string{scaleMinMax}},
+		schemaParameter{Name: "quantize", Type: "string", Description: "Add each vector quantized to int8 with a per-vector scale as quantized_vector and quantization_scale; kept when include_vectors is false", Enum: []string{quantizeInt8}},
+		schemaParameter{Name: "format", Type: "string", Description: "Response format; sparse is JSON with each vector as a sparse_vector of indices and values", Default: "json", Enum: []string{"json", "ndjson", "csv", "sparse"}},
+		schemaParameter{Name: "annotate_nearest", Type: "boolean", Description: "Name each item's nearest generated cluster center", Default: false},
+		schemaParameter{Name: "metric", Type: "string", Description: "Metric used by annotate_nearest", Default: metricEuclidean, Enum: []string{metricCosine, metricEuclidean, metricDot}},
+	)
+}
+
+// openAPIDocument builds the OpenAPI description of /api/vectors. The
+// response schemas are derived from the Go types by reflection so they
+// can't drift from what the handlers encode.
+func openAPIDocument() map[string]interface{} {
+	schemas := make(map[string]interface{})
+	dataResponse := reflectSchema(reflect.TypeOf(VectorDataResponse{}), schemas)
+	item := reflectSchema(reflect.TypeOf(VectorItem{}), schemas)
+	errorResponse := reflectSchema(reflect.TypeOf(ErrorResponse{}), schemas)
+
+	var parameters []interface{}
+	for _, p := range vectorQueryParameters() {
+		schema := map[string]interface{}{"type": p.Type}
+		if p.Default != nil {
+			schema["default"] = p.Default
+		}
+		if p.Enum != nil {
+			schema["enum"] = p.Enum
+		}
+		if p.Repeated {
+			schema = map[string]interface{}{"type": "array", "items": schema}
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":        p.Name,
+			"in":          "query",
+			"description": p.Description,
+			"schema":      schema,
+		})
+	}
+
+	errorContent := map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": errorResponse},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "AVS Visualizer API",
+			"version": "1.0.0",
+		},
+		"paths": map[string]interface{}{
+			"/api/vectors": map[string]interface{}{
+				"get": map[string]interface{}{
+					"summary":    "List vectors",
+					"parameters": parameters,
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "A page of vectors",
+							"content": map[string]interface{}{
+								"application/json":     map[string]interface{}{"schema": dataResponse},
+								"application/x-ndjson": map[string]interface{}{"schema": item},
+								"text/csv":             map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+							},
+						},
+						"400": map[string]interface{}{
+							"description": "Invalid parameters",
+							"content":     errorContent,
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+// reflectSchema returns the OpenAPI schema for t. Named structs are added
+// to schemas and referenced, so each appears once.
+func reflectSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := reflectSchema(t.Elem(), schemas)
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": reflectSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": reflectSchema(t.Elem(), schemas)}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, seen := schemas[t.Name()]; seen {
+			return ref
+		}
+		schemas[t.Name()] = nil // Reserve the name so recursive types terminate
+
+		properties := make(map[string]interface{})
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = reflectSchema(field.Type, schemas)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if required != nil {
+			schema["required"] = required
+		}
+		schemas[t.Name()] = schema
+		return ref
+	default:
+		// interface{} values may hold anything
+		return map[string]interface{}{}
+	}
+}
+
+func handleSchema(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(openAPIDocument())
+}
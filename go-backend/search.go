@@ -0,0 +1,213 @@
+package main
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+)
+
+// Search bodies hold a single query vector
+const maxSearchBodyBytes = 1 << 20
+
+// SearchRequest is the request body for searching by example. K defaults
+// to 10 and Metric to cosine.
+type SearchRequest struct {
+	Vector []float64 `json:"vector"`
+	K      int       `json:"k"`
+	Metric string    `json:"metric"`
+}
+
+// SearchHit is one search result: a neighbor with the item's metadata and
+// clusters
+type SearchHit struct {
+	Neighbor
+	Metadata map[string]interface{} `json:"metadata"`
+	Clusters []string               `json:"clusters"`
+}
+
+// SearchResponse is the response structure for searching by example. Hits
+// are closest first, ties in dataset order; Searched is the number of items
+// that passed the filters and were scored.
+type SearchResponse struct {
+	Hits     []SearchHit `json:"hits"`
+	Metric   string      `json:"metric"`
+	K        int         `json:"k"`
+	Searched int         `json:"searched"`
+	Total    int         `json:"total"`
+}
+
+// searchCandidate is a scored item and its position in the dataset
+type searchCandidate struct {
+	item  VectorItem
+	score float64
+	index int
+}
+
+// searchHeap holds the best candidates seen so far with the worst on top,
+// so it can be evicted when a closer item turns up
+type searchHeap struct {
+	candidates []searchCandidate
+	metric     similarityMetric
+}
+
+// worse reports whether a ranks below b: further away, or as close but
+// later in the dataset
+func (h *searchHeap) worse(a, b searchCandidate) bool {
+	if a.score != b.score {
+		return h.metric.Closer(b.score, a.score)
+	}
+	return a.index > b.index
+}
+
+func (h *searchHeap) Len() int { return len(h.candidates) }
+
+func (h *searchHeap) Less(i, j int) bool { return h.worse(h.candidates[i], h.candidates[j]) }
+
+func (h *searchHeap) Swap(i, j int) {
+	h.candidates[i], h.candidates[j] = h.candidates[j], h.candidates[i]
+}
+
+func (h *searchHeap) Push(x interface{}) { h.candidates = append(h.candidates, x.(searchCandidate)) }
+
+func (h *searchHeap) Pop() interface{} {
+	last := h.candidates[len(h.candidates)-1]
+	h.candidates = h.candidates[:len(h.candidates)-1]
+	return last
+}
+
+// Offer keeps candidate if it is among the best k seen so far
+func (h *searchHeap) Offer(candidate searchCandidate, k int) {
+	if h.Len() < k {
+		heap.Push(h, candidate)
+	} else if h.worse(h.candidates[0], candidate) {
+		h.candidates[0] = candidate
+		heap.Fix(h, 0)
+	}
+}
+
+// Hits returns the kept candidates as search hits, closest first. The heap
+// is left empty.
+func (h *searchHeap) Hits() []SearchHit {
+	sort.Slice(h.candidates, func(a, b int) bool {
+		return h.worse(h.candidates[b], h.candidates[a])
+	})
+	hits := make([]SearchHit, len(h.candidates))
+	for i, candidate := range h.candidates {
+		score := candidate.score
+		hits[i] = SearchHit{
+			Neighbor: Neighbor{ID: candidate.item.ID, Key: candidate.item.Key},
+			Metadata: candidate.item.Metadata,
+			Clusters: candidate.item.Clusters,
+		}
+		if h.metric.IsDistance {
+			hits[i].Distance = &score
+		} else {
+			hits[i].Similarity = &score
+		}
+	}
+	h.candidates = nil
+	return hits
+}
+
+// checkSearchOptions validates a search's k and metric name
+func checkSearchOptions(k int, metricName string) (similarityMetric, error) {
+	metric, err := metricByName(metricName)
+	if err != nil {
+		return metric, err
+	}
+	if k <= 0 || k > maxLimit {
+		return metric, fmt.Errorf("k must be an integer between 1 and %d", maxLimit)
+	}
+	return metric, nil
+}
+
+// checkQueryVector returns an error unless vector is a finite vector of
+// the given dimensions. name identifies the vector in the message.
+func checkQueryVector(name string, vector []float64, dimensions int) error {
+	if len(vector) != dimensions {
+		return fmt.Errorf("%s has %d dimensions, but the dataset's vectors have %d", name, len(vector), dimensions)
+	}
+	for _, x := range vector {
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			return fmt.Errorf("%s components must be finite numbers", name)
+		}
+	}
+	return nil
+}
+
+// handleSearch returns the items closest to a posted query vector. The
+// dataset is scanned once, holding only the best k items at a time.
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	if !beginPOST(w, r) {
+		return
+	}
+
+	// Generation parameters come from the query string, as for GET requests
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters, err := parseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	request := SearchRequest{
+		K:      10,           // Default
+		Metric: metricCosine, // Default
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSearchBodyBytes))
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	metric, err := checkSearchOptions(request.K, request.Metric)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkQueryVector("vector", request.Vector, params.vectorDimensions()); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Generate data, keeping the best k matches
+	best := &searchHeap{metric: metric}
+	next := datasetIterator(params)
+	searched := 0
+	for index := 0; ; index++ {
+		if err := r.Context().Err(); err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		item, ok := next()
+		if !ok {
+			break
+		}
+		if !matchesFilters(item, filters) {
+			continue
+		}
+		searched++
+		best.Offer(searchCandidate{item: item, score: metric.Score(request.Vector, item.Vector), index: index}, request.K)
+	}
+	hits := best.Hits()
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := SearchResponse{
+		Hits:     hits,
+		Metric:   metric.Name,
+		K:        request.K,
+		Searched: searched,
+		Total:    len(hits),
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
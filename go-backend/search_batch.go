@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Batch search bodies hold many query vectors
+const maxBatchSearchBodyBytes = 32 << 20
+
+// Most query vectors one batch search may hold
+const maxBatchSearchQueries = 1000
+
+// BatchSearchRequest is the request body for searching by several examples
+// at once. K and Metric apply to every query and default as for a single
+// search.
+type BatchSearchRequest struct {
+	Vectors [][]float64 `json:"vectors"`
+	K       int         `json:"k"`
+	Metric  string      `json:"metric"`
+}
+
+// BatchSearchResult holds the hits for one query vector
+type BatchSearchResult struct {
+	Hits  []SearchHit `json:"hits"`
+	Total int         `json:"total"`
+}
+
+// BatchSearchResponse is the response structure for batch searches.
+// Results follows the order of the query vectors; Searched is the number
+// of items that passed the filters and were scored against each query.
+type BatchSearchResponse struct {
+	Results  []BatchSearchResult `json:"results"`
+	Metric   string              `json:"metric"`
+	K        int                 `json:"k"`
+	Searched int                 `json:"searched"`
+	Total    int                 `json:"total"`
+}
+
+// handleBatchSearch returns the items closest to each of several posted
+// query vectors. The dataset is generated once and the queries are split
+// between runtime.NumCPU() workers.
+func handleBatchSearch(w http.ResponseWriter, r *http.Request) {
+	if !beginPOST(w, r) {
+		return
+	}
+
+	// Generation parameters come from the query string, as for GET requests
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters, err := parseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	request := BatchSearchRequest{
+		K:      10,           // Default
+		Metric: metricCosine, // Default
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchSearchBodyBytes))
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	metric, err := checkSearchOptions(request.K, request.Metric)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(request.Vectors) == 0 || len(request.Vectors) > maxBatchSearchQueries {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("vectors must hold between 1 and %d query vectors", maxBatchSearchQueries))
+		return
+	}
+	dimensions := params.vectorDimensions()
+	for i, vector := range request.Vectors {
+		if err := checkQueryVector(fmt.Sprintf("vectors[%d]", i), vector, dimensions); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	candidates := make([]searchCandidate, 0, len(data))
+	for index, item := range data {
+		if matchesFilters(item, filters) {
+			candidates = append(candidates, searchCandidate{item: item, index: index})
+		}
+	}
+
+	// Score the queries in parallel, each worker taking one at a time
+	results := make([]BatchSearchResult, len(request.Vectors))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for worker := 0; worker < min(runtime.NumCPU(), len(request.Vectors)); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			best := &searchHeap{metric: metric}
+			for {
+				query := int(next.Add(1) - 1)
+				if query >= len(request.Vectors) || r.Context().Err() != nil {
+					return
+				}
+				for _, candidate := range candidates {
+					candidate.score = metric.Score(request.Vectors[query], candidate.item.Vector)
+					best.Offer(candidate, request.K)
+				}
+				hits := best.Hits()
+				results[query] = BatchSearchResult{Hits: hits, Total: len(hits)}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := r.Context().Err(); err != nil {
+		writeWorkError(w, err)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := BatchSearchResponse{
+		Results:  results,
+		Metric:   metric.Name,
+		K:        request.K,
+		Searched: len(candidates),
+		Total:    len(results),
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
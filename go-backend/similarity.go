@@ -0,0 +1,492 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Neighbor is a single result of a nearest-neighbor query. Similarity is
+// set for the similarity metrics and Distance for euclidean.
+type Neighbor struct {
+	ID         string   `json:"id"`
+	Key        string   `json:"key"`
+	Similarity *float64 `json:"similarity,omitempty"`
+	Distance   *float64 `json:"distance,omitempty"`
+}
+
+// NeighborResponse is the response structure for neighbor queries. Vector
+// names the embedding compared, and is omitted for the primary vector.
+// Index is "ann" when the neighbors came from the approximate index, and
+// omitted for exact results. Threshold, K and Matched are set for
+// threshold queries: the neighbors are the items at least as close as
+// Threshold, capped at K when k was given and uncapped when K is omitted.
+// Matched counts every item within the threshold before the cap; it is
+// omitted for the ann index, which only looks at K candidates.
+type NeighborResponse struct {
+	ID        string     `json:"id"`
+	Vector    string     `json:"vector,omitempty"`
+	Metric    string     `json:"metric"`
+	Index     string     `json:"index,omitempty"`
+	Threshold *float64   `json:"threshold,omitempty"`
+	K         *int       `json:"k,omitempty"`
+	Matched   *int       `json:"matched,omitempty"`
+	Neighbors []Neighbor `json:"neighbors"`
+	Total     int        `json:"total"`
+}
+
+// The similarity matrix is quadratic in the number of vectors
+const maxSimilarityMatrixItems = 500
+
+// SimilarityMatrixResponse is the response structure for pairwise
+// similarities. Matrix[i][j] compares IDs[i] with IDs[j]; for euclidean it
+// holds distances.
+type SimilarityMatrixResponse struct {
+	IDs    []string    `json:"ids"`
+	Metric string      `json:"metric"`
+	Matrix [][]float64 `json:"matrix"`
+	Total  int         `json:"total"`
+}
+
+// Metrics accepted by the metric query parameter
+const (
+	metricCosine    = "cosine"
+	metricEuclidean = "euclidean"
+	metricDot       = "dot"
+)
+
+// similarityMetric compares two vectors. For similarities larger scores
+// are closer; for distances smaller scores are.
+type similarityMetric struct {
+	Name       string
+	Score      func(a, b []float64) float64
+	IsDistance bool
+}
+
+// Closer reports whether score a ranks closer than score b
+func (m similarityMetric) Closer(a, b float64) bool {
+	if m.IsDistance {
+		return a < b
+	}
+	return a > b
+}
+
+// parseMetric reads the metric query parameter, falling back to
+// defaultMetric when it is absent
+func parseMetric(query url.Values, defaultMetric string) (similarityMetric, error) {
+	name := query.Get("metric")
+	if name == "" {
+		name = defaultMetric
+	}
+	return metricByName(name)
+}
+
+// metricByName returns the metric called name
+func metricByName(name string) (similarityMetric, error) {
+	switch name {
+	case metricCosine:
+		return similarityMetric{Name: metricCosine, Score: cosineSimilarity}, nil
+	case metricEuclidean:
+		return similarityMetric{Name: metricEuclidean, Score: euclideanDistance, IsDistance: true}, nil
+	case metricDot:
+		return similarityMetric{Name: metricDot, Score: dotProduct}, nil
+	default:
+		return similarityMetric{}, fmt.Errorf("metric must be %q, %q or %q", metricCosine, metricEuclidean, metricDot)
+	}
+}
+
+// metricDistance returns a distance for the metric: cosine distance for
+// cosine and L2 for euclidean. Dot products aren't distances, so the
+// second result is false for dot.
+func metricDistance(metric similarityMetric) (func(a, b []float64) float64, bool) {
+	switch metric.Name {
+	case metricCosine:
+		return func(a, b []float64) float64 {
+			return 1 - cosineSimilarity(a, b)
+		}, true
+	case metricEuclidean:
+		return metric.Score, true
+	default:
+		return nil, false
+	}
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b. A zero
+// vector has no direction, so its similarity to anything is 0.
+func cosineSimilarity(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// normalizeVector returns a copy of v scaled to unit L2 length. A zero
+// vector has no direction and is returned unchanged.
+func normalizeVector(v []float64) []float64 {
+	norm := math.Sqrt(dotProduct(v, v))
+	if norm == 0 {
+		return v
+	}
+	normalized := make([]float64, len(v))
+	for i, x := range v {
+		normalized[i] = x / norm
+	}
+	return normalized
+}
+
+// dotProduct returns the inner product of a and b
+func dotProduct(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// euclideanDistance returns the L2 distance between a and b
+func euclideanDistance(a, b []float64) float64 {
+	return math.Sqrt(squaredEuclidean(a, b))
+}
+
+// squaredEuclidean returns the squared L2 distance between a and b
+func squaredEuclidean(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+// findNeighbors returns the k items closest to data[target] under metric,
+// excluding the target itself. Ties keep dataset order. norms, when not
+// nil, holds each vector's norm for cosine. Sparse data is scored in
+// sparse form.
+func findNeighbors(data []VectorItem, norms []float64, target, k int, metric similarityMetric) []Neighbor {
+	score := func(i int) float64 {
+		return metric.Score(data[target].Vector, data[i].Vector)
+	}
+	if metric.Name == metricCosine && norms != nil {
+		score = func(i int) float64 {
+			return cosineWithNorms(data[target].Vector, data[i].Vector, norms[target], norms[i])
+		}
+	}
+	if forms := sparseForms(data); forms != nil {
+		sparseMetric := sparseScore(metric.Name)
+		score = func(i int) float64 {
+			return sparseMetric(forms[target], forms[i])
+		}
+	}
+
+	indices := make([]int, 0, len(data)-1)
+	scores := make([]float64, len(data))
+	for i := range data {
+		if i == target {
+			continue
+		}
+		indices = append(indices, i)
+		scores[i] = score(i)
+	}
+
+	sort.SliceStable(indices, func(a, b int) bool {
+		return metric.Closer(scores[indices[a]], scores[indices[b]])
+	})
+
+	if k < len(indices) {
+		indices = indices[:k]
+	}
+	neighbors := make([]Neighbor, len(indices))
+	for n, i := range indices {
+		score := scores[i]
+		neighbors[n] = Neighbor{ID: data[i].ID, Key: data[i].Key}
+		if metric.IsDistance {
+			neighbors[n].Distance = &score
+		} else {
+			neighbors[n].Similarity = &score
+		}
+	}
+	return neighbors
+}
+
+// parseThreshold reads the threshold query parameter, or nil when it is
+// absent. Cosine similarities lie in [-1, 1] and distances are never
+// negative, so thresholds outside those ranges are errors.
+func parseThreshold(query url.Values, metric similarityMetric) (*float64, error) {
+	thresholdStr := query.Get("threshold")
+	if thresholdStr == "" {
+		return nil, nil
+	}
+	threshold, err := strconv.ParseFloat(thresholdStr, 64)
+	if err != nil || math.IsInf(threshold, 0) || math.IsNaN(threshold) {
+		return nil, fmt.Errorf("threshold must be a finite number")
+	}
+	switch metric.Name {
+	case metricCosine:
+		if threshold < -1 || threshold > 1 {
+			return nil, fmt.Errorf("threshold must be between -1 and 1 for metric %s", metric.Name)
+		}
+	case metricEuclidean:
+		if threshold < 0 {
+			return nil, fmt.Errorf("threshold must not be negative for metric %s", metric.Name)
+		}
+	}
+	return &threshold, nil
+}
+
+// withinThreshold returns the leading neighbors at least as close as
+// threshold: similarities at or above it, or distances at or below it.
+// Neighbors must be sorted closest first.
+func withinThreshold(neighbors []Neighbor, metric similarityMetric, threshold float64) []Neighbor {
+	for n, neighbor := range neighbors {
+		var within bool
+		if metric.IsDistance {
+			within = *neighbor.Distance <= threshold
+		} else {
+			within = *neighbor.Similarity >= threshold
+		}
+		if !within {
+			return neighbors[:n]
+		}
+	}
+	return neighbors
+}
+
+func handleNeighbors(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters. IDs resolve in the same logical dataset as
+	// the listing and item endpoints.
+	params, err := parsePagedParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	metric, err := parseMetric(r.URL.Query(), metricCosine)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Unseeded synthetic data changes on every request, so its IDs can't
+	// be looked up again
+	if loadedItems() == nil && !params.Seeded {
+		writeError(w, http.StatusBadRequest, "seed is required to look up generated vectors")
+		return
+	}
+
+	id := r.PathValue("id")
+
+	k := 10 // Default
+	kStr := r.URL.Query().Get("k")
+	if kStr != "" {
+		parsedK, err := strconv.Atoi(kStr)
+		if err != nil || parsedK <= 0 {
+			writeError(w, http.StatusBadRequest, "k must be a positive integer")
+			return
+		}
+		k = parsedK
+	}
+
+	// With a threshold, k only caps the results when it is given
+	threshold, err := parseThreshold(r.URL.Query(), metric)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	options, err := parseANNOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if options.Index == indexANN {
+		if _, ok := metricDistance(metric); !ok {
+			writeError(w, http.StatusBadRequest, "the ann index supports metric cosine or euclidean")
+			return
+		}
+		if threshold != nil && kStr == "" {
+			writeError(w, http.StatusBadRequest, "the ann index needs k to bound a threshold query")
+			return
+		}
+	}
+
+	var matched *int
+
+	var neighbors []Neighbor
+	if options.Index == indexANN {
+		// Build or reuse the index for this dataset
+		dataset, err := annNeighborDataset(r.Context(), params, metric, options.M)
+		if err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		target, ok := dataset.nodes[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("vector %q not found", id))
+			return
+		}
+		neighbors = annNeighbors(dataset, target, k, options.Ef, metric)
+		if threshold != nil {
+			neighbors = withinThreshold(neighbors, metric, *threshold)
+		}
+	} else {
+		// Generate data, or reuse it with its norms
+		dataset, err := similarityDataset(r.Context(), params)
+		if err != nil {
+			writeWorkError(w, err)
+			return
+		}
+		data := dataset.items
+
+		target := -1
+		for i, item := range data {
+			if item.ID == id {
+				target = i
+				break
+			}
+		}
+		if target < 0 {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("vector %q not found", id))
+			return
+		}
+
+		if threshold != nil {
+			// Rank everything, keep what is close enough, then apply k
+			neighbors = withinThreshold(findNeighbors(data, dataset.norms, target, len(data), metric), metric, *threshold)
+			count := len(neighbors)
+			matched = &count
+			if kStr != "" && k < len(neighbors) {
+				neighbors = neighbors[:k]
+			}
+		} else {
+			neighbors = findNeighbors(data, dataset.norms, target, k, metric)
+		}
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := NeighborResponse{
+		ID:        id,
+		Vector:    params.VectorField,
+		Metric:    metric.Name,
+		Neighbors: neighbors,
+		Total:     len(neighbors),
+	}
+	if options.Index == indexANN {
+		response.Index = indexANN
+	}
+	if threshold != nil {
+		response.Threshold = threshold
+		response.Matched = matched
+		if kStr != "" {
+			response.K = &k
+		}
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
+
+// similarityMatrix returns the symmetric matrix of pairwise scores under
+// metric. For cosine the diagonal is exactly 1, including for zero vectors.
+// norms, when not nil, holds each vector's norm for cosine. Sparse data is
+// scored in sparse form.
+func similarityMatrix(data []VectorItem, norms []float64, metric similarityMetric) [][]float64 {
+	score := func(i, j int) float64 {
+		return metric.Score(data[i].Vector, data[j].Vector)
+	}
+	if metric.Name == metricCosine && norms != nil {
+		score = func(i, j int) float64 {
+			return cosineWithNorms(data[i].Vector, data[j].Vector, norms[i], norms[j])
+		}
+	}
+	if forms := sparseForms(data); forms != nil {
+		sparseMetric := sparseScore(metric.Name)
+		score = func(i, j int) float64 {
+			return sparseMetric(forms[i], forms[j])
+		}
+	}
+
+	matrix := make([][]float64, len(data))
+	for i := range matrix {
+		matrix[i] = make([]float64, len(data))
+		if metric.Name == metricCosine {
+			matrix[i][i] = 1
+		} else {
+			matrix[i][i] = score(i, i)
+		}
+	}
+	for i := range data {
+		for j := i + 1; j < len(data); j++ {
+			similarity := score(i, j)
+			matrix[i][j] = similarity
+			matrix[j][i] = similarity
+		}
+	}
+	return matrix
+}
+
+func handleSimilarityMatrix(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	metric, err := parseMetric(r.URL.Query(), metricCosine)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Refuse oversized matrices before generating anything
+	if datasetSize(params) > maxSimilarityMatrixItems {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("similarity matrix is limited to %d vectors", maxSimilarityMatrixItems))
+		return
+	}
+
+	// Generate data, or reuse it with its norms
+	dataset, err := similarityDataset(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	data := dataset.items
+	if len(data) > maxSimilarityMatrixItems {
+		// The loaded dataset grew in the meantime
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("similarity matrix is limited to %d vectors", maxSimilarityMatrixItems))
+		return
+	}
+
+	ids := make([]string, len(data))
+	for i, item := range data {
+		ids[i] = item.ID
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := SimilarityMatrixResponse{
+		IDs:    ids,
+		Metric: metric.Name,
+		Matrix: similarityMatrix(data, dataset.norms, metric),
+		Total:  len(data),
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
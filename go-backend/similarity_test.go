@@ -0,0 +1,199 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCosineSimilarityZeroVectors(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+	}{
+		{"zero and nonzero", []float64{0, 0, 0}, []float64{1, 2, 3}},
+		{"nonzero and zero", []float64{1, 2, 3}, []float64{0, 0, 0}},
+		{"both zero", []float64{0, 0, 0}, []float64{0, 0, 0}},
+		{"empty", []float64{}, []float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cosineSimilarity(tt.a, tt.b)
+			if math.IsNaN(got) {
+				t.Fatalf("cosineSimilarity(%v, %v) is NaN", tt.a, tt.b)
+			}
+			if got != 0 {
+				t.Errorf("cosineSimilarity(%v, %v) = %v, want 0", tt.a, tt.b, got)
+			}
+		})
+	}
+}
+
+func TestCosineSimilarityIdenticalVectors(t *testing.T) {
+	tests := [][]float64{
+		{1, 0, 0},
+		{1, 2, 3},
+		{-0.5, 0.25, 4, -8},
+		{1e-9, 1e-9},
+		{1e9, -1e9},
+	}
+	for _, v := range tests {
+		if got := cosineSimilarity(v, v); math.Abs(got-1) > 1e-12 {
+			t.Errorf("cosineSimilarity(%v, %v) = %v, want 1", v, v, got)
+		}
+		// Scaling either side doesn't change the angle
+		scaled := make([]float64, len(v))
+		for i, x := range v {
+			scaled[i] = 3 * x
+		}
+		if got := cosineSimilarity(v, scaled); math.Abs(got-1) > 1e-12 {
+			t.Errorf("cosineSimilarity(%v, %v) = %v, want 1", v, scaled, got)
+		}
+	}
+}
+
+func TestCosineWithNormsMatchesCosineSimilarity(t *testing.T) {
+	vectors := [][]float64{{0, 0, 0}, {1, 2, 3}, {-1, 0.5, 2}, {3, 6, 9}}
+	items := make([]VectorItem, len(vectors))
+	for i, v := range vectors {
+		items[i] = VectorItem{Vector: v}
+	}
+	norms := vectorNorms(items)
+	for i, a := range vectors {
+		for j, b := range vectors {
+			want := cosineSimilarity(a, b)
+			if got := cosineWithNorms(a, b, norms[i], norms[j]); got != want {
+				t.Errorf("cosineWithNorms(%v, %v) = %v, want %v", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestFindNeighborsZeroAndIdenticalVectors(t *testing.T) {
+	data := []VectorItem{
+		{ID: "target", Vector: []float64{1, 2, 3}},
+		{ID: "zero", Vector: []float64{0, 0, 0}},
+		{ID: "same", Vector: []float64{1, 2, 3}},
+		{ID: "opposite", Vector: []float64{-1, -2, -3}},
+	}
+	metric, err := metricByName(metricCosine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, norms := range [][]float64{nil, vectorNorms(data)} {
+		neighbors := findNeighbors(data, norms, 0, 10, metric)
+		if len(neighbors) != 3 {
+			t.Fatalf("got %d neighbors, want 3", len(neighbors))
+		}
+		want := []struct {
+			id         string
+			similarity float64
+		}{{"same", 1}, {"zero", 0}, {"opposite", -1}}
+		for i, w := range want {
+			n := neighbors[i]
+			if n.ID != w.id {
+				t.Fatalf("neighbor %d is %q, want %q", i, n.ID, w.id)
+			}
+			if n.Similarity == nil || math.IsNaN(*n.Similarity) || math.Abs(*n.Similarity-w.similarity) > 1e-12 {
+				t.Errorf("neighbor %q similarity = %v, want %v", n.ID, n.Similarity, w.similarity)
+			}
+		}
+	}
+}
+
+func TestMetricScores(t *testing.T) {
+	tests := []struct {
+		metric string
+		a, b   []float64
+		want   float64
+	}{
+		// cos = 0 for perpendicular vectors, 1 for parallel, -1 for opposite
+		{metricCosine, []float64{1, 0}, []float64{0, 1}, 0},
+		{metricCosine, []float64{1, 1}, []float64{2, 2}, 1},
+		{metricCosine, []float64{1, 2}, []float64{-1, -2}, -1},
+		// (1·4 + 2·5 + 3·6) / (√14 · √77) = 32 / √1078
+		{metricCosine, []float64{1, 2, 3}, []float64{4, 5, 6}, 32 / math.Sqrt(1078)},
+		// 1 / (1 · √2)
+		{metricCosine, []float64{1, 0}, []float64{1, 1}, 1 / math.Sqrt2},
+
+		{metricEuclidean, []float64{0, 0}, []float64{3, 4}, 5},
+		{metricEuclidean, []float64{1, 2, 3}, []float64{1, 2, 3}, 0},
+		// √(3² + 3² + 3²)
+		{metricEuclidean, []float64{1, 2, 3}, []float64{4, 5, 6}, math.Sqrt(27)},
+		{metricEuclidean, []float64{-1}, []float64{1}, 2},
+
+		{metricDot, []float64{1, 2, 3}, []float64{4, 5, 6}, 32},
+		{metricDot, []float64{1, 0}, []float64{0, 1}, 0},
+		{metricDot, []float64{2, -3}, []float64{-4, 1}, -11},
+		{metricDot, []float64{0.5, 0.5}, []float64{2, 2}, 2},
+	}
+	for _, tt := range tests {
+		metric, err := metricByName(tt.metric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := metric.Score(tt.a, tt.b); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s(%v, %v) = %v, want %v", tt.metric, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMetricByNameRejectsUnknown(t *testing.T) {
+	if _, err := metricByName("manhattan"); err == nil {
+		t.Error("metricByName(\"manhattan\") succeeded, want an error")
+	}
+}
+
+func TestFindNeighborsOrderPerMetric(t *testing.T) {
+	data := []VectorItem{
+		{ID: "target", Vector: []float64{1, 0}},
+		{ID: "far", Vector: []float64{10, 10}},
+		{ID: "near", Vector: []float64{1, 0.1}},
+		{ID: "opposite", Vector: []float64{-1, 0}},
+	}
+	tests := []struct {
+		metric string
+		want   []string
+	}{
+		// Similarities rank largest first, distances smallest first
+		{metricCosine, []string{"near", "far", "opposite"}},
+		{metricEuclidean, []string{"near", "opposite", "far"}},
+		{metricDot, []string{"far", "near", "opposite"}},
+	}
+	for _, tt := range tests {
+		metric, err := metricByName(tt.metric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		neighbors := findNeighbors(data, nil, 0, len(data), metric)
+		for i, id := range tt.want {
+			if neighbors[i].ID != id {
+				t.Errorf("%s: neighbor %d is %q, want %q", tt.metric, i, neighbors[i].ID, id)
+			}
+		}
+		if metric.IsDistance != (neighbors[0].Distance != nil) || metric.IsDistance == (neighbors[0].Similarity != nil) {
+			t.Errorf("%s: neighbors report the wrong score field", tt.metric)
+		}
+	}
+}
+
+// neighborsStatus requests the neighbors of id with query
+func neighborsStatus(id, query string) int {
+	r := httptest.NewRequest("GET", "/api/vectors/"+id+"/neighbors?"+query, nil)
+	r.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handleNeighbors(w, r)
+	return w.Code
+}
+
+func TestNeighborsResolveIDsLikeItemLookup(t *testing.T) {
+	// The item endpoint finds 750 in the default logical dataset
+	if code := neighborsStatus("750", "seed=1&dimensions=3"); code != http.StatusOK {
+		t.Errorf("neighbors of 750 answered %d, want 200", code)
+	}
+	if code := neighborsStatus("750", "dimensions=3"); code != http.StatusBadRequest {
+		t.Errorf("unseeded neighbors answered %d, want 400", code)
+	}
+}
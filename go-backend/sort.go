@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// shuffleSeedSalt separates the shuffling source from the generation source
+const shuffleSeedSalt = 0x2545F4914F6CDD1D
+
+// sortOptions order items by id, key or a metadata field. An empty Key
+// leaves the dataset order unchanged. Shuffle first permutes the items
+// with a source derived from ShuffleSeed.
+type sortOptions struct {
+	Key         string
+	Descending  bool
+	Shuffle     bool
+	ShuffleSeed int64
+}
+
+// parseSort reads the sort, order and shuffle query parameters. The sort
+// key must be id, key or a metadata field the dataset has. Shuffles are
+// seeded from the request seed, so seeded requests shuffle identically.
+func parseSort(query url.Values, params vectorParams) (sortOptions, error) {
+	opts := sortOptions{Key: query.Get("sort"), ShuffleSeed: params.Seed ^ shuffleSeedSalt}
+
+	if shuffleStr := query.Get("shuffle"); shuffleStr != "" {
+		shuffle, err := strconv.ParseBool(shuffleStr)
+		if err != nil {
+			return opts, fmt.Errorf("shuffle must be true or false")
+		}
+		opts.Shuffle = shuffle
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, fmt.Errorf("order must be asc or desc")
+	}
+
+	if opts.Key != "" && !isSortKey(opts.Key) {
+		return opts, fmt.Errorf("unknown sort key %q", opts.Key)
+	}
+	return opts, nil
+}
+
+// isSortKey reports whether items can be sorted by key
+func isSortKey(key string) bool {
+	if key == "id" || key == "key" {
+		return true
+	}
+	if loaded := loadedItems(); loaded != nil {
+		for _, item := range loaded {
+			if _, ok := item.Metadata[key]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	for _, field := range metadataSchema {
+		if field.Name == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Reorders reports whether Apply changes the dataset order
+func (opts sortOptions) Reorders() bool {
+	return opts.Key != "" || opts.Shuffle
+}
+
+// Apply shuffles and sorts data in place. The sort is stable, so ties keep
+// dataset order (or the shuffled order) in either direction, and items
+// missing the field always sort last.
+func (opts sortOptions) Apply(data []VectorItem) {
+	if opts.Shuffle {
+		// Fisher-Yates shuffle, as in getRandomItems
+		rng := rand.New(rand.NewSource(opts.ShuffleSeed))
+		for i := len(data) - 1; i > 0; i-- {
+			j := rng.Intn(i + 1)
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+	if opts.Key == "" {
+		return
+	}
+
+	values := make([]interface{}, len(data))
+	for i, item := range data {
+		switch opts.Key {
+		case "id":
+			values[i] = item.ID
+		case "key":
+			values[i] = item.Key
+		default:
+			values[i] = item.Metadata[opts.Key]
+		}
+	}
+
+	order := make([]int, len(data))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		va, vb := values[order[a]], values[order[b]]
+		if va == nil || vb == nil {
+			return va != nil
+		}
+		c := compareValues(va, vb)
+		if opts.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	sorted := make([]VectorItem, len(data))
+	for i, j := range order {
+		sorted[i] = data[j]
+	}
+	copy(data, sorted)
+}
+
+// compareValues orders two metadata values, returning -1, 0 or 1. Numbers
+// compare numerically, as do strings that are both integers, such as
+// sequential IDs; false sorts before true; anything else compares by its
+// string form.
+func compareValues(a, b interface{}) int {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return compareFloats(fa, fb)
+		}
+	}
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			ia, errA := strconv.ParseInt(sa, 10, 64)
+			ib, errB := strconv.ParseInt(sb, 10, 64)
+			if errA == nil && errB == nil {
+				return compareFloats(float64(ia), float64(ib))
+			}
+		}
+	}
+	if ba, ok := a.(bool); ok {
+		if bb, ok := b.(bool); ok {
+			switch {
+			case ba == bb:
+				return 0
+			case bb:
+				return -1
+			default:
+				return 1
+			}
+		}
+	}
+
+	sa, sb := fmt.Sprint(a), fmt.Sprint(b)
+	switch {
+	case sa < sb:
+		return -1
+	case sa > sb:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
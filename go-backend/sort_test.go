@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// fetchVectors requests /api/vectors with query and decodes the response
+func fetchVectors(t *testing.T, query string) VectorDataResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handleVectorData(w, httptest.NewRequest("GET", "/api/vectors?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", query, w.Code, w.Body)
+	}
+	var response VectorDataResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// checkPagesContinue fetches consecutive pages of the dataset query
+// describes and checks they neither overlap nor skip items: together they
+// are the leading items of one larger page
+func checkPagesContinue(t *testing.T, query string) {
+	t.Helper()
+	const limit, pages = 50, 3
+	whole := fetchVectors(t, query+"&limit="+strconv.Itoa(limit*pages))
+
+	seen := make(map[string]int)
+	for page := 0; page < pages; page++ {
+		offset := page * limit
+		response := fetchVectors(t, query+"&limit="+strconv.Itoa(limit)+"&offset="+strconv.Itoa(offset))
+		if response.Total != whole.Total {
+			t.Errorf("page %d total = %d, want %d", page, response.Total, whole.Total)
+		}
+		if response.NextOffset == nil || *response.NextOffset != offset+limit {
+			t.Errorf("page %d next_offset = %v, want %d", page, response.NextOffset, offset+limit)
+		}
+		if len(response.Data) != limit {
+			t.Fatalf("page %d has %d items, want %d", page, len(response.Data), limit)
+		}
+		for i, item := range response.Data {
+			if previous, ok := seen[item.ID]; ok {
+				t.Errorf("item %q is on page %d and page %d", item.ID, previous, page)
+			}
+			seen[item.ID] = page
+			if want := whole.Data[offset+i].ID; item.ID != want {
+				t.Errorf("page %d item %d is %q, want %q", page, i, item.ID, want)
+			}
+		}
+	}
+}
+
+func TestSortedPagesContinue(t *testing.T) {
+	for _, query := range []string{
+		"seed=7&dimensions=4&sort=score&order=desc",
+		"seed=7&dimensions=4&sort=key",
+		"seed=7&dimensions=4&sort=status&filter=isActive:true",
+	} {
+		t.Run(query, func(t *testing.T) {
+			checkPagesContinue(t, query)
+		})
+	}
+}
+
+func TestSortIsStableAndOrdered(t *testing.T) {
+	response := fetchVectors(t, "seed=7&dimensions=4&limit=500&size=500&sort=status&order=desc")
+	for i := 1; i < len(response.Data); i++ {
+		previous, current := response.Data[i-1], response.Data[i]
+		a, b := previous.Metadata["status"].(string), current.Metadata["status"].(string)
+		if a < b {
+			t.Fatalf("item %d status %q sorts after %q in descending order", i, b, a)
+		}
+		// Ties keep generation order, which is ID order for sequential IDs
+		if a == b {
+			prevID, _ := strconv.Atoi(previous.ID)
+			id, _ := strconv.Atoi(current.ID)
+			if id < prevID {
+				t.Fatalf("tied items %q and %q are out of generation order", previous.ID, current.ID)
+			}
+		}
+	}
+}
+
+func TestSortNumericField(t *testing.T) {
+	response := fetchVectors(t, "seed=7&dimensions=4&limit=500&size=500&sort=value")
+	for i := 1; i < len(response.Data); i++ {
+		a, _ := toFloat(response.Data[i-1].Metadata["value"])
+		b, _ := toFloat(response.Data[i].Metadata["value"])
+		if a > b {
+			t.Fatalf("item %d value %v sorts after %v", i, b, a)
+		}
+	}
+}
+
+func TestSortUnknownKey(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleVectorData(w, httptest.NewRequest("GET", "/api/vectors?sort=nope", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestShuffledPagesContinue(t *testing.T) {
+	checkPagesContinue(t, "seed=7&dimensions=4&shuffle=true")
+}
+
+func TestShuffledPagesFormOnePermutation(t *testing.T) {
+	const size, limit = 300, 50
+	seen := make(map[string]bool)
+	moved := 0
+	for offset := 0; offset < size; offset += limit {
+		query := "seed=7&dimensions=4&shuffle=true&size=" + strconv.Itoa(size) +
+			"&limit=" + strconv.Itoa(limit) + "&offset=" + strconv.Itoa(offset)
+		for i, item := range fetchVectors(t, query).Data {
+			if seen[item.ID] {
+				t.Errorf("item %q appears twice", item.ID)
+			}
+			seen[item.ID] = true
+			if item.ID != strconv.Itoa(offset+i) {
+				moved++
+			}
+		}
+	}
+
+	// Sequential IDs run from 0 to size - 1
+	for i := 0; i < size; i++ {
+		if !seen[strconv.Itoa(i)] {
+			t.Errorf("item %d is on no page", i)
+		}
+	}
+	if moved < size/2 {
+		t.Errorf("only %d of %d items moved, want a shuffled order", moved, size)
+	}
+}
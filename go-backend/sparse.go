@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// SparseVector is the compact form of a mostly-zero vector: the positions
+// of its nonzero components in increasing order, and their values
+type SparseVector struct {
+	Indices []int     `json:"indices"`
+	Values  []float64 `json:"values"`
+}
+
+// toSparse returns the nonzero components of v
+func toSparse(v []float64) SparseVector {
+	s := SparseVector{Indices: []int{}, Values: []float64{}}
+	for i, x := range v {
+		if x != 0 {
+			s.Indices = append(s.Indices, i)
+			s.Values = append(s.Values, x)
+		}
+	}
+	return s
+}
+
+// Dense expands s into a vector of the given dimensions
+func (s SparseVector) Dense(dimensions int) []float64 {
+	v := make([]float64, dimensions)
+	for n, i := range s.Indices {
+		v[i] = s.Values[n]
+	}
+	return v
+}
+
+// sparsify zeroes all but round((1 - sparsity) * len(v)) components of v,
+// chosen uniformly with rng. At least one component is kept so the vector
+// still has a direction.
+func sparsify(rng *rand.Rand, v []float64, sparsity float64) {
+	keep := max(int(math.Round((1-sparsity)*float64(len(v)))), 1)
+	if keep >= len(v) {
+		return
+	}
+
+	// Partial Fisher-Yates: the first keep positions are the survivors
+	positions := make([]int, len(v))
+	for i := range positions {
+		positions[i] = i
+	}
+	for i := 0; i < keep; i++ {
+		j := i + rng.Intn(len(positions)-i)
+		positions[i], positions[j] = positions[j], positions[i]
+	}
+	for _, i := range positions[keep:] {
+		v[i] = 0
+	}
+}
+
+// sparseMerge walks the union of a's and b's nonzero positions in
+// increasing order, calling f with both values at each one. Components
+// that are zero in both vectors are skipped, which is what makes sparse
+// scores cheap.
+func sparseMerge(a, b SparseVector, f func(x, y float64)) {
+	i, j := 0, 0
+	for i < len(a.Indices) || j < len(b.Indices) {
+		switch {
+		case j >= len(b.Indices) || (i < len(a.Indices) && a.Indices[i] < b.Indices[j]):
+			f(a.Values[i], 0)
+			i++
+		case i >= len(a.Indices) || b.Indices[j] < a.Indices[i]:
+			f(0, b.Values[j])
+			j++
+		default:
+			f(a.Values[i], b.Values[j])
+			i++
+			j++
+		}
+	}
+}
+
+// sparseScore returns the sparse counterpart of the named metric. Both
+// forms visit the nonzero components in the same order, so they agree
+// exactly with the dense scores.
+func sparseScore(name string) func(a, b SparseVector) float64 {
+	switch name {
+	case metricCosine:
+		return func(a, b SparseVector) float64 {
+			var dot, normA, normB float64
+			sparseMerge(a, b, func(x, y float64) {
+				dot += x * y
+				normA += x * x
+				normB += y * y
+			})
+			if normA == 0 || normB == 0 {
+				return 0
+			}
+			return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+		}
+	case metricEuclidean:
+		return func(a, b SparseVector) float64 {
+			sum := 0.0
+			sparseMerge(a, b, func(x, y float64) {
+				d := x - y
+				sum += d * d
+			})
+			return math.Sqrt(sum)
+		}
+	default:
+		return func(a, b SparseVector) float64 {
+			sum := 0.0
+			sparseMerge(a, b, func(x, y float64) {
+				sum += x * y
+			})
+			return sum
+		}
+	}
+}
+
+// sparseForms converts every item's vector to sparse form when that saves
+// work, i.e. when at most half of all components are nonzero. It returns
+// nil for dense data.
+func sparseForms(data []VectorItem) []SparseVector {
+	total, nonzero := 0, 0
+	for _, item := range data {
+		total += len(item.Vector)
+		for _, x := range item.Vector {
+			if x != 0 {
+				nonzero++
+			}
+		}
+	}
+	if total == 0 || nonzero*2 > total {
+		return nil
+	}
+
+	forms := make([]SparseVector, len(data))
+	for i, item := range data {
+		forms[i] = toSparse(item.Vector)
+	}
+	return forms
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// NumericSummary describes the distribution of a numeric metadata field.
+// Count is the number of items that had the field; the other values are
+// zero when it is 0.
+type NumericSummary struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Mean  float64 `json:"mean"`
+	Max   float64 `json:"max"`
+}
+
+// StatsResponse summarizes a dataset without returning its items
+type StatsResponse struct {
+	Total    int            `json:"total"`
+	Clusters map[string]int `json:"clusters"`
+	Score    NumericSummary `json:"score"`
+	Value    NumericSummary `json:"value"`
+	Status   map[string]int `json:"status"`
+	Priority map[string]int `json:"priority"`
+}
+
+// numericAccumulator builds a NumericSummary one value at a time
+type numericAccumulator struct {
+	summary NumericSummary
+	sum     float64
+}
+
+func (a *numericAccumulator) Add(value interface{}) {
+	v, ok := toFloat(value)
+	if !ok {
+		return
+	}
+	if a.summary.Count == 0 || v < a.summary.Min {
+		a.summary.Min = v
+	}
+	if a.summary.Count == 0 || v > a.summary.Max {
+		a.summary.Max = v
+	}
+	a.summary.Count++
+	a.sum += v
+}
+
+func (a *numericAccumulator) Summary() NumericSummary {
+	summary := a.summary
+	if summary.Count > 0 {
+		summary.Mean = a.sum / float64(summary.Count)
+	}
+	return summary
+}
+
+// computeStats summarizes the dataset in a single pass, so only the
+// aggregates are held in memory
+func computeStats(next func() (VectorItem, bool)) StatsResponse {
+	stats := StatsResponse{
+		Clusters: make(map[string]int),
+		Status:   make(map[string]int),
+		Priority: make(map[string]int),
+	}
+
+	var score, value numericAccumulator
+	for {
+		item, ok := next()
+		if !ok {
+			break
+		}
+
+		stats.Total++
+		for _, cluster := range item.Clusters {
+			stats.Clusters[cluster]++
+		}
+		score.Add(item.Metadata["score"])
+		value.Add(item.Metadata["value"])
+		if status, ok := item.Metadata["status"]; ok {
+			stats.Status[fmt.Sprint(status)]++
+		}
+		if priority, ok := item.Metadata["priority"]; ok {
+			stats.Priority[fmt.Sprint(priority)]++
+		}
+	}
+
+	stats.Score = score.Summary()
+	stats.Value = value.Summary()
+	return stats
+}
+
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats := computeStats(datasetIterator(params))
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	newJSONEncoder(w, r).Encode(stats)
+}
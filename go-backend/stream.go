@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// wantsNDJSON reports whether the client asked for newline-delimited JSON,
+// either with ?format=ndjson or an Accept header
+func wantsNDJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "ndjson"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+}
+
+// streamFunc writes a page of items as they are generated
+type streamFunc func(ctx context.Context, w http.ResponseWriter, next func() (VectorItem, bool), params vectorParams, filters []itemFilter, output outputOptions)
+
+// streamWriter returns the streaming encoder the client asked for and its
+// format name, or nil for a regular JSON response
+func streamWriter(r *http.Request) (streamFunc, string) {
+	switch {
+	case wantsNDJSON(r):
+		return streamNDJSON, "ndjson"
+	case wantsCSV(r):
+		return streamCSV, "csv"
+	default:
+		return nil, ""
+	}
+}
+
+// pageIterator wraps next so it yields only the items of the requested
+// page, counting just the items that pass filters towards the offset
+func pageIterator(next func() (VectorItem, bool), params vectorParams, filters []itemFilter) func() (VectorItem, bool) {
+	matched := 0
+	return func() (VectorItem, bool) {
+		for matched < params.Offset+params.Limit {
+			item, ok := next()
+			if !ok {
+				return VectorItem{}, false
+			}
+			if !matchesFilters(item, filters) {
+				continue
+			}
+			matched++
+			if matched > params.Offset {
+				return item, true
+			}
+		}
+		return VectorItem{}, false
+	}
+}
+
+// streamNDJSON writes the requested page one item per line as it is
+// generated, flushing after each line so clients can render progressively.
+// Once streaming has started the status code can no longer change, so an
+// item that fails to encode is reported as a final ErrorResponse line.
+func streamNDJSON(ctx context.Context, w http.ResponseWriter, next func() (VectorItem, bool), params vectorParams, filters []itemFilter, output outputOptions) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	page := pageIterator(next, params, filters)
+	for written := 0; ; written++ {
+		if err := ctx.Err(); err != nil {
+			// Nothing can change the status now; just end the stream
+			log.Printf("ndjson: stream stopped after %d items: %v", written, err)
+			return
+		}
+		item, ok := page()
+		if !ok {
+			return
+		}
+
+		line, err := json.Marshal(output.Apply(item))
+		if err != nil {
+			log.Printf("ndjson: encoding item %s: %v", item.ID, err)
+			line, _ = json.Marshal(ErrorResponse{Error: "failed to encode item " + item.ID})
+			w.Write(append(line, '\n'))
+			return
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			// The client has gone away; nobody is left to tell
+			log.Printf("ndjson: stream aborted after %d items: %v", written, err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Exact t-SNE is O(n²) per iteration, so the endpoint refuses larger inputs
+const maxTSNEItems = 2000
+
+// Optimizer settings from the reference t-SNE implementation
+const (
+	tsneLearningRate        = 200.0
+	tsneExaggeration        = 4.0
+	tsneExaggerationIters   = 100
+	tsneMomentumSwitchIters = 250
+	tsneInitialMomentum     = 0.5
+	tsneFinalMomentum       = 0.8
+	tsneMinGain             = 0.01
+)
+
+// TSNEResponse is the response structure for t-SNE projections
+type TSNEResponse struct {
+	Data       []VectorItem `json:"data"`
+	Total      int          `json:"total"`
+	Perplexity float64      `json:"perplexity"`
+	Iterations int          `json:"iterations"`
+}
+
+// runTSNE embeds vectors in 2D with exact t-SNE. The initial layout is
+// drawn from rng, so a seeded rng gives a reproducible embedding. It stops
+// between iterations with the context's error once ctx ends.
+func runTSNE(ctx context.Context, vectors [][]float64, perplexity float64, iterations int, rng *rand.Rand) ([][]float64, error) {
+	n := len(vectors)
+	p := tsneAffinities(vectors, perplexity)
+
+	y := make([][2]float64, n)
+	for i := range y {
+		y[i] = [2]float64{rng.NormFloat64() * 1e-4, rng.NormFloat64() * 1e-4}
+	}
+	velocity := make([][2]float64, n)
+	gains := make([][2]float64, n)
+	for i := range gains {
+		gains[i] = [2]float64{1, 1}
+	}
+
+	num := make([]float64, n*n)
+	grad := make([][2]float64, n)
+	for iter := 0; iter < iterations; iter++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		exaggeration := 1.0
+		if iter < tsneExaggerationIters {
+			exaggeration = tsneExaggeration
+		}
+		momentum := tsneFinalMomentum
+		if iter < tsneMomentumSwitchIters {
+			momentum = tsneInitialMomentum
+		}
+
+		// Student-t kernel between all pairs of embedded points
+		sumNum := 0.0
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				dx, dy := y[i][0]-y[j][0], y[i][1]-y[j][1]
+				q := 1 / (1 + dx*dx + dy*dy)
+				num[i*n+j] = q
+				num[j*n+i] = q
+				sumNum += 2 * q
+			}
+		}
+
+		for i := 0; i < n; i++ {
+			grad[i] = [2]float64{}
+			for j := 0; j < n; j++ {
+				if i == j {
+					continue
+				}
+				q := num[i*n+j]
+				mult := (exaggeration*p[i*n+j] - math.Max(q/sumNum, 1e-12)) * q
+				grad[i][0] += 4 * mult * (y[i][0] - y[j][0])
+				grad[i][1] += 4 * mult * (y[i][1] - y[j][1])
+			}
+		}
+
+		// Gradient step with per-coordinate adaptive gains
+		var mean [2]float64
+		for i := 0; i < n; i++ {
+			for d := 0; d < 2; d++ {
+				if (grad[i][d] > 0) != (velocity[i][d] > 0) {
+					gains[i][d] += 0.2
+				} else {
+					gains[i][d] *= 0.8
+				}
+				gains[i][d] = math.Max(gains[i][d], tsneMinGain)
+				velocity[i][d] = momentum*velocity[i][d] - tsneLearningRate*gains[i][d]*grad[i][d]
+				y[i][d] += velocity[i][d]
+				mean[d] += y[i][d]
+			}
+		}
+
+		// Keep the embedding centered on the origin
+		for i := 0; i < n; i++ {
+			y[i][0] -= mean[0] / float64(n)
+			y[i][1] -= mean[1] / float64(n)
+		}
+	}
+
+	embedding := make([][]float64, n)
+	for i := range y {
+		embedding[i] = []float64{y[i][0], y[i][1]}
+	}
+	return embedding, nil
+}
+
+// tsneAffinities returns the symmetrized joint probabilities P as a flat
+// n×n matrix. Each point's Gaussian bandwidth is found by binary search so
+// its conditional distribution has the requested perplexity.
+func tsneAffinities(vectors [][]float64, perplexity float64) []float64 {
+	n := len(vectors)
+	distances := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			d := squaredEuclidean(vectors[i], vectors[j])
+			distances[i*n+j] = d
+			distances[j*n+i] = d
+		}
+	}
+
+	targetEntropy := math.Log(perplexity)
+	conditional := make([]float64, n*n)
+	row := make([]float64, n)
+	for i := 0; i < n; i++ {
+		beta, betaMin, betaMax := 1.0, math.Inf(-1), math.Inf(1)
+		for attempt := 0; attempt < 50; attempt++ {
+			sum := 0.0
+			for j := 0; j < n; j++ {
+				row[j] = 0
+				if j != i {
+					row[j] = math.Exp(-distances[i*n+j] * beta)
+				}
+				sum += row[j]
+			}
+			if sum == 0 {
+				sum = 1e-12
+			}
+
+			entropy := 0.0
+			for j := 0; j < n; j++ {
+				row[j] /= sum
+				if row[j] > 0 {
+					entropy -= row[j] * math.Log(row[j])
+				}
+			}
+
+			diff := entropy - targetEntropy
+			if math.Abs(diff) < 1e-5 {
+				break
+			}
+			// Too flat means the bandwidth is too wide, so raise beta
+			if diff > 0 {
+				betaMin = beta
+				if math.IsInf(betaMax, 1) {
+					beta *= 2
+				} else {
+					beta = (beta + betaMax) / 2
+				}
+			} else {
+				betaMax = beta
+				if math.IsInf(betaMin, -1) {
+					beta /= 2
+				} else {
+					beta = (beta + betaMin) / 2
+				}
+			}
+		}
+		copy(conditional[i*n:(i+1)*n], row)
+	}
+
+	p := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			p[i*n+j] = math.Max((conditional[i*n+j]+conditional[j*n+i])/float64(2*n), 1e-12)
+		}
+	}
+	return p
+}
+
+// parseTSNEOptions reads the perplexity and iterations query parameters
+func parseTSNEOptions(query url.Values) (float64, int, error) {
+	perplexity := 30.0 // Default
+	if perplexityStr := query.Get("perplexity"); perplexityStr != "" {
+		parsedPerplexity, err := strconv.ParseFloat(perplexityStr, 64)
+		if err != nil || parsedPerplexity <= 0 {
+			return 0, 0, fmt.Errorf("perplexity must be a positive number")
+		}
+		perplexity = parsedPerplexity
+	}
+
+	iterations := 500 // Default
+	if iterationsStr := query.Get("iterations"); iterationsStr != "" {
+		parsedIterations, err := strconv.Atoi(iterationsStr)
+		if err != nil || parsedIterations <= 0 {
+			return 0, 0, fmt.Errorf("iterations must be a positive integer")
+		}
+		iterations = parsedIterations
+	}
+
+	return perplexity, iterations, nil
+}
+
+// checkTSNEInput reports why n vectors can't be embedded at perplexity
+func checkTSNEInput(n int, perplexity float64) error {
+	if n > maxTSNEItems {
+		return fmt.Errorf("t-SNE is limited to %d vectors", maxTSNEItems)
+	}
+	if perplexity >= float64(n) {
+		return fmt.Errorf("perplexity must be less than the number of vectors (%d)", n)
+	}
+	return nil
+}
+
+func handleTSNE(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	perplexity, iterations, err := parseTSNEOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Refuse oversized requests before generating anything
+	if err := checkTSNEInput(datasetSize(params), perplexity); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	if err := checkTSNEInput(len(data), perplexity); err != nil {
+		// The loaded dataset changed in the meantime
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	// Seed the initial layout from the generation seed so results are reproducible
+	embedding, err := runTSNE(r.Context(), vectors, perplexity, iterations, params.Rand())
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	for i := range data {
+		data[i].Projection = embedding[i]
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := TSNEResponse{
+		Data:       data,
+		Total:      len(data),
+		Perplexity: perplexity,
+		Iterations: iterations,
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}
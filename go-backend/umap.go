@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Building the exact neighbor graph is O(n²), so the endpoint refuses
+// larger inputs
+const maxUMAPItems = 2000
+
+// Optimizer settings from the reference UMAP implementation
+const (
+	umapEpochs          = 200
+	umapNegativeSamples = 5
+	umapInitialAlpha    = 1.0
+	umapGradientClip    = 4.0
+	umapSpread          = 1.0
+)
+
+// UMAPResponse is the response structure for UMAP projections.
+// NeighborhoodPreservation is the mean fraction of each point's
+// n_neighbors nearest neighbors that are also its nearest neighbors in the
+// embedding.
+type UMAPResponse struct {
+	Data                     []VectorItem `json:"data"`
+	Total                    int          `json:"total"`
+	NNeighbors               int          `json:"n_neighbors"`
+	MinDist                  float64      `json:"min_dist"`
+	NeighborhoodPreservation float64      `json:"neighborhood_preservation"`
+}
+
+// umapEdge is one edge of the fuzzy neighbor graph
+type umapEdge struct {
+	from, to int
+	weight   float64
+}
+
+// runUMAP embeds vectors in the given number of dimensions with a
+// simplified UMAP: a fuzzy k-nearest neighbor graph laid out by stochastic
+// gradient descent with negative sampling, starting from a random layout
+// drawn from rng. It stops between epochs with the context's error once
+// ctx ends.
+func runUMAP(ctx context.Context, vectors [][]float64, nNeighbors int, minDist float64, components int, rng *rand.Rand) ([][]float64, error) {
+	n := len(vectors)
+	neighbors, distances := exactKNN(vectors, nNeighbors)
+	edges := umapGraph(neighbors, distances)
+	a, b := fitUMAPCurve(minDist)
+
+	embedding := make([][]float64, n)
+	for i := range embedding {
+		embedding[i] = make([]float64, components)
+		for d := range embedding[i] {
+			embedding[i][d] = rng.Float64()*20 - 10
+		}
+	}
+
+	maxWeight := 0.0
+	for _, edge := range edges {
+		maxWeight = math.Max(maxWeight, edge.weight)
+	}
+
+	clip := func(v float64) float64 {
+		return math.Max(-umapGradientClip, math.Min(umapGradientClip, v))
+	}
+
+	for epoch := 0; epoch < umapEpochs; epoch++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		alpha := umapInitialAlpha * (1 - float64(epoch)/float64(umapEpochs))
+		for _, edge := range edges {
+			// Sample each edge in proportion to its weight
+			if rng.Float64()*maxWeight > edge.weight {
+				continue
+			}
+
+			// Attraction between the edge's endpoints
+			head, tail := embedding[edge.from], embedding[edge.to]
+			d2 := squaredEuclidean(head, tail)
+			if d2 > 0 {
+				coefficient := -2 * a * b * math.Pow(d2, b-1) / (1 + a*math.Pow(d2, b))
+				for d := range head {
+					grad := clip(coefficient * (head[d] - tail[d]))
+					head[d] += grad * alpha
+					tail[d] -= grad * alpha
+				}
+			}
+
+			// Repulsion from randomly sampled points
+			for s := 0; s < umapNegativeSamples; s++ {
+				other := embedding[rng.Intn(n)]
+				d2 := squaredEuclidean(head, other)
+				if d2 == 0 {
+					continue
+				}
+				coefficient := 2 * b / ((0.001 + d2) * (1 + a*math.Pow(d2, b)))
+				for d := range head {
+					head[d] += clip(coefficient*(head[d]-other[d])) * alpha
+				}
+			}
+		}
+	}
+
+	return embedding, nil
+}
+
+// exactKNN returns the indices and distances of each vector's k nearest
+// neighbors, closest first
+func exactKNN(vectors [][]float64, k int) ([][]int, [][]float64) {
+	n := len(vectors)
+	neighbors := make([][]int, n)
+	distances := make([][]float64, n)
+
+	order := make([]int, 0, n-1)
+	row := make([]float64, n)
+	for i := 0; i < n; i++ {
+		order = order[:0]
+		for j := 0; j < n; j++ {
+			if j != i {
+				row[j] = math.Sqrt(squaredEuclidean(vectors[i], vectors[j]))
+				order = append(order, j)
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return row[order[a]] < row[order[b]]
+		})
+
+		neighbors[i] = make([]int, k)
+		distances[i] = make([]float64, k)
+		for m := 0; m < k; m++ {
+			neighbors[i][m] = order[m]
+			distances[i][m] = row[order[m]]
+		}
+	}
+	return neighbors, distances
+}
+
+// umapGraph turns the k-nearest-neighbor lists into a symmetric fuzzy
+// graph. Each point's membership strengths are scaled so they sum to
+// log2(k), and directed strengths combine with a fuzzy union.
+func umapGraph(neighbors [][]int, distances [][]float64) []umapEdge {
+	k := len(neighbors[0])
+	target := math.Log2(float64(k))
+
+	directed := make(map[[2]int]float64)
+	for i := range neighbors {
+		rho := distances[i][0]
+
+		// Binary search for the bandwidth
+		lo, hi, sigma := 0.0, math.Inf(1), 1.0
+		for attempt := 0; attempt < 64; attempt++ {
+			sum := 0.0
+			for _, d := range distances[i] {
+				sum += math.Exp(-math.Max(0, d-rho) / sigma)
+			}
+			if math.Abs(sum-target) < 1e-5 {
+				break
+			}
+			if sum > target {
+				hi = sigma
+				sigma = (lo + hi) / 2
+			} else {
+				lo = sigma
+				if math.IsInf(hi, 1) {
+					sigma *= 2
+				} else {
+					sigma = (lo + hi) / 2
+				}
+			}
+		}
+
+		for m, j := range neighbors[i] {
+			directed[[2]int{i, j}] = math.Exp(-math.Max(0, distances[i][m]-rho) / sigma)
+		}
+	}
+
+	// Iterate in a fixed order so the layout doesn't depend on map order
+	var edges []umapEdge
+	for i := range neighbors {
+		for _, j := range neighbors[i] {
+			forward := directed[[2]int{i, j}]
+			backward, mutual := directed[[2]int{j, i}]
+			if mutual && j < i {
+				continue // Already added from j's side
+			}
+			edges = append(edges, umapEdge{from: i, to: j, weight: forward + backward - forward*backward})
+		}
+	}
+	return edges
+}
+
+// fitUMAPCurve finds a and b so that 1/(1+a·d^(2b)) approximates a curve
+// that is 1 up to minDist and then decays exponentially. A grid search is
+// plenty for two parameters.
+func fitUMAPCurve(minDist float64) (float64, float64) {
+	const samples = 300
+	xs := make([]float64, samples)
+	ys := make([]float64, samples)
+	for i := range xs {
+		xs[i] = 3 * umapSpread * float64(i+1) / samples
+		ys[i] = 1
+		if xs[i] > minDist {
+			ys[i] = math.Exp(-(xs[i] - minDist) / umapSpread)
+		}
+	}
+
+	bestA, bestB, bestErr := 1.0, 1.0, math.Inf(1)
+	for ai := 0; ai <= 100; ai++ {
+		a := math.Pow(10, -1+3*float64(ai)/100) // 0.1 to 100
+		for bi := 0; bi <= 100; bi++ {
+			b := 0.2 + 1.8*float64(bi)/100 // 0.2 to 2
+			err := 0.0
+			for i, x := range xs {
+				diff := 1/(1+a*math.Pow(x, 2*b)) - ys[i]
+				err += diff * diff
+			}
+			if err < bestErr {
+				bestA, bestB, bestErr = a, b, err
+			}
+		}
+	}
+	return bestA, bestB
+}
+
+// neighborhoodPreservation returns the mean fraction of each point's k
+// nearest neighbors in the input that remain among its k nearest
+// neighbors in the embedding
+func neighborhoodPreservation(vectors, embedding [][]float64, k int) float64 {
+	high, _ := exactKNN(vectors, k)
+	low, _ := exactKNN(embedding, k)
+
+	total := 0.0
+	for i := range high {
+		kept := make(map[int]bool, k)
+		for _, j := range low[i] {
+			kept[j] = true
+		}
+		shared := 0
+		for _, j := range high[i] {
+			if kept[j] {
+				shared++
+			}
+		}
+		total += float64(shared) / float64(k)
+	}
+	return total / float64(len(high))
+}
+
+// parseUMAPOptions reads the n_neighbors and min_dist query parameters
+func parseUMAPOptions(query url.Values) (int, float64, error) {
+	nNeighbors := 15 // Default
+	if nNeighborsStr := query.Get("n_neighbors"); nNeighborsStr != "" {
+		parsedNNeighbors, err := strconv.Atoi(nNeighborsStr)
+		if err != nil || parsedNNeighbors < 2 {
+			return 0, 0, fmt.Errorf("n_neighbors must be an integer of at least 2")
+		}
+		nNeighbors = parsedNNeighbors
+	}
+
+	minDist := 0.1 // Default
+	if minDistStr := query.Get("min_dist"); minDistStr != "" {
+		parsedMinDist, err := strconv.ParseFloat(minDistStr, 64)
+		if err != nil || !(parsedMinDist >= 0 && parsedMinDist <= umapSpread) {
+			return 0, 0, fmt.Errorf("min_dist must be a number between 0 and %g", umapSpread)
+		}
+		minDist = parsedMinDist
+	}
+
+	return nNeighbors, minDist, nil
+}
+
+// checkUMAPInput reports why n vectors can't be embedded with nNeighbors
+func checkUMAPInput(n, nNeighbors int) error {
+	if n > maxUMAPItems {
+		return fmt.Errorf("UMAP is limited to %d vectors", maxUMAPItems)
+	}
+	if nNeighbors >= n {
+		return fmt.Errorf("n_neighbors must be less than the number of vectors (%d)", n)
+	}
+	return nil
+}
+
+func handleUMAP(w http.ResponseWriter, r *http.Request) {
+	if !beginGET(w, r) {
+		return
+	}
+
+	// Parse query parameters
+	params, err := parseVectorParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	nNeighbors, minDist, err := parseUMAPOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Refuse oversized requests before generating anything
+	if err := checkUMAPInput(datasetSize(params), nNeighbors); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Generate data
+	data, err := datasetItems(r.Context(), params)
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	if err := checkUMAPInput(len(data), nNeighbors); err != nil {
+		// The loaded dataset changed in the meantime
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	vectors := make([][]float64, len(data))
+	for i, item := range data {
+		vectors[i] = item.Vector
+	}
+
+	// Seed the initial layout from the generation seed so results are reproducible
+	embedding, err := runUMAP(r.Context(), vectors, nNeighbors, minDist, 2, params.Rand())
+	if err != nil {
+		writeWorkError(w, err)
+		return
+	}
+	for i := range data {
+		data[i].Projection = embedding[i]
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	response := UMAPResponse{
+		Data:                     data,
+		Total:                    len(data),
+		NNeighbors:               nNeighbors,
+		MinDist:                  minDist,
+		NeighborhoodPreservation: neighborhoodPreservation(vectors, embedding, nNeighbors),
+	}
+
+	newJSONEncoder(w, r).Encode(response)
+}